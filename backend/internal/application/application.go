@@ -6,23 +6,29 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/handlers"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/jwtkeys"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models/memory"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/workers"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// writeTimeout also bounds the context of every request, see routes
+const writeTimeout = 10 * time.Second
+
 type Application struct {
-	config    *config.Config
-	logger    *slog.Logger
-	server    *http.Server
-	handler   *handlers.Handlers
-	models    *models.Models
-	emailChan *amqp.Channel
+	config  *config.Config
+	logger  *slog.Logger
+	server  *http.Server
+	handler *handlers.Handlers
+	models  models.Store
+	mail    workers.MailPublisher
 }
 
 func New() *Application {
@@ -45,75 +51,116 @@ func (app *Application) Run() {
 	}
 	app.config = cfg
 
-	/****************************************************************
-		establish database connection
-	****************************************************************/
-	db, err := utils.OpenDB(cfg)
+	ctx, cancel := context.WithCancel(context.Background()) // context for graceful shutdown
+	defer cancel()
+
+	closeAll, err := app.setup(ctx)
 	if err != nil {
 		app.logger.Error(err.Error())
 		os.Exit(1)
 	}
-	defer db.Close()
-	app.logger.Info("database connection pool established")
-	app.models = models.New(db)
+	defer closeAll()
 
 	/****************************************************************
-		establish mail sender
+		start the server
 	****************************************************************/
-	conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:5672/", app.config.RabbitMQ.User, app.config.RabbitMQ.Password, app.config.RabbitMQ.Host))
-	if err != nil {
+	app.server = &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.ServerPort),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: writeTimeout,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+	app.logger.Info("starting server", "addr", app.server.Addr)
+	if err := app.server.ListenAndServe(); err != nil {
 		app.logger.Error(err.Error())
-		os.Exit(1)
 	}
-	defer conn.Close()
+}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		app.logger.Error(err.Error())
-		os.Exit(1)
+// setup connects the storage and the mail of the configuration, starts the
+// workers and builds the handlers. The returned func closes the connections,
+// also when setup fails part way.
+func (app *Application) setup(ctx context.Context) (func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for _, c := range slices.Backward(closers) {
+			c()
+		}
+	}
+	fail := func(err error) (func(), error) {
+		closeAll()
+		return func() {}, err
 	}
-	defer ch.Close()
-	app.emailChan = ch
 
-	_, err = ch.QueueDeclare("mail_queue", true, false, false, false, nil)
-	if err != nil {
-		app.logger.Error(err.Error())
-		os.Exit(1)
+	/****************************************************************
+		establish database connection
+	****************************************************************/
+	switch app.config.Storage {
+	case config.StorageMemory:
+		app.models = memory.New()
+		app.logger.Warn("using in-memory storage, all data is lost when the api stops")
+	default:
+		db, err := utils.OpenDB(app.config)
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, db.Close)
+		app.logger.Info("database connection pool established")
+		app.models = models.New(db, app.config)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background()) // context for graceful shutdown
-	defer cancel()
+	/****************************************************************
+		establish mail sender
+	****************************************************************/
+	switch app.config.Storage {
+	case config.StorageMemory:
+		// running without infrastructure, mails only go to the log
+		app.mail = workers.NewLogMailPublisher(app.logger)
+		app.logger.Warn("using in-memory storage, mails are logged instead of sent")
+	default:
+		conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:5672/", app.config.RabbitMQ.User, app.config.RabbitMQ.Password, app.config.RabbitMQ.Host))
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, func() { _ = conn.Close() })
 
-	mailSender := workers.NewMailSender(app.config, app.logger, ch)
-	if err := mailSender.Run(ctx); err != nil {
-		app.logger.Error("failed to start the mail sender", slog.String("error", err.Error()))
-		os.Exit(1)
+		ch, err := conn.Channel()
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, func() { _ = ch.Close() })
+
+		if _, err := ch.QueueDeclare("mail_queue", true, false, false, false, nil); err != nil {
+			return fail(err)
+		}
+
+		mailSender := workers.NewMailSender(app.config, app.logger, ch)
+		if err := mailSender.Run(ctx); err != nil {
+			return fail(fmt.Errorf("failed to start the mail sender: %w", err))
+		}
+		app.mail = workers.NewAMQPMailPublisher(ch)
+		app.logger.Info("email client established")
+	}
+
+	graduationReminder := workers.NewGraduationReminder(app.config, app.logger, app.models, app.mail)
+	graduationReminder.Run(ctx)
+
+	keys := jwtkeys.New(app.config, app.logger, app.models)
+	if err := keys.Run(ctx); err != nil {
+		return fail(fmt.Errorf("failed to load the jwt signing keys: %w", err))
 	}
-	app.logger.Info("email client established")
+	app.logger.Info("jwt signing keys loaded")
 
 	/****************************************************************
 		perform health check
 	****************************************************************/
-	if err := app.healthCheck(); err != nil {
-		app.logger.Error(err.Error())
-		os.Exit(1)
+	if err := app.healthCheck(ctx); err != nil {
+		return fail(err)
 	}
 	app.logger.Info("health check completed")
 
-	/****************************************************************
-		start the server
-	****************************************************************/
-	app.handler = handlers.New(app.config, app.logger, app.models, ch)
-	app.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.config.ServerPort),
-		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
-	}
-	app.logger.Info("starting server", "addr", app.server.Addr)
-	if err := app.server.ListenAndServe(); err != nil {
-		app.logger.Error(err.Error())
-	}
+	app.handler = handlers.New(app.config, app.logger, app.models, app.mail, keys)
+
+	return closeAll, nil
 }
@@ -1,23 +1,56 @@
 package application
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/password"
 )
 
-func (app *Application) healthCheck() error {
-	if err := app.checkBlackCoreExists(); err != nil {
+// the backfill walks every user once, which takes longer than the timeouts
+// of a single model call
+const pinyinBackfillTimeout = time.Minute
+
+func (app *Application) healthCheck(ctx context.Context) error {
+	if err := app.checkAdminRoleExists(ctx); err != nil {
+		return err
+	}
+
+	if err := app.checkBlackCoreExists(ctx); err != nil {
+		return err
+	}
+
+	if err := app.backfillUserPinyin(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (app *Application) checkAdminRoleExists(ctx context.Context) error {
+	role, err := app.models.SelectRoleByName(ctx, app.config.InitialAdmin.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("initial admin role %q does not exist", app.config.InitialAdmin.Role)
+		}
+		return err
+	}
+
+	// the initial admin role always holds every permission
+	if err := app.models.GrantAllPermissions(ctx, role.ID); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (app *Application) checkBlackCoreExists() error {
-	_, err := app.models.SelectUserByUsername(app.config.InitialAdmin.Username)
+func (app *Application) checkBlackCoreExists(ctx context.Context) error {
+	_, err := app.models.SelectUserByUsername(ctx, app.config.InitialAdmin.Username)
 	if err == nil {
 		// initial black core exists
 		return nil
@@ -30,22 +63,44 @@ func (app *Application) checkBlackCoreExists() error {
 		return err // unknown error
 	}
 
-	password_hash, err := bcrypt.GenerateFromPassword([]byte(app.config.InitialAdmin.Password), bcrypt.DefaultCost)
+	// a weak configured password should not keep the api from starting, but
+	// it ought to be changed
+	hasher := password.New(app.config)
+	if err := hasher.Validate(app.config.InitialAdmin.Password, app.config.InitialAdmin.Username); err != nil {
+		app.logger.Warn("initial admin password does not meet the password policy", slog.String("reason", err.Error()))
+	}
+	password_hash, err := hasher.Hash(app.config.InitialAdmin.Password)
 	if err != nil {
 		return err
 	}
 
 	user := &models.User{
 		Username:     app.config.InitialAdmin.Username,
-		PasswordHash: string(password_hash),
+		PasswordHash: password_hash,
 		Email:        app.config.InitialAdmin.Email,
 		FullName:     app.config.InitialAdmin.FullName,
-		Role:         "黑心",
+		Role:         app.config.InitialAdmin.Role,
+	}
+
+	if err := app.models.InsertUser(ctx, user); err != nil {
+		return err
 	}
 
-	if err := app.models.InsertUser(user); err != nil {
+	return nil
+}
+
+func (app *Application) backfillUserPinyin(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pinyinBackfillTimeout)
+	defer cancel()
+
+	n, err := app.models.BackfillUserPinyin(ctx)
+	if err != nil {
 		return err
 	}
 
+	if n > 0 {
+		app.logger.Info("backfilled user pinyin", slog.Int("users", n))
+	}
+
 	return nil
 }
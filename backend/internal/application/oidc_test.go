@@ -0,0 +1,338 @@
+package application
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+const (
+	testOIDCClientID     = "ecnc-shift-manager"
+	testOIDCClientSecret = "secret"
+	testOIDCKeyID        = "test"
+)
+
+// fakeProvider is an identity provider serving discovery, its keys, and an
+// authorization endpoint that signs in whoever claims is set to, as soon as
+// it is asked.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// claims are added to the id tokens of the next sign-ins
+	claims map[string]any
+	// codes are the authorization requests waiting for their token
+	codes map[string]url.Values
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{t: t, key: key, codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *fakeProvider) signInAs(claims map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.claims = claims
+}
+
+func (p *fakeProvider) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (p *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	p.writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *fakeProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &p.key.PublicKey,
+		KeyID:     testOIDCKeyID,
+		Algorithm: "RS256",
+		Use:       "sig",
+	}}})
+}
+
+// authorize signs in at once and sends the browser back with a code, as a
+// provider does once its user has logged in.
+func (p *fakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testOIDCClientID || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := utils.GenerateToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = query
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token exchanges a code for an id token, once, and only with the verifier
+// of its challenge.
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testOIDCClientID || clientSecret != testOIDCClientSecret {
+		p.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	authorization, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	claims := p.claims
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.Get("code_challenge") {
+		p.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"sub":   "subject",
+		"aud":   testOIDCClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": authorization.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
+	idToken.Header["kid"] = testOIDCKeyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		p.t.Error(err)
+		return
+	}
+
+	p.writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+// oidcLogin follows the redirects of a login through the provider, and
+// returns where the api finally sends the browser in the frontend.
+func (c *testClient) oidcLogin(redirect string) *url.URL {
+	c.t.Helper()
+
+	// the frontend itself is not running
+	client := *c.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if strings.HasPrefix(req.URL.String(), c.app.config.AppBaseURL) {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	res, err := client.Get(c.app.server.URL + "/auth/oidc/login?redirect=" + url.QueryEscape(redirect))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		c.t.Fatalf("got %d, want a redirect to the frontend", res.StatusCode)
+	}
+	location, err := res.Location()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return location
+}
+
+func newOIDCTestApp(t *testing.T) (*testApp, *fakeProvider) {
+	t.Helper()
+
+	provider := newFakeProvider(t)
+	app := newTestApp(t, map[string]string{
+		"OIDC_ISSUER_URL":    provider.server.URL,
+		"OIDC_CLIENT_ID":     testOIDCClientID,
+		"OIDC_CLIENT_SECRET": testOIDCClientSecret,
+		"OIDC_CLAIM":         "email",
+		"OIDC_USER_FIELD":    "email",
+	})
+	// the api serves the callback itself, without the /api prefix of the
+	// proxy
+	app.config.OIDC.RedirectURL = app.server.URL + "/auth/oidc/callback"
+
+	return app, provider
+}
+
+func TestOIDCLogin(t *testing.T) {
+	app, provider := newOIDCTestApp(t)
+	c := app.newClient(t)
+
+	provider.signInAs(map[string]any{"email": "admin@example.com", "email_verified": true})
+	location := c.oidcLogin("/users")
+	if want := app.config.AppBaseURL + "/users"; location.String() != want {
+		t.Fatalf("got redirected to %s, want %s", location, want)
+	}
+
+	var me struct {
+		Username string `json:"username"`
+	}
+	c.get("/me/").decode(t, &me)
+	if me.Username != testAdminUsername {
+		t.Fatalf("got %q, want %q", me.Username, testAdminUsername)
+	}
+
+	// the flow cookie is spent with its state
+	client := *c.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res, err := client.Get(c.app.server.URL + "/auth/oidc/callback?code=replayed&state=replayed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	location, err = res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/auth/login" || location.Query().Get("error") == "" {
+		t.Fatalf("got redirected to %s, want the login page with an error", location)
+	}
+}
+
+func TestOIDCLoginRejects(t *testing.T) {
+	app, provider := newOIDCTestApp(t)
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		code   string
+	}{
+		{"unverified email", map[string]any{"email": "admin@example.com", "email_verified": false}, "oidc_email_unverified"},
+		{"email without verification", map[string]any{"email": "admin@example.com"}, "oidc_email_unverified"},
+		{"unknown email", map[string]any{"email": "nobody@example.com", "email_verified": true}, "oidc_user_not_linked"},
+		{"no email", map[string]any{}, "oidc_claim_missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := app.newClient(t)
+			provider.signInAs(tt.claims)
+
+			location := c.oidcLogin("/")
+			if want := i18n.T(i18n.Default, "error."+tt.code); location.Path != "/auth/login" || location.Query().Get("error") != want {
+				t.Fatalf("got redirected to %s, want the login page with %q", location, want)
+			}
+			c.get("/me/").expect(t, http.StatusUnauthorized, "not_logged_in")
+		})
+	}
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+	app, provider := newOIDCTestApp(t)
+	c := app.newClient(t)
+	provider.signInAs(map[string]any{"email": "admin@example.com", "email_verified": true})
+
+	// stop at the provider, then come back with a state of another login
+	client := *c.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res, err := client.Get(app.server.URL + "/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	authURL, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = client.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	callback, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := callback.Query()
+	query.Set("state", "forged")
+	callback.RawQuery = query.Encode()
+
+	res, err = client.Get(callback.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	location, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/auth/login" || location.Query().Get("error") == "" {
+		t.Fatalf("got redirected to %s, want the login page with an error", location)
+	}
+	c.get("/me/").expect(t, http.StatusUnauthorized, "not_logged_in")
+}
@@ -4,58 +4,146 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-)
-
-var (
-	blackCoreLevel int32 = 3
+	"github.com/go-chi/cors"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
 )
 
 func (app *Application) routes() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(app.handler.LoggerMiddleware)
-	r.Use(middleware.Recoverer)
+	r.Use(app.handler.RecovererMiddleware)
+	r.Use(app.handler.DeadlineMiddleware(writeTimeout))
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   app.config.CORS.AllowedOrigins,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"X-Impersonated-By"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+	r.Use(app.handler.CSRFMiddleware)
+
+	r.Get("/.well-known/jwks.json", app.handler.GetJWKS)
 
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/login", app.handler.Login)
-		r.With(app.handler.GetRequesterMiddleware).Post("/logout", app.handler.Logout)
+		r.Post("/forgot-password", app.handler.ForgotPassword)
+		r.Post("/reset-password", app.handler.ResetPassword)
+		r.Post("/activate", app.handler.ActivateAccount)
+		r.Post("/login/2fa", app.handler.Login2FA)
+		r.Get("/methods", app.handler.GetAuthMethods)
+		r.Get("/oidc/login", app.handler.OIDCLogin)
+		r.Get("/oidc/callback", app.handler.OIDCCallback)
+		r.With(app.handler.GetRequesterMiddleware, app.handler.SessionOnlyMiddleware).Post("/logout", app.handler.Logout)
+		r.With(app.handler.GetRequesterMiddleware, app.handler.SessionOnlyMiddleware).Post("/stop-impersonation", app.handler.StopImpersonation)
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(app.handler.GetRequesterMiddleware)
-		r.Route("/users", func(r chi.Router) {
-			r.Use(app.handler.AuthGuardMiddleware(blackCoreLevel))
-			r.Post("/", app.handler.CreateUser)
-			r.Get("/", app.handler.GetAllUsers)
-			r.Route("/{userID}", func(r chi.Router) {
-				r.Use(app.handler.GetUserMiddleware)
-				r.Get("/", app.handler.GetUser)
-				r.Delete("/", app.handler.DeleteUser)
-				r.Post("/update-role", app.handler.UpdateUserRole)
-			})
-		})
 		r.Route("/me", func(r chi.Router) {
 			r.Get("/", app.handler.GetMyInfo)
-			r.Post("/update-password", app.handler.UpdateMyPassword)
-		})
-		r.Route("/schedule-templates", func(r chi.Router) {
-			r.Use(app.handler.AuthGuardMiddleware(blackCoreLevel))
-			r.Post("/", app.handler.CreateScheduleTemplate)
-			r.Get("/{scheduleTemplateID}", app.handler.GetScheduleTemplates)
-			r.Delete("/{scheduleTemplateID}", app.handler.DeleteScheduleTemplate)
-		})
-		r.Route("/schedule-template-meta", func(r chi.Router) {
-			r.Use(app.handler.AuthGuardMiddleware(blackCoreLevel))
-			r.Get("/", app.handler.GetAllScheduleTemplateMeta)
-			r.Post("/{scheduleTemplateID}/update-description", app.handler.UpdateScheduleTemplateDescription)
+			r.Route("/2fa", func(r chi.Router) {
+				r.Use(app.handler.SessionOnlyMiddleware, app.handler.NoImpersonationMiddleware)
+				r.Get("/", app.handler.GetMyTwoFactor)
+				r.Post("/enroll", app.handler.EnrollMyTwoFactor)
+				r.Post("/enable", app.handler.EnableMyTwoFactor)
+				r.Post("/disable", app.handler.DisableMyTwoFactor)
+				r.Post("/regenerate-recovery-codes", app.handler.RegenerateMyRecoveryCodes)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.handler.TwoFactorGuardMiddleware)
+				r.With(app.handler.NoImpersonationMiddleware).Post("/update-profile", app.handler.UpdateMyProfile)
+				r.Group(func(r chi.Router) {
+					r.Use(app.handler.SessionOnlyMiddleware, app.handler.NoImpersonationMiddleware)
+					r.Post("/update-password", app.handler.UpdateMyPassword)
+					r.Route("/sessions", func(r chi.Router) {
+						r.Get("/", app.handler.GetMySessions)
+						r.Post("/revoke-all", app.handler.RevokeAllMySessions)
+						r.Post("/{sessionID}/revoke", app.handler.RevokeMySession)
+					})
+					r.Route("/tokens", func(r chi.Router) {
+						r.Get("/", app.handler.GetMyAPITokens)
+						r.Post("/", app.handler.CreateMyAPIToken)
+						r.Post("/{tokenID}/revoke", app.handler.RevokeMyAPIToken)
+					})
+				})
+			})
 		})
-		r.Route("/schedule-plans", func(r chi.Router) {
-			r.Use(app.handler.AuthGuardMiddleware(blackCoreLevel))
-			r.Post("/", app.handler.CreateSchedulePlan)
-			r.Route("/{schedulePlanID}", func(r chi.Router) {
-				r.Use(app.handler.GetSchedulePlanMiddleware)
-				r.Get("/", app.handler.GetSchedulePlan)
+
+		// roles that require 2FA may only enroll until they have enabled it
+		r.Group(func(r chi.Router) {
+			r.Use(app.handler.TwoFactorGuardMiddleware)
+			r.Route("/users", func(r chi.Router) {
+				r.Use(app.handler.PermissionGuardMiddleware(models.PermissionUsersManage))
+				r.Post("/", app.handler.CreateUser)
+				r.Get("/", app.handler.GetAllUsers)
+				r.Post("/import", app.handler.ImportUsers)
+				r.Get("/assignable-roles", app.handler.GetAssignableRoles)
+				r.Route("/{userID}", func(r chi.Router) {
+					r.Use(app.handler.GetUserMiddleware)
+					r.Get("/", app.handler.GetUser)
+					r.Group(func(r chi.Router) {
+						r.Use(app.handler.UserLevelGuardMiddleware)
+						r.Post("/deactivate", app.handler.DeactivateUser)
+						r.Post("/restore", app.handler.RestoreUser)
+						r.Post("/resend-invitation", app.handler.ResendInvitation)
+						r.Post("/revoke-invitation", app.handler.RevokeInvitation)
+						r.Post("/unlock", app.handler.UnlockUser)
+						r.Post("/reset-2fa", app.handler.ResetUserTwoFactor)
+						r.Post("/update-role", app.handler.UpdateUserRole)
+						r.Post("/update-profile", app.handler.UpdateUserProfile)
+						r.With(
+							app.handler.PermissionGuardMiddleware(models.PermissionUsersImpersonate),
+							app.handler.SessionOnlyMiddleware,
+							app.handler.NoImpersonationMiddleware,
+						).Post("/impersonate", app.handler.ImpersonateUser)
+					})
+				})
+			})
+			r.Route("/roles", func(r chi.Router) {
+				r.Use(app.handler.PermissionGuardMiddleware(models.PermissionRolesManage))
+				r.Post("/", app.handler.CreateRole)
+				r.Get("/", app.handler.GetAllRoles)
+				r.Route("/{roleID}", func(r chi.Router) {
+					r.Use(app.handler.GetRoleMiddleware)
+					r.Get("/", app.handler.GetRole)
+					r.Group(func(r chi.Router) {
+						r.Use(app.handler.RoleLevelGuardMiddleware)
+						r.Post("/update", app.handler.UpdateRole)
+						r.Post("/update-permissions", app.handler.UpdateRolePermissions)
+						r.Delete("/", app.handler.DeleteRole)
+					})
+				})
+			})
+			r.With(app.handler.PermissionGuardMiddleware(models.PermissionRolesManage)).Get("/permissions", app.handler.GetAllPermissions)
+			r.Route("/login-lock-events", func(r chi.Router) {
+				r.Use(app.handler.PermissionGuardMiddleware(models.PermissionUsersManage))
+				r.Get("/", app.handler.GetLoginLockEvents)
+				r.Post("/{lockEventID}/unlock", app.handler.UnlockLoginLockEvent)
+			})
+			r.With(app.handler.PermissionGuardMiddleware(models.PermissionAuditView)).Get("/audit-events", app.handler.GetAuditEvents)
+			r.Route("/schedule-templates", func(r chi.Router) {
+				r.Use(app.handler.PermissionGuardMiddleware(models.PermissionTemplatesEdit))
+				r.Post("/", app.handler.CreateScheduleTemplate)
+				r.Get("/{scheduleTemplateID}", app.handler.GetScheduleTemplates)
+				r.Delete("/{scheduleTemplateID}", app.handler.DeleteScheduleTemplate)
+			})
+			r.Route("/schedule-template-meta", func(r chi.Router) {
+				r.Use(app.handler.PermissionGuardMiddleware(models.PermissionTemplatesEdit))
+				r.Get("/", app.handler.GetAllScheduleTemplateMeta)
+				r.Post("/{scheduleTemplateID}/update-description", app.handler.UpdateScheduleTemplateDescription)
+			})
+			r.Route("/schedule-plans", func(r chi.Router) {
+				r.Use(app.handler.PermissionGuardMiddleware(models.PermissionPlansEdit))
+				r.Post("/", app.handler.CreateSchedulePlan)
+				r.Route("/{schedulePlanID}", func(r chi.Router) {
+					r.Use(app.handler.GetSchedulePlanMiddleware)
+					r.Get("/", app.handler.GetSchedulePlan)
+					r.Get("/export", app.handler.ExportSchedulePlan)
+					r.Put("/assignments", app.handler.UpdateSchedulePlanAssignments)
+					r.Post("/publish", app.handler.PublishSchedulePlan)
+				})
 			})
 		})
 	})
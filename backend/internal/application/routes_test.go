@@ -0,0 +1,714 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/password"
+)
+
+const (
+	testAdminUsername = "admin"
+	testAdminPassword = "correct horse battery staple"
+	testAdminRole     = "黑心"
+)
+
+// testApp serves the routes of the api set up as STORAGE=memory does: on
+// top of the in-memory store, logging its mails instead of sending them.
+type testApp struct {
+	*Application
+	server *httptest.Server
+}
+
+func newTestApp(t *testing.T, env map[string]string) *testApp {
+	t.Helper()
+
+	for key, value := range map[string]string{
+		"ENVIRONMENT":                    "development",
+		"JWT_SECRET":                     "test-secret-that-is-at-least-32-bytes-long",
+		"APP_BASE_URL":                   "http://localhost:5173",
+		"STORAGE":                        config.StorageMemory,
+		"RABBITMQ_HOST":                  "rabbitmq.invalid",
+		"LOCAL_LOGIN_ENABLED":            "true",
+		"OIDC_ISSUER_URL":                "",
+		"TRUSTED_PROXIES":                "",
+		"TWO_FACTOR_REQUIRED_ROLE_LEVEL": "0",
+		"INITIAL_ADMIN_USERNAME":         testAdminUsername,
+		"INITIAL_ADMIN_FULLNAME":         "Admin",
+		"INITIAL_ADMIN_EMAIL":            "admin@example.com",
+		"INITIAL_ADMIN_PASSWORD":         testAdminPassword,
+		"INITIAL_ADMIN_ROLE":             testAdminRole,
+	} {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg, err := config.ReadConfig(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	app := &Application{config: cfg, logger: logger}
+	closeAll, err := app.setup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(closeAll)
+
+	server := httptest.NewServer(app.routes())
+	t.Cleanup(server.Close)
+
+	return &testApp{Application: app, server: server}
+}
+
+// createUser adds an active user with the role, as if they had accepted
+// their invitation.
+func (app *testApp) createUser(t *testing.T, username, role string) *models.User {
+	t.Helper()
+
+	hash, err := password.New(app.config).Hash(testAdminPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Email:        username + "@example.com",
+		FullName:     username,
+		Role:         role,
+	}
+	if err := app.models.InsertUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// testClient is a browser of its own, keeping the cookies of the api and
+// echoing the CSRF token like the frontend does.
+type testClient struct {
+	t      *testing.T
+	app    *testApp
+	client *http.Client
+}
+
+type testResponse struct {
+	Status  int
+	Header  http.Header
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Code    string            `json:"code"`
+	Fields  map[string]string `json:"fields"`
+	Data    json.RawMessage   `json:"data"`
+}
+
+func (app *testApp) newClient(t *testing.T) *testClient {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t: t, app: app, client: &http.Client{Jar: jar}}
+}
+
+func (c *testClient) cookie(name string) string {
+	u, _ := url.Parse(c.app.server.URL)
+	for _, cookie := range c.client.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// request sends body as JSON. Requests that change state carry the CSRF
+// token unless a header of the same name is given.
+func (c *testClient) request(method, path string, body any, header http.Header) *testResponse {
+	c.t.Helper()
+
+	var reader io.Reader
+	if r, ok := body.(io.Reader); ok {
+		reader = r
+	} else if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.app.server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if method != http.MethodGet {
+		req.Header.Set("X-CSRF-Token", c.cookie("__ecnc_shift_manager_csrf"))
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	r := &testResponse{Status: res.StatusCode, Header: res.Header}
+	if err := json.NewDecoder(res.Body).Decode(r); err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return r
+}
+
+func (c *testClient) get(path string) *testResponse {
+	c.t.Helper()
+	return c.request(http.MethodGet, path, nil, nil)
+}
+
+func (c *testClient) post(path string, body any) *testResponse {
+	c.t.Helper()
+	return c.request(http.MethodPost, path, body, nil)
+}
+
+// upload posts content as the file field of a form, like a file input does.
+func (c *testClient) upload(path, content string) *testResponse {
+	c.t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "upload.csv")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := io.WriteString(file, content); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.request(http.MethodPost, path, &body, http.Header{"Content-Type": {form.FormDataContentType()}})
+}
+
+func (c *testClient) login(username string) *testResponse {
+	c.t.Helper()
+
+	res := c.post("/auth/login", map[string]string{"username": username, "password": testAdminPassword})
+	if res.Status != http.StatusOK {
+		c.t.Fatalf("login of %s: %d %s", username, res.Status, res.Code)
+	}
+	return res
+}
+
+// expect fails the test unless the response has the status and, when given,
+// the error code.
+func (r *testResponse) expect(t *testing.T, status int, code string) {
+	t.Helper()
+
+	if r.Status != status || r.Code != code {
+		t.Fatalf("got %d %q (%s), want %d %q", r.Status, r.Code, r.Message, status, code)
+	}
+}
+
+func (r *testResponse) decode(t *testing.T, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// totp computes the code of an authenticator app for the given step.
+func totp(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha1.New, key)
+	_ = binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func totpStep() int64 {
+	return time.Now().Unix() / 30
+}
+
+func TestLogin(t *testing.T) {
+	app := newTestApp(t, nil)
+	c := app.newClient(t)
+
+	res := c.post("/auth/login", map[string]string{"username": testAdminUsername, "password": "wrong"})
+	res.expect(t, http.StatusUnauthorized, "invalid_credentials")
+
+	c.get("/me/").expect(t, http.StatusUnauthorized, "not_logged_in")
+
+	c.login(testAdminUsername)
+	var me models.User
+	c.get("/me/").decode(t, &me)
+	if me.Username != testAdminUsername {
+		t.Fatalf("got %q, want %q", me.Username, testAdminUsername)
+	}
+
+	c.post("/auth/logout", nil).expect(t, http.StatusOK, "")
+	c.get("/me/").expect(t, http.StatusUnauthorized, "not_logged_in")
+}
+
+func TestLoginWithTwoFactor(t *testing.T) {
+	app := newTestApp(t, nil)
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	c.post("/me/2fa/enroll", nil).decode(t, &enrollment)
+
+	// the code of the last step, so that the login below can use the
+	// current one
+	step := totpStep()
+	c.post("/me/2fa/enable", map[string]string{"code": "000000"}).expect(t, http.StatusBadRequest, "second_factor_invalid")
+	var enabled struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	c.post("/me/2fa/enable", map[string]string{"code": totp(t, enrollment.Secret, step-1)}).decode(t, &enabled)
+	c.post("/auth/logout", nil).expect(t, http.StatusOK, "")
+
+	login := func() string {
+		var partial struct {
+			PartialToken string `json:"partialToken"`
+		}
+		c.login(testAdminUsername).decode(t, &partial)
+		if partial.PartialToken == "" {
+			t.Fatal("login did not ask for the second factor")
+		}
+		// the password alone starts no session
+		c.get("/me/").expect(t, http.StatusUnauthorized, "not_logged_in")
+		return partial.PartialToken
+	}
+
+	partialToken := login()
+	c.post("/auth/login/2fa", map[string]string{"partialToken": partialToken}).expect(t, http.StatusBadRequest, "second_factor_required")
+	c.post("/auth/login/2fa", map[string]string{"partialToken": partialToken, "code": totp(t, enrollment.Secret, step-1)}).expect(t, http.StatusBadRequest, "second_factor_invalid")
+	c.post("/auth/login/2fa", map[string]string{"partialToken": "forged", "code": totp(t, enrollment.Secret, step)}).expect(t, http.StatusUnauthorized, "partial_token_expired")
+	c.post("/auth/login/2fa", map[string]string{"partialToken": partialToken, "code": totp(t, enrollment.Secret, step)}).expect(t, http.StatusOK, "")
+	c.get("/me/").expect(t, http.StatusOK, "")
+
+	// each recovery code works once
+	c.post("/auth/logout", nil).expect(t, http.StatusOK, "")
+	partialToken = login()
+	c.post("/auth/login/2fa", map[string]string{"partialToken": partialToken, "recoveryCode": enabled.RecoveryCodes[0]}).expect(t, http.StatusOK, "")
+	c.post("/auth/logout", nil).expect(t, http.StatusOK, "")
+	partialToken = login()
+	c.post("/auth/login/2fa", map[string]string{"partialToken": partialToken, "recoveryCode": enabled.RecoveryCodes[0]}).expect(t, http.StatusBadRequest, "second_factor_invalid")
+}
+
+func TestCSRF(t *testing.T) {
+	app := newTestApp(t, nil)
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+
+	body := map[string]string{"fullName": "Administrator"}
+	c.request(http.MethodPost, "/me/update-profile", body, http.Header{"X-Csrf-Token": {""}}).expect(t, http.StatusForbidden, "csrf_failed")
+	c.request(http.MethodPost, "/me/update-profile", body, http.Header{"X-Csrf-Token": {"forged"}}).expect(t, http.StatusForbidden, "csrf_failed")
+	c.request(http.MethodPost, "/me/update-profile", body, http.Header{"Origin": {"https://evil.example.com"}}).expect(t, http.StatusForbidden, "csrf_failed")
+
+	// reading needs no token
+	c.request(http.MethodGet, "/me/", nil, http.Header{"X-Csrf-Token": {""}}).expect(t, http.StatusOK, "")
+	c.request(http.MethodPost, "/me/update-profile", body, http.Header{"Origin": {app.config.AppBaseURL}}).expect(t, http.StatusOK, "")
+
+	// without a session there is nothing to forge, except from a foreign
+	// origin
+	anonymous := app.newClient(t)
+	anonymous.post("/auth/login", map[string]string{"username": testAdminUsername, "password": "wrong"}).expect(t, http.StatusUnauthorized, "invalid_credentials")
+	anonymous.request(http.MethodPost, "/auth/login", map[string]string{"username": testAdminUsername, "password": testAdminPassword}, http.Header{"Origin": {"https://evil.example.com"}}).expect(t, http.StatusForbidden, "csrf_failed")
+}
+
+func TestImpersonation(t *testing.T) {
+	app := newTestApp(t, nil)
+	ctx := context.Background()
+
+	// seniors may manage and impersonate users, like the admins
+	senior, err := app.models.SelectRoleByName(ctx, "资深助理")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.UpdateRolePermissions(ctx, senior.ID, []string{models.PermissionUsersManage, models.PermissionUsersImpersonate}); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := app.createUser(t, "alice", "资深助理")
+	bob := app.createUser(t, "bob", "资深助理")
+	carol := app.createUser(t, "carol", "普通助理")
+	dave := app.createUser(t, "dave", "普通助理")
+	dave.Status = models.UserStatusDeactivated
+	if err := app.models.UpdateUser(ctx, dave); err != nil {
+		t.Fatal(err)
+	}
+
+	c := app.newClient(t)
+	c.login(alice.Username)
+
+	impersonate := func(user *models.User) *testResponse {
+		return c.post("/users/"+user.ID.String()+"/impersonate", nil)
+	}
+	impersonate(alice).expect(t, http.StatusBadRequest, "cannot_impersonate_self")
+	impersonate(bob).expect(t, http.StatusForbidden, "user_target_level")
+	impersonate(dave).expect(t, http.StatusBadRequest, "impersonation_target_inactive")
+
+	res := impersonate(carol)
+	res.expect(t, http.StatusOK, "")
+	if got := res.Header.Get("X-Impersonated-By"); got != alice.Username {
+		t.Fatalf("got X-Impersonated-By %q, want %q", got, alice.Username)
+	}
+
+	var me models.User
+	res = c.get("/me/")
+	res.decode(t, &me)
+	if me.Username != carol.Username || me.ImpersonatedBy != alice.Username {
+		t.Fatalf("got %q impersonated by %q, want %q by %q", me.Username, me.ImpersonatedBy, carol.Username, alice.Username)
+	}
+
+	// the credentials of the user stay theirs
+	c.post("/me/update-password", map[string]string{"oldPassword": testAdminPassword, "newPassword": "another horse battery staple"}).expect(t, http.StatusForbidden, "forbidden_while_impersonating")
+	c.post("/me/2fa/enroll", nil).expect(t, http.StatusForbidden, "forbidden_while_impersonating")
+	c.post("/me/update-profile", map[string]string{"fullName": "Carol"}).expect(t, http.StatusForbidden, "forbidden_while_impersonating")
+
+	// and the impersonation is bound by the permissions of the user
+	c.get("/users/").expect(t, http.StatusForbidden, "permission_denied")
+
+	c.post("/auth/stop-impersonation", nil).expect(t, http.StatusOK, "")
+	me = models.User{}
+	c.get("/me/").decode(t, &me)
+	if me.Username != alice.Username || me.ImpersonatedBy != "" {
+		t.Fatalf("got %q impersonated by %q, want %q", me.Username, me.ImpersonatedBy, alice.Username)
+	}
+	c.post("/auth/stop-impersonation", nil).expect(t, http.StatusBadRequest, "not_impersonating")
+
+	// the permission to manage users is not enough
+	if err := app.models.UpdateRolePermissions(ctx, senior.ID, []string{models.PermissionUsersManage}); err != nil {
+		t.Fatal(err)
+	}
+	impersonate(carol).expect(t, http.StatusForbidden, "permission_denied")
+}
+
+func TestGuards(t *testing.T) {
+	app := newTestApp(t, nil)
+	ctx := context.Background()
+
+	senior, err := app.models.SelectRoleByName(ctx, "资深助理")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.UpdateRolePermissions(ctx, senior.ID, []string{models.PermissionUsersManage}); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := app.createUser(t, "alice", "资深助理")
+	bob := app.createUser(t, "bob", "资深助理")
+	carol := app.createUser(t, "carol", "普通助理")
+
+	// permissions
+	junior := app.newClient(t)
+	junior.login(carol.Username)
+	junior.get("/users/").expect(t, http.StatusForbidden, "permission_denied")
+	junior.get("/roles/").expect(t, http.StatusForbidden, "permission_denied")
+	junior.get("/audit-events").expect(t, http.StatusForbidden, "permission_denied")
+	junior.get("/me/").expect(t, http.StatusOK, "")
+
+	c := app.newClient(t)
+	c.login(alice.Username)
+	c.get("/users/").expect(t, http.StatusOK, "")
+	c.get("/roles/").expect(t, http.StatusForbidden, "permission_denied")
+
+	// levels
+	updateRole := func(user *models.User, role string) *testResponse {
+		return c.post("/users/"+user.ID.String()+"/update-role", map[string]string{"role": role})
+	}
+	updateRole(alice, "普通助理").expect(t, http.StatusForbidden, "cannot_change_own_role")
+	updateRole(bob, "普通助理").expect(t, http.StatusForbidden, "user_target_level")
+	updateRole(carol, "资深助理").expect(t, http.StatusForbidden, "role_level_too_high")
+	updateRole(carol, "nobody").expect(t, http.StatusBadRequest, "role_invalid")
+
+	admin := app.newClient(t)
+	admin.login(testAdminUsername)
+	admin.post("/users/"+carol.ID.String()+"/update-role", map[string]string{"role": "资深助理"}).expect(t, http.StatusOK, "")
+	updateRole(carol, "普通助理").expect(t, http.StatusForbidden, "user_target_level")
+}
+
+func TestTwoFactorGuard(t *testing.T) {
+	app := newTestApp(t, map[string]string{"TWO_FACTOR_REQUIRED_ROLE_LEVEL": "3"})
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+
+	c.get("/users/").expect(t, http.StatusForbidden, "two_factor_enrollment_required")
+	c.get("/me/").expect(t, http.StatusOK, "")
+
+	// enrolling is what the guard leaves open
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	c.post("/me/2fa/enroll", nil).decode(t, &enrollment)
+	c.post("/me/2fa/enable", map[string]string{"code": totp(t, enrollment.Secret, totpStep())}).expect(t, http.StatusOK, "")
+	c.get("/users/").expect(t, http.StatusOK, "")
+
+	// and lower roles are not asked to
+	app.createUser(t, "carol", "普通助理")
+	junior := app.newClient(t)
+	junior.login("carol")
+	junior.get("/me/").expect(t, http.StatusOK, "")
+	junior.post("/me/update-profile", map[string]string{"fullName": "Carol"}).expect(t, http.StatusOK, "")
+}
+
+func TestUserLevelGuard(t *testing.T) {
+	app := newTestApp(t, nil)
+	ctx := context.Background()
+
+	senior, err := app.models.SelectRoleByName(ctx, "资深助理")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.UpdateRolePermissions(ctx, senior.ID, []string{models.PermissionUsersManage}); err != nil {
+		t.Fatal(err)
+	}
+
+	admin, err := app.models.SelectUserByUsername(ctx, testAdminUsername)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := app.createUser(t, "alice", "资深助理")
+	bob := app.createUser(t, "bob", "资深助理")
+	carol := app.createUser(t, "carol", "普通助理")
+
+	c := app.newClient(t)
+	c.login(alice.Username)
+
+	// nothing about the users at or above the requester may change, or
+	// their accounts could be taken over
+	for _, user := range []*models.User{admin, bob} {
+		for path, body := range map[string]any{
+			"/update-profile":    map[string]string{"fullName": "Mallory", "email": "mallory@example.com"},
+			"/update-role":       map[string]string{"role": "普通助理"},
+			"/deactivate":        map[string]string{"status": models.UserStatusDeactivated},
+			"/restore":           nil,
+			"/unlock":            nil,
+			"/reset-2fa":         nil,
+			"/resend-invitation": nil,
+			"/revoke-invitation": nil,
+		} {
+			t.Run(user.Username+path, func(t *testing.T) {
+				c.post("/users/"+user.ID.String()+path, body).expect(t, http.StatusForbidden, "user_target_level")
+			})
+		}
+	}
+	c.get("/users/"+admin.ID.String()).expect(t, http.StatusOK, "")
+
+	c.post("/users/"+alice.ID.String()+"/reset-2fa", nil).expect(t, http.StatusForbidden, "self_reset_2fa_forbidden")
+	c.post("/users/"+carol.ID.String()+"/update-profile", map[string]string{"fullName": "Carol", "email": "carol@example.com"}).expect(t, http.StatusOK, "")
+	c.post("/users/"+carol.ID.String()+"/unlock", nil).expect(t, http.StatusOK, "")
+
+	// alice is only offered the roles below 资深助理
+	var roles []models.Role
+	c.get("/users/assignable-roles").decode(t, &roles)
+	if len(roles) != 1 || roles[0].Name != "普通助理" {
+		t.Fatalf("got %+v, want only 普通助理", roles)
+	}
+	c.get("/roles/").expect(t, http.StatusForbidden, "permission_denied")
+}
+
+func TestRoleLevelGuard(t *testing.T) {
+	app := newTestApp(t, nil)
+	ctx := context.Background()
+
+	roles := map[string]*models.Role{}
+	for _, name := range []string{"普通助理", "资深助理", testAdminRole} {
+		role, err := app.models.SelectRoleByName(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roles[name] = role
+	}
+	if err := app.models.UpdateRolePermissions(ctx, roles["资深助理"].ID, []string{models.PermissionRolesManage}); err != nil {
+		t.Fatal(err)
+	}
+	app.createUser(t, "alice", "资深助理")
+
+	c := app.newClient(t)
+	c.login("alice")
+	c.get("/roles/").expect(t, http.StatusOK, "")
+
+	rolePath := func(name string) string {
+		return "/roles/" + roles[name].ID.String()
+	}
+	update := func(name string, level int32) *testResponse {
+		return c.post(rolePath(name)+"/update", map[string]any{"name": name, "level": level, "description": "edited"})
+	}
+
+	// the roles at or above the requester are out of reach
+	for _, name := range []string{"资深助理", testAdminRole} {
+		update(name, 1).expect(t, http.StatusForbidden, "role_target_level")
+		c.post(rolePath(name)+"/update-permissions", map[string]any{"permissions": []string{}}).expect(t, http.StatusForbidden, "role_target_level")
+		c.request(http.MethodDelete, rolePath(name), nil, nil).expect(t, http.StatusForbidden, "role_target_level")
+	}
+
+	// and so are the levels
+	update("普通助理", 2).expect(t, http.StatusForbidden, "role_target_level")
+	c.post("/roles/", map[string]any{"name": "组长", "level": 2}).expect(t, http.StatusForbidden, "role_target_level")
+	update("普通助理", 1).expect(t, http.StatusOK, "")
+	c.post("/roles/", map[string]any{"name": "实习助理", "level": 1}).expect(t, http.StatusOK, "")
+
+	// only the permissions the requester holds may be granted
+	grant := func(permissions ...string) *testResponse {
+		return c.post(rolePath("普通助理")+"/update-permissions", map[string]any{"permissions": permissions})
+	}
+	grant(models.PermissionUsersManage).expect(t, http.StatusForbidden, "permission_not_held")
+	grant(models.PermissionRolesManage).expect(t, http.StatusOK, "")
+}
+
+func TestSchedulePlanExport(t *testing.T) {
+	app := newTestApp(t, nil)
+	ctx := context.Background()
+
+	st := &models.ScheduleTemplate{
+		Name: "weekdays",
+		Shifts: []*models.ScheduleTemplateShift{{
+			StartTime:          models.TimeOfDay(8 * time.Hour),
+			EndTime:            models.TimeOfDay(10 * time.Hour),
+			RequiredAssistants: 1,
+			ApplicableDays:     []int32{1},
+		}},
+	}
+	if err := app.models.InsertScheduleTemplate(ctx, st); err != nil {
+		t.Fatal(err)
+	}
+	sp := &models.SchedulePlan{
+		Name:                 "autumn",
+		SubmissionStartTime:  time.Now(),
+		SubmissionEndTime:    time.Now().Add(time.Hour),
+		ActiveStartTime:      time.Now().Add(2 * time.Hour),
+		ActiveEndTime:        time.Now().Add(3 * time.Hour),
+		ScheduleTemplateName: st.Name,
+	}
+	if err := app.models.InsertSchedulePlan(ctx, sp); err != nil {
+		t.Fatal(err)
+	}
+	// a name a spreadsheet would run
+	mallory := app.createUser(t, "mallory", "普通助理")
+	mallory.FullName = `=HYPERLINK("https://evil.example.com","mallory")`
+	if err := app.models.UpdateUser(ctx, mallory); err != nil {
+		t.Fatal(err)
+	}
+
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+	planPath := "/schedule-plans/" + sp.ID.String()
+	c.request(http.MethodPut, planPath+"/assignments", map[string]any{
+		"assignments": []map[string]any{{"scheduleTemplateShiftId": st.Shifts[0].ID, "dayOfWeek": 1, "userId": mallory.ID}},
+	}, nil).expect(t, http.StatusOK, "")
+
+	// drafts are not exported
+	c.get(planPath+"/export").expect(t, http.StatusConflict, "schedule_plan_not_published")
+
+	c.post(planPath+"/publish", nil).expect(t, http.StatusOK, "")
+	c.post(planPath+"/publish", nil).expect(t, http.StatusConflict, "schedule_plan_already_published")
+
+	res, err := c.client.Get(app.server.URL + planPath + "/export?format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", res.StatusCode, body)
+	}
+	if want := `08:00-10:00,"'=HYPERLINK(""https://evil.example.com"",""mallory"")",-`; !strings.Contains(string(body), want) {
+		t.Fatalf("got %s, want a row %s", body, want)
+	}
+}
+
+func TestCreateUserWithoutBroker(t *testing.T) {
+	// newTestApp points RabbitMQ at a host that does not exist, so the app
+	// only sets up because STORAGE=memory logs the mails
+	app := newTestApp(t, nil)
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+
+	c.post("/users/", map[string]string{
+		"username": "alice",
+		"email":    "alice@example.com",
+		"fullName": "Alice",
+		"role":     "普通助理",
+	}).expect(t, http.StatusOK, "")
+}
+
+func TestImportUsers(t *testing.T) {
+	app := newTestApp(t, nil)
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+
+	csv := "username,email,fullName,role\nalice,alice@example.com,Alice,普通助理\n"
+	c.upload("/users/import?dryRun=true", csv).expect(t, http.StatusOK, "")
+
+	c.post("/users/import", nil).expect(t, http.StatusBadRequest, "csv_file_missing")
+
+	// a file over the limit is cut off while the form is read
+	large := csv + strings.Repeat("bob,bob@example.com,Bob,普通助理\n", 1<<15)
+	c.upload("/users/import", large).expect(t, http.StatusRequestEntityTooLarge, "csv_file_too_large")
+}
+
+func TestPasswordChangeRevokesAPITokens(t *testing.T) {
+	app := newTestApp(t, nil)
+	c := app.newClient(t)
+	c.login(testAdminUsername)
+
+	var created struct {
+		Token string `json:"token"`
+	}
+	c.post("/me/tokens/", map[string]string{"name": "script"}).decode(t, &created)
+	bearer := http.Header{"Authorization": {"Bearer " + created.Token}}
+
+	script := app.newClient(t)
+	script.request(http.MethodGet, "/me/", nil, bearer).expect(t, http.StatusOK, "")
+
+	c.post("/me/update-password", map[string]string{
+		"oldPassword": testAdminPassword,
+		"newPassword": "another correct horse battery staple",
+	}).expect(t, http.StatusOK, "")
+
+	// a leaked token is locked out together with the old password, while
+	// the session that changed it stays
+	script.request(http.MethodGet, "/me/", nil, bearer).expect(t, http.StatusUnauthorized, "invalid_token")
+	c.get("/me/").expect(t, http.StatusOK, "")
+}
@@ -1,23 +1,86 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	StoragePostgres = "postgres"
+	// StorageMemory keeps everything in memory, for development without a
+	// database
+	StorageMemory = "memory"
+)
+
+// minJWTSecretLength is the size of the key that is derived from JWT_SECRET.
+const minJWTSecretLength = 32
+
 type Config struct {
 	logger *slog.Logger
 
 	Environment string
 	ServerPort  int
 	JWTSecret   string
+	AppBaseURL  string
+
+	// JWTSecretPrevious is the JWTSecret before the last change. It only
+	// opens the keys sealed under it, which are then sealed again.
+	JWTSecretPrevious string
+
+	// JWTSecret encrypts the private keys of the JWT keyset at rest
+	JWT struct {
+		Algorithm        string
+		RotationInterval time.Duration
+	}
+
+	LocalLoginEnabled bool
+
+	OIDC struct {
+		IssuerURL    string
+		ClientID     string
+		ClientSecret string
+		RedirectURL  string
+		Scopes       []string
+		Claim        string
+		UserField    string
+	}
+
+	Cookie struct {
+		Secure   bool
+		SameSite http.SameSite
+		Domain   string
+	}
+
+	CORS struct {
+		AllowedOrigins []string
+	}
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+	// X-Real-IP headers name the client
+	TrustedProxies []netip.Prefix
+
+	Storage string
 
 	Postgres struct {
 		User     string
 		Password string
 		DB       string
 		Host     string
+
+		QueryTimeout time.Duration
+		BatchTimeout time.Duration
+		TxTimeout    time.Duration
+
+		MaxConns        int32
+		MinConns        int32
+		MaxConnIdleTime time.Duration
 	}
 
 	RabbitMQ struct {
@@ -32,11 +95,46 @@ type Config struct {
 		Password string
 	}
 
+	Password struct {
+		Argon2Memory      int
+		Argon2Iterations  int
+		Argon2Parallelism int
+		MinLength         int
+		MinClasses        int
+	}
+
+	Invitation struct {
+		TokenTTL time.Duration
+	}
+
+	PasswordReset struct {
+		TokenTTL         time.Duration
+		Window           time.Duration
+		MaxEmailRequests int
+		MaxIPRequests    int
+	}
+
+	LoginThrottle struct {
+		Window              time.Duration
+		Lockout             time.Duration
+		MaxUsernameFailures int
+		MaxIPFailures       int
+	}
+
+	TwoFactor struct {
+		RequiredLevel int
+	}
+
+	GraduationReminder struct {
+		Months int
+	}
+
 	InitialAdmin struct {
 		Username string
 		FullName string
 		Email    string
 		Password string
+		Role     string
 	}
 }
 
@@ -48,12 +146,134 @@ func ReadConfig(logger *slog.Logger) (*Config, error) {
 	cfg.Environment = cfg.readStringEnv("ENVIRONMENT")
 	cfg.ServerPort = cfg.readIntEnv("API_SERVER_PORT")
 	cfg.JWTSecret = cfg.readStringEnv("JWT_SECRET")
+	cfg.JWTSecretPrevious = os.Getenv("JWT_SECRET_PREVIOUS")
+	cfg.AppBaseURL = cfg.readStringEnv("APP_BASE_URL")
+
+	// the secret is the key encrypting the signing keys, so it has to be
+	// as strong as one
+	if len(cfg.JWTSecret) < minJWTSecretLength {
+		return nil, fmt.Errorf("JWT_SECRET must be at least %d bytes long", minJWTSecretLength)
+	}
+
+	// jwt keyset
+	cfg.JWT.Algorithm = os.Getenv("JWT_ALGORITHM")
+	if cfg.JWT.Algorithm != "RS256" {
+		cfg.JWT.Algorithm = "EdDSA"
+	}
+	cfg.JWT.RotationInterval = time.Duration(cfg.readIntEnv("JWT_KEY_ROTATION_DAYS")) * 24 * time.Hour
+	if cfg.JWT.RotationInterval <= 0 {
+		cfg.JWT.RotationInterval = 30 * 24 * time.Hour
+	}
+
+	// local password login stays on unless explicitly turned off
+	cfg.LocalLoginEnabled = os.Getenv("LOCAL_LOGIN_ENABLED") != "false"
+
+	// oidc, which is disabled while the issuer is empty
+	cfg.OIDC.IssuerURL = os.Getenv("OIDC_ISSUER_URL")
+	cfg.OIDC.ClientID = os.Getenv("OIDC_CLIENT_ID")
+	cfg.OIDC.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	cfg.OIDC.RedirectURL = os.Getenv("OIDC_REDIRECT_URL")
+	if cfg.OIDC.RedirectURL == "" {
+		cfg.OIDC.RedirectURL = strings.TrimSuffix(cfg.AppBaseURL, "/") + "/api/auth/oidc/callback"
+	}
+	cfg.OIDC.Scopes = strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(cfg.OIDC.Scopes) == 0 {
+		cfg.OIDC.Scopes = []string{"openid", "profile", "email"}
+	}
+	cfg.OIDC.Claim = os.Getenv("OIDC_CLAIM")
+	if cfg.OIDC.Claim == "" {
+		cfg.OIDC.Claim = "email"
+	}
+	cfg.OIDC.UserField = os.Getenv("OIDC_USER_FIELD")
+	if cfg.OIDC.UserField != "student_id" {
+		cfg.OIDC.UserField = "email"
+	}
+
+	// cookie, which is only marked secure in production unless overridden
+	cfg.Cookie.Secure = cfg.Environment == "production"
+	switch os.Getenv("COOKIE_SECURE") {
+	case "true":
+		cfg.Cookie.Secure = true
+	case "false":
+		cfg.Cookie.Secure = false
+	}
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "strict":
+		cfg.Cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		// browsers drop SameSite=None cookies that are not secure
+		cfg.Cookie.SameSite = http.SameSiteNoneMode
+		cfg.Cookie.Secure = true
+	default:
+		cfg.Cookie.SameSite = http.SameSiteLaxMode
+	}
+	cfg.Cookie.Domain = os.Getenv("COOKIE_DOMAIN")
+
+	// cors, allowing only the frontend unless configured otherwise
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.CORS.AllowedOrigins = append(cfg.CORS.AllowedOrigins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if len(cfg.CORS.AllowedOrigins) == 0 && cfg.AppBaseURL != "" {
+		cfg.CORS.AllowedOrigins = []string{strings.TrimSuffix(cfg.AppBaseURL, "/")}
+	}
+
+	// trusted proxies, as addresses or CIDR ranges
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix.Masked())
+	}
+
+	// storage
+	cfg.Storage = os.Getenv("STORAGE")
+	if cfg.Storage != StorageMemory {
+		cfg.Storage = StoragePostgres
+	}
+	if cfg.Storage == StorageMemory && cfg.Environment == "production" {
+		return nil, errors.New("in-memory storage cannot be used in production")
+	}
 
 	// postgres
 	cfg.Postgres.User = cfg.readStringEnv("POSTGRES_USER")
 	cfg.Postgres.Password = cfg.readStringEnv("POSTGRES_PASSWORD")
 	cfg.Postgres.DB = cfg.readStringEnv("POSTGRES_DB")
 	cfg.Postgres.Host = cfg.readStringEnv("POSTGRES_HOST")
+	cfg.Postgres.QueryTimeout = time.Duration(cfg.readIntEnv("POSTGRES_QUERY_TIMEOUT_SECONDS")) * time.Second
+	if cfg.Postgres.QueryTimeout <= 0 {
+		cfg.Postgres.QueryTimeout = 5 * time.Second
+	}
+	cfg.Postgres.BatchTimeout = time.Duration(cfg.readIntEnv("POSTGRES_BATCH_TIMEOUT_SECONDS")) * time.Second
+	if cfg.Postgres.BatchTimeout <= 0 {
+		cfg.Postgres.BatchTimeout = 10 * time.Second
+	}
+	cfg.Postgres.TxTimeout = time.Duration(cfg.readIntEnv("POSTGRES_TX_TIMEOUT_SECONDS")) * time.Second
+	if cfg.Postgres.TxTimeout <= 0 {
+		cfg.Postgres.TxTimeout = 15 * time.Second
+	}
+	cfg.Postgres.MaxConns = int32(cfg.readIntEnv("POSTGRES_MAX_CONNS"))
+	if cfg.Postgres.MaxConns <= 0 {
+		cfg.Postgres.MaxConns = 25
+	}
+	cfg.Postgres.MinConns = int32(cfg.readIntEnv("POSTGRES_MIN_CONNS"))
+	if cfg.Postgres.MinConns < 0 || cfg.Postgres.MinConns > cfg.Postgres.MaxConns {
+		return nil, errors.New("POSTGRES_MIN_CONNS must be between 0 and POSTGRES_MAX_CONNS")
+	}
+	cfg.Postgres.MaxConnIdleTime = time.Duration(cfg.readIntEnv("POSTGRES_MAX_CONN_IDLE_MINUTES")) * time.Minute
+	if cfg.Postgres.MaxConnIdleTime <= 0 {
+		cfg.Postgres.MaxConnIdleTime = 15 * time.Minute
+	}
 
 	// rabbitmq
 	cfg.RabbitMQ.Host = cfg.readStringEnv("RABBITMQ_HOST")
@@ -65,11 +285,86 @@ func ReadConfig(logger *slog.Logger) (*Config, error) {
 	cfg.MailClient.Sender = cfg.readStringEnv("MAIL_CLIENT_SENDER")
 	cfg.MailClient.Password = cfg.readStringEnv("MAIL_CLIENT_PASSWORD")
 
+	// Password
+	cfg.Password.Argon2Memory = cfg.readIntEnv("PASSWORD_ARGON2_MEMORY_KIB")
+	if cfg.Password.Argon2Memory <= 0 {
+		cfg.Password.Argon2Memory = 64 * 1024
+	}
+	cfg.Password.Argon2Iterations = cfg.readIntEnv("PASSWORD_ARGON2_ITERATIONS")
+	if cfg.Password.Argon2Iterations <= 0 {
+		cfg.Password.Argon2Iterations = 3
+	}
+	cfg.Password.Argon2Parallelism = cfg.readIntEnv("PASSWORD_ARGON2_PARALLELISM")
+	if cfg.Password.Argon2Parallelism <= 0 || cfg.Password.Argon2Parallelism > 255 {
+		cfg.Password.Argon2Parallelism = 2
+	}
+	cfg.Password.MinLength = cfg.readIntEnv("PASSWORD_MIN_LENGTH")
+	if cfg.Password.MinLength <= 0 {
+		cfg.Password.MinLength = 8
+	}
+	cfg.Password.MinClasses = cfg.readIntEnv("PASSWORD_MIN_CLASSES")
+	if cfg.Password.MinClasses <= 0 || cfg.Password.MinClasses > 4 {
+		cfg.Password.MinClasses = 2
+	}
+
+	// Invitation
+	cfg.Invitation.TokenTTL = time.Duration(cfg.readIntEnv("INVITATION_TOKEN_TTL_HOURS")) * time.Hour
+	if cfg.Invitation.TokenTTL <= 0 {
+		cfg.Invitation.TokenTTL = 72 * time.Hour
+	}
+
+	// Password Reset
+	cfg.PasswordReset.TokenTTL = time.Duration(cfg.readIntEnv("PASSWORD_RESET_TOKEN_TTL_MINUTES")) * time.Minute
+	if cfg.PasswordReset.TokenTTL <= 0 {
+		cfg.PasswordReset.TokenTTL = 30 * time.Minute
+	}
+	cfg.PasswordReset.Window = time.Duration(cfg.readIntEnv("PASSWORD_RESET_WINDOW_MINUTES")) * time.Minute
+	if cfg.PasswordReset.Window <= 0 {
+		cfg.PasswordReset.Window = time.Hour
+	}
+	cfg.PasswordReset.MaxEmailRequests = cfg.readIntEnv("PASSWORD_RESET_MAX_EMAIL_REQUESTS")
+	if cfg.PasswordReset.MaxEmailRequests <= 0 {
+		cfg.PasswordReset.MaxEmailRequests = 3
+	}
+	cfg.PasswordReset.MaxIPRequests = cfg.readIntEnv("PASSWORD_RESET_MAX_IP_REQUESTS")
+	if cfg.PasswordReset.MaxIPRequests <= 0 {
+		cfg.PasswordReset.MaxIPRequests = 20
+	}
+
+	// Login Throttle
+	cfg.LoginThrottle.Window = time.Duration(cfg.readIntEnv("LOGIN_FAILURE_WINDOW_MINUTES")) * time.Minute
+	if cfg.LoginThrottle.Window <= 0 {
+		cfg.LoginThrottle.Window = 15 * time.Minute
+	}
+	cfg.LoginThrottle.Lockout = time.Duration(cfg.readIntEnv("LOGIN_LOCKOUT_MINUTES")) * time.Minute
+	if cfg.LoginThrottle.Lockout <= 0 {
+		cfg.LoginThrottle.Lockout = 15 * time.Minute
+	}
+	cfg.LoginThrottle.MaxUsernameFailures = cfg.readIntEnv("LOGIN_MAX_USERNAME_FAILURES")
+	if cfg.LoginThrottle.MaxUsernameFailures <= 0 {
+		cfg.LoginThrottle.MaxUsernameFailures = 5
+	}
+	cfg.LoginThrottle.MaxIPFailures = cfg.readIntEnv("LOGIN_MAX_IP_FAILURES")
+	if cfg.LoginThrottle.MaxIPFailures <= 0 {
+		cfg.LoginThrottle.MaxIPFailures = 50
+	}
+
+	// Two Factor
+	cfg.TwoFactor.RequiredLevel = cfg.readIntEnv("TWO_FACTOR_REQUIRED_ROLE_LEVEL")
+
+	// Graduation Reminder, looking 6 months ahead unless set, and disabled
+	// when set to 0
+	cfg.GraduationReminder.Months = 6
+	if os.Getenv("GRADUATION_REMINDER_MONTHS") != "" {
+		cfg.GraduationReminder.Months = cfg.readIntEnv("GRADUATION_REMINDER_MONTHS")
+	}
+
 	// Initial Admin
 	cfg.InitialAdmin.Username = cfg.readStringEnv("INITIAL_ADMIN_USERNAME")
 	cfg.InitialAdmin.FullName = cfg.readStringEnv("INITIAL_ADMIN_FULLNAME")
 	cfg.InitialAdmin.Email = cfg.readStringEnv("INITIAL_ADMIN_EMAIL")
 	cfg.InitialAdmin.Password = cfg.readStringEnv("INITIAL_ADMIN_PASSWORD")
+	cfg.InitialAdmin.Role = cfg.readStringEnv("INITIAL_ADMIN_ROLE")
 
 	return cfg, nil
 }
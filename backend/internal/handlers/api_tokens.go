@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+const (
+	// apiTokenPrefix makes leaked tokens easy to recognise in logs and scans
+	apiTokenPrefix        = "esm_"
+	apiTokenDefaultDays   = 30
+	apiTokenMaxDays       = 365
+	apiTokenMaxNameLength = 100
+)
+
+func (h *Handlers) GetMyAPITokens(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("GetMyAPITokens should be used after GetRequesterMiddleware")
+	}
+
+	tokens, err := h.models.SelectActiveAPITokensByUserID(r.Context(), requester.ID)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "api_tokens_listed", tokens)
+}
+
+func (h *Handlers) CreateMyAPIToken(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("CreateMyAPIToken should be used after GetRequesterMiddleware")
+	}
+
+	var payload struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.ExpiresInDays == 0 {
+		payload.ExpiresInDays = apiTokenDefaultDays
+	}
+	switch {
+	case payload.Name == "":
+		h.errorResponse(w, r, invalidField("name", "api_token_name_required"))
+		return
+	case utf8.RuneCountInString(payload.Name) > apiTokenMaxNameLength:
+		h.errorResponse(w, r, invalidField("name", "api_token_name_too_long", apiTokenMaxNameLength))
+		return
+	case payload.ExpiresInDays < 1 || payload.ExpiresInDays > apiTokenMaxDays:
+		h.errorResponse(w, r, invalidField("expiresInDays", "api_token_expiry_invalid", apiTokenMaxDays))
+		return
+	}
+
+	// a token can never grant more than the role of its owner
+	scopes := make([]string, 0, len(payload.Scopes))
+	for _, scope := range payload.Scopes {
+		if !slices.Contains(requester.Permissions, scope) {
+			h.errorResponse(w, r, forbidden("api_token_scope_forbidden", scope).withField("scopes"))
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := utils.GenerateToken()
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	plaintext := apiTokenPrefix + secret
+
+	token := &models.APIToken{
+		UserID:      requester.ID,
+		Name:        payload.Name,
+		TokenPrefix: plaintext[:len(apiTokenPrefix)+6],
+		TokenHash:   utils.HashToken(plaintext),
+		Scopes:      scopes,
+		ExpiresAt:   time.Now().AddDate(0, 0, payload.ExpiresInDays),
+	}
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.InsertAPIToken(r.Context(), token); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeCreateAPIToken, models.AuditTargetAPIToken, token.ID.String(), nil, token)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	// the plaintext is only ever shown here
+	h.successResponse(w, r, "api_token_created", map[string]any{
+		"token":    plaintext,
+		"apiToken": token,
+	})
+}
+
+func (h *Handlers) RevokeMyAPIToken(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("RevokeMyAPIToken should be used after GetRequesterMiddleware")
+	}
+
+	tokenID, err := uuid.Parse(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		h.errorResponse(w, r, badRequest("invalid_api_token_id"))
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.RevokeAPIToken(r.Context(), requester.ID, tokenID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeRevokeAPIToken, models.AuditTargetAPIToken, tokenID.String(), nil, nil)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, notFound("api_token_not_found"))
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.successResponse(w, r, "api_token_revoked", nil)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// recordAuditEvent writes an audit event for the current requester using m, so
+// that it commits or rolls back together with the change it describes.
+func (h *Handlers) recordAuditEvent(m models.Store, r *http.Request, action, targetType, targetID string, before, after any) error {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		return errors.New("recordAuditEvent must be used after GetRequesterMiddleware")
+	}
+
+	return h.recordAuditEventAs(m, r, requester, action, targetType, targetID, before, after)
+}
+
+// recordAuditEventAs is recordAuditEvent for routes without a requester, such
+// as password resets, where the actor is identified some other way.
+func (h *Handlers) recordAuditEventAs(m models.Store, r *http.Request, actor *models.User, action, targetType, targetID string, before, after any) error {
+	event := &models.AuditEvent{
+		ActorID:       actor.ID,
+		ActorUsername: actor.Username,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		UserAgent:     r.UserAgent(),
+		RequestMethod: r.Method,
+		RequestPath:   r.URL.Path,
+	}
+	if impersonator, ok := r.Context().Value(impersonatorCtxKey).(*models.User); ok {
+		event.ImpersonatorID = &impersonator.ID
+		event.ImpersonatorUsername = &impersonator.Username
+	}
+
+	var err error
+	if before != nil {
+		if event.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if event.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+
+	event.IPAddress = h.clientIP(r)
+
+	return m.InsertAuditEvent(r.Context(), event)
+}
+
+// clientIP returns the address of the client. Requests relayed by a trusted
+// proxy name it in X-Forwarded-For, read from the right so that addresses
+// made up by the client are skipped, or else in X-Real-IP.
+func (h *Handlers) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	ip = ip.Unmap()
+	if !h.trustedProxy(ip) {
+		return ip.String()
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0 && h.trustedProxy(ip); i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			ip = hop.Unmap()
+		}
+		return ip.String()
+	}
+
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+	return ip.String()
+}
+
+func (h *Handlers) trustedProxy(ip netip.Addr) bool {
+	for _, prefix := range h.config.TrustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handlers) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := &models.AuditEventFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		TargetID:   query.Get("targetId"),
+	}
+
+	if actorIDParam := query.Get("actorId"); actorIDParam != "" {
+		actorID, err := uuid.Parse(actorIDParam)
+		if err != nil {
+			h.errorResponse(w, r, badRequest("invalid_actor_id"))
+			return
+		}
+		filter.ActorID = &actorID
+	}
+	if fromParam := query.Get("from"); fromParam != "" {
+		from, err := time.Parse(time.RFC3339, fromParam)
+		if err != nil {
+			h.errorResponse(w, r, badRequest("invalid_from"))
+			return
+		}
+		filter.From = &from
+	}
+	if toParam := query.Get("to"); toParam != "" {
+		to, err := time.Parse(time.RFC3339, toParam)
+		if err != nil {
+			h.errorResponse(w, r, badRequest("invalid_to"))
+			return
+		}
+		filter.To = &to
+	}
+
+	page, pageSize := 1, 20
+	if pageParam := query.Get("page"); pageParam != "" {
+		p, err := strconv.Atoi(pageParam)
+		if err != nil || p < 1 {
+			h.errorResponse(w, r, badRequest("invalid_page"))
+			return
+		}
+		page = p
+	}
+	if pageSizeParam := query.Get("pageSize"); pageSizeParam != "" {
+		ps, err := strconv.Atoi(pageSizeParam)
+		if err != nil || ps < 1 || ps > 100 {
+			h.errorResponse(w, r, badRequest("invalid_page_size"))
+			return
+		}
+		pageSize = ps
+	}
+	filter.Limit = pageSize
+	filter.Offset = (page - 1) * pageSize
+
+	events, total, err := h.models.SelectAuditEvents(r.Context(), filter)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "audit_events_listed", map[string]any{
+		"events":   events,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
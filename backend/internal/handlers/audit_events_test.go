@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+)
+
+func TestClientIP(t *testing.T) {
+	h := &Handlers{config: &config.Config{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"direct", "203.0.113.7:1234", "", "", "203.0.113.7"},
+		{"untrusted peer sending headers", "203.0.113.7:1234", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed hop before the proxy", "10.0.0.1:1234", "192.0.2.9, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:1234", "198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"malformed hop", "10.0.0.1:1234", "garbage, 10.0.0.2", "", "10.0.0.2"},
+		{"real ip", "10.0.0.1:1234", "", "198.51.100.1", "198.51.100.1"},
+		{"ipv4 mapped peer", "[::ffff:203.0.113.7]:1234", "", "", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := h.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
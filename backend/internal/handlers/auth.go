@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+const (
+	tokenCookieName = "__ecnc_shift_manager_token"
+	sessionTTL      = 24 * time.Hour
 )
 
 func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
@@ -23,79 +29,209 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 
 	// check the payload
 	switch {
+	case !h.config.LocalLoginEnabled:
+		h.errorResponse(w, r, forbidden("password_login_disabled"))
+		return
 	case payload.Username == "":
-		h.errorResponse(w, r, errors.New("用户名为空"))
+		h.errorResponse(w, r, invalidField("username", "username_required"))
 		return
 	case payload.Password == "":
-		h.errorResponse(w, r, errors.New("密码为空"))
+		h.errorResponse(w, r, invalidField("password", "password_required"))
 		return
 	}
 
-	// get the user
-	user, err := h.models.SelectUserByUsername(payload.Username)
-	if err != nil {
+	// refuse attempts while the username or the client is throttled
+	ip := h.clientIP(r)
+	if err := h.checkLoginThrottle(r.Context(), payload.Username, ip); err != nil {
+		var throttledErr *loginThrottledError
 		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			h.errorResponse(w, r, errors.New("用户名不存在或密码错误"))
-			return
+		case errors.As(err, &throttledErr):
+			h.throttledResponse(w, r, throttledErr)
 		default:
 			h.internalServerError(w, r, err)
-			return
 		}
+		return
 	}
 
-	// check the password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.Password)); err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			h.errorResponse(w, r, errors.New("用户名不存在或密码错误"))
+	// get the user and check the password, where pending users have not
+	// set a password yet
+	user, err := h.models.SelectUserByUsername(r.Context(), payload.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.internalServerError(w, r, err)
+		return
+	}
+	match := false
+	if err == nil && user.PasswordHash != "" {
+		var needsRehash bool
+		match, needsRehash, err = h.passwords.Verify(payload.Password, user.PasswordHash)
+		if err != nil {
+			h.internalServerError(w, r, err)
 			return
-		default:
+		}
+		if match && needsRehash {
+			h.rehashPassword(r, user, payload.Password)
+		}
+	} else {
+		// take as long as a real check so that unknown usernames do not
+		// answer faster
+		h.passwords.VerifyDummy(payload.Password)
+	}
+	if !match {
+		if err := h.recordLoginFailure(r.Context(), payload.Username, ip); err != nil {
 			h.internalServerError(w, r, err)
 			return
 		}
+		h.errorResponse(w, r, unauthorized("invalid_credentials"))
+		return
 	}
 
-	// create jwt
-	expiresAt := time.Now().Add(24 * time.Hour) // expires in one day
+	// check the account is active
+	if user.Status != models.UserStatusActive {
+		h.errorResponse(w, r, forbidden("account_deactivated"))
+		return
+	}
 
-	claims := jwt.RegisteredClaims{
-		Subject:   user.Username,
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	// users with 2FA enabled get a partial token to exchange in Login2FA
+	if user.TwoFactorEnabled {
+		partialToken, err := h.issuePartialToken(r.Context(), user)
+		if err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
+		h.successResponse(w, r, "second_factor_requested", map[string]any{
+			"twoFactorRequired": true,
+			"partialToken":      partialToken,
+		})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString([]byte(h.config.JWTSecret))
-	if err != nil {
+	h.completeLogin(w, r, user)
+}
+
+// completeLogin starts a session for a user whose credentials have all been
+// checked.
+func (h *Handlers) completeLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if err := h.establishSession(w, r, user); err != nil {
 		h.internalServerError(w, r, err)
 		return
 	}
 
-	// set the jwt in the http-only cookie
-	cookie := &http.Cookie{
-		Name:     "__ecnc_shift_manager_token",
-		Value:    ss,
-		Path:     "/",
-		Expires:  expiresAt,
-		HttpOnly: true,
+	// response
+	h.successResponse(w, r, "logged_in", user)
+}
+
+func (h *Handlers) establishSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	// a successful login clears the failures of the username but not of the
+	// client, so one valid account cannot be used to reset the ip counter
+	if err := h.models.DeleteLoginAttempt(r.Context(), models.LoginScopeUsername, user.Username); err != nil {
+		return err
 	}
-	http.SetCookie(w, cookie)
 
-	// response
-	h.successResponse(w, r, "登录成功", user)
+	// get the permissions
+	var err error
+	user.Permissions, err = h.models.SelectPermissionsByRoleName(r.Context(), user.Role)
+	if err != nil {
+		return err
+	}
+	user.TwoFactorRequired = h.twoFactorRequired(user)
+
+	return h.startSession(w, r, user)
+}
+
+func (h *Handlers) GetAuthMethods(w http.ResponseWriter, r *http.Request) {
+	h.successResponse(w, r, "auth_methods_retrieved", map[string]bool{
+		"password": h.config.LocalLoginEnabled,
+		"oidc":     h.config.OIDC.IssuerURL != "",
+	})
 }
 
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
-	// remove the token from the http-only cookie
-	cookie := &http.Cookie{
-		Name:    "__ecnc_shift_manager_token",
-		Value:   "",
-		Path:    "/",
-		Expires: time.Now().Add(-time.Hour),
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("Logout should be used after GetRequesterMiddleware")
 	}
-	http.SetCookie(w, cookie)
+	session, ok := r.Context().Value(sessionCtxKey).(*models.Session)
+	if !ok {
+		panic("Logout should be used after GetRequesterMiddleware")
+	}
+
+	// revoke the session so the token cannot be reused, and when
+	// impersonating the session of the admin as well
+	if err := h.models.RevokeSession(r.Context(), requester.ID, session.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.internalServerError(w, r, err)
+		return
+	}
+	if session.ImpersonatorSessionID != nil {
+		if err := h.models.RevokeSession(r.Context(), *session.ImpersonatorID, *session.ImpersonatorSessionID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.clearTokenCookie(w)
 
 	// response
-	h.successResponse(w, r, "登出成功", nil)
+	h.successResponse(w, r, "logged_out", nil)
+}
+
+// startSession records a new session for the user and sets a jwt carrying
+// its ID in the http-only cookie.
+func (h *Handlers) startSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	session := &models.Session{
+		UserID:    user.ID,
+		IPAddress: h.clientIP(r),
+		UserAgent: r.UserAgent(),
+		ExpiresAt: time.Now().Add(sessionTTL),
+	}
+	if err := h.models.InsertSession(r.Context(), session); err != nil {
+		return err
+	}
+
+	return h.setSessionCookie(r.Context(), w, session, user.Username)
+}
+
+// setSessionCookie sets a jwt for an existing session of the user.
+func (h *Handlers) setSessionCookie(ctx context.Context, w http.ResponseWriter, session *models.Session, username string) error {
+	// create jwt
+	claims := jwt.RegisteredClaims{
+		ID:        session.ID.String(),
+		Subject:   username,
+		ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	ss, err := h.keys.Sign(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	// set the jwt in the http-only cookie, along with a fresh csrf token
+	http.SetCookie(w, h.newCookie(tokenCookieName, ss, session.ExpiresAt, true))
+
+	return h.setCSRFCookie(w, session.ExpiresAt)
+}
+
+func (h *Handlers) clearTokenCookie(w http.ResponseWriter) {
+	expired := time.Now().Add(-time.Hour)
+	http.SetCookie(w, h.newCookie(tokenCookieName, "", expired, true))
+	http.SetCookie(w, h.newCookie(csrfCookieName, "", expired, false))
+}
+
+// rehashPassword upgrades a bcrypt hash, or an argon2id hash with outdated
+// parameters, once the password is known to be correct. Failing to do so
+// must not fail the login, so errors are only logged.
+func (h *Handlers) rehashPassword(r *http.Request, user *models.User, plaintext string) {
+	newHash, err := h.passwords.Hash(plaintext)
+	if err != nil {
+		h.logInternalServerError(r, err)
+		return
+	}
+
+	if err := h.models.UpdateUserPasswordHash(r.Context(), user.ID, user.PasswordHash, newHash); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logInternalServerError(r, err)
+		}
+		return
+	}
+	user.PasswordHash = newHash
 }
@@ -4,7 +4,11 @@ type contextKey string
 
 const (
 	requesterCtxKey     contextKey = "requester"
+	sessionCtxKey       contextKey = "session"
+	impersonatorCtxKey  contextKey = "impersonator"
+	apiTokenCtxKey      contextKey = "apiToken"
 	userCtxKey          contextKey = "user"
 	scheduleTemplateKey contextKey = "scheduleTemplate"
 	schedulePlanKey     contextKey = "schedulePlan"
+	roleCtxKey          contextKey = "role"
 )
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/password"
+)
+
+// appError is an error meant for the client. Code is a stable identifier
+// that clients can switch on, and names the message shown to the user in
+// the i18n catalogs, formatted with Args. Fields map request fields to the
+// code of what is wrong with them.
+type appError struct {
+	Status int
+	Code   string
+	Args   []any
+	Fields map[string]string
+	// Data goes out as the data of the response, for failures that come
+	// with details such as the rows of an import
+	Data any
+}
+
+func (e *appError) Error() string {
+	return e.message(i18n.Default)
+}
+
+func (e *appError) message(locale string) string {
+	return i18n.T(locale, "error."+e.Code, e.Args...)
+}
+
+func newAppError(status int, code string, args ...any) *appError {
+	return &appError{
+		Status: status,
+		Code:   code,
+		Args:   args,
+	}
+}
+
+func badRequest(code string, args ...any) *appError {
+	return newAppError(http.StatusBadRequest, code, args...)
+}
+
+func unauthorized(code string, args ...any) *appError {
+	return newAppError(http.StatusUnauthorized, code, args...)
+}
+
+func forbidden(code string, args ...any) *appError {
+	return newAppError(http.StatusForbidden, code, args...)
+}
+
+func notFound(code string, args ...any) *appError {
+	return newAppError(http.StatusNotFound, code, args...)
+}
+
+func conflict(code string, args ...any) *appError {
+	return newAppError(http.StatusConflict, code, args...)
+}
+
+func unprocessable(code string, args ...any) *appError {
+	return newAppError(http.StatusUnprocessableEntity, code, args...)
+}
+
+// invalidField reports a bad value of one request field.
+func invalidField(field, code string, args ...any) *appError {
+	return badRequest(code, args...).withField(field)
+}
+
+// withField returns a copy of the error that blames a request field.
+func (e *appError) withField(field string) *appError {
+	c := *e
+	c.Fields = maps.Clone(e.Fields)
+	if c.Fields == nil {
+		c.Fields = make(map[string]string)
+	}
+	c.Fields[field] = e.Code
+	return &c
+}
+
+// withData returns a copy of the error that carries data in the response.
+func (e *appError) withData(data any) *appError {
+	c := *e
+	c.Data = data
+	return &c
+}
+
+var (
+	errInternal     = newAppError(http.StatusInternalServerError, "internal_error")
+	errInvalidJSON  = badRequest("invalid_json")
+	errValidation   = badRequest("validation_failed")
+	errEditConflict = conflict("edit_conflict")
+	errForbidden    = forbidden("permission_denied")
+)
+
+// validationError turns the errors of h.validate into field details, named
+// by their json tags.
+func validationError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	appErr := *errValidation
+	appErr.Fields = make(map[string]string, len(validationErrs))
+	for _, fe := range validationErrs {
+		appErr.Fields[fe.Field()] = fe.Tag()
+	}
+	return &appErr
+}
+
+// passwordPolicyError blames the field holding a password that the policy
+// rejected.
+func passwordPolicyError(field string, err error) error {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return err
+	}
+	return invalidField(field, policyErr.Code, policyErr.Args...)
+}
@@ -2,27 +2,56 @@ package handlers
 
 import (
 	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
 
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/go-playground/validator/v10"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/jwtkeys"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/password"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/workers"
 )
 
 type Handlers struct {
 	config    *config.Config
 	logger    *slog.Logger
-	models    *models.Models
-	emailChan *amqp.Channel
+	models    models.Store
+	mail      workers.MailPublisher
 	validate  *validator.Validate
+	passwords *password.Hasher
+	keys      *jwtkeys.Keyset
+
+	// the oidc provider is discovered on first use, so that the api still
+	// starts while the identity provider is unreachable
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
 }
 
-func New(config *config.Config, logger *slog.Logger, models *models.Models, emailChan *amqp.Channel) *Handlers {
+func New(config *config.Config, logger *slog.Logger, models models.Store, mail workers.MailPublisher, keys *jwtkeys.Keyset) *Handlers {
 	return &Handlers{
 		config:    config,
 		logger:    logger,
 		models:    models,
-		emailChan: emailChan,
-		validate:  validator.New(validator.WithRequiredStructEnabled()),
+		mail:      mail,
+		validate:  newValidator(),
+		passwords: password.New(config),
+		keys:      keys,
 	}
 }
+
+// newValidator names fields by their json tags, which is how clients know
+// them.
+func newValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
+}
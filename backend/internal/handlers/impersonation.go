@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+const (
+	impersonationTTL = 30 * time.Minute
+
+	// impersonatorHeader names the admin behind every response of an
+	// impersonation session
+	impersonatorHeader = "X-Impersonated-By"
+)
+
+// authenticateImpersonator returns the admin behind an impersonation
+// session, which ends as soon as the admin logs out, is deactivated or
+// loses the permission to impersonate.
+func (h *Handlers) authenticateImpersonator(ctx context.Context, session *models.Session) (*models.User, error) {
+	if _, err := h.models.TouchSession(ctx, *session.ImpersonatorSessionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errSessionExpired
+		}
+		return nil, err
+	}
+
+	impersonator, err := h.models.SelectUserByID(ctx, *session.ImpersonatorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errSessionExpired
+		}
+		return nil, err
+	}
+	if impersonator.Status != models.UserStatusActive {
+		return nil, errSessionExpired
+	}
+
+	impersonator.Permissions, err = h.models.SelectPermissionsByRoleName(ctx, impersonator.Role)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(impersonator.Permissions, models.PermissionUsersImpersonate) {
+		return nil, errSessionExpired
+	}
+	impersonator.TwoFactorRequired = h.twoFactorRequired(impersonator)
+
+	return impersonator, nil
+}
+
+// NoImpersonationMiddleware keeps impersonating admins away from the
+// credentials and personal details of the impersonated user.
+func (h *Handlers) NoImpersonationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(impersonatorCtxKey).(*models.User); ok {
+			h.errorResponse(w, r, forbidden("forbidden_while_impersonating"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handlers) ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("ImpersonateUser should be used after GetRequesterMiddleware")
+	}
+	session, ok := r.Context().Value(sessionCtxKey).(*models.Session)
+	if !ok {
+		panic("ImpersonateUser should be used after SessionOnlyMiddleware")
+	}
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		panic("ImpersonateUser should be used after GetUserMiddleware")
+	}
+
+	switch {
+	case user.ID == requester.ID:
+		h.errorResponse(w, r, badRequest("cannot_impersonate_self"))
+		return
+	case user.Status != models.UserStatusActive:
+		h.errorResponse(w, r, badRequest("impersonation_target_inactive"))
+		return
+	}
+
+	// the impersonation never outlives the session of the admin
+	expiresAt := time.Now().Add(impersonationTTL)
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	impersonation := &models.Session{
+		UserID:                user.ID,
+		ImpersonatorID:        &requester.ID,
+		ImpersonatorSessionID: &session.ID,
+		IPAddress:             h.clientIP(r),
+		UserAgent:             r.UserAgent(),
+		ExpiresAt:             expiresAt,
+	}
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.InsertSession(r.Context(), impersonation); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersImpersonate, models.AuditTargetUser, user.ID.String(), nil, map[string]any{
+			"sessionId": impersonation.ID,
+			"expiresAt": expiresAt,
+		})
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	if err := h.setSessionCookie(r.Context(), w, impersonation, user.Username); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	user.ImpersonatedBy = requester.Username
+	w.Header().Set(impersonatorHeader, requester.Username)
+	h.successResponse(w, r, "impersonation_started", user)
+}
+
+// StopImpersonation ends an impersonation session and hands the admin back
+// their own session.
+func (h *Handlers) StopImpersonation(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("StopImpersonation should be used after GetRequesterMiddleware")
+	}
+	session, ok := r.Context().Value(sessionCtxKey).(*models.Session)
+	if !ok {
+		panic("StopImpersonation should be used after SessionOnlyMiddleware")
+	}
+	impersonator, ok := r.Context().Value(impersonatorCtxKey).(*models.User)
+	if !ok {
+		h.errorResponse(w, r, badRequest("not_impersonating"))
+		return
+	}
+
+	var adminSession *models.Session
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.RevokeSession(r.Context(), requester.ID, session.ID); err != nil {
+			return err
+		}
+		var err error
+		if adminSession, err = m.TouchSession(r.Context(), *session.ImpersonatorSessionID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionAuthStopImpersonation, models.AuditTargetUser, requester.ID.String(), nil, nil)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errSessionExpired)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := h.setSessionCookie(r.Context(), w, adminSession, impersonator.Username); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Del(impersonatorHeader)
+	h.successResponse(w, r, "impersonation_stopped", impersonator)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+// createInvitation stores a new invitation for a pending user using m and
+// returns the plaintext token to be mailed once the transaction commits.
+func (h *Handlers) createInvitation(ctx context.Context, m models.Store, user *models.User) (string, error) {
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.InsertUserInvitation(ctx, &models.UserInvitation{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(h.config.Invitation.TokenTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (h *Handlers) publishInvitationMail(user *models.User, token string) error {
+	return h.publishMail(user, user.Locale, "invitation", map[string]any{
+		"FullName": user.FullName,
+		"Username": user.Username,
+		"TTLHours": int(h.config.Invitation.TokenTTL.Hours()),
+		"Link":     h.config.AppBaseURL + "/activate?token=" + url.QueryEscape(token),
+	})
+}
+
+func (h *Handlers) ActivateAccount(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+	switch {
+	case payload.Token == "":
+		h.errorResponse(w, r, invalidField("token", "activation_token_required"))
+		return
+	case payload.Password == "":
+		h.errorResponse(w, r, invalidField("password", "password_required"))
+		return
+	}
+
+	// the username is only known once the token is consumed, so it is
+	// checked again below
+	if err := h.passwords.Validate(payload.Password, ""); err != nil {
+		h.errorResponse(w, r, passwordPolicyError("password", err))
+		return
+	}
+	passwordHash, err := h.passwords.Hash(payload.Password)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	errInvalidToken := badRequest("activation_token_invalid")
+	var user *models.User
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		userID, err := m.ConsumeUserInvitation(r.Context(), utils.HashToken(payload.Token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errInvalidToken
+			}
+			return err
+		}
+
+		user, err = m.SelectUserByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if user.Status != models.UserStatusPending {
+			return errInvalidToken
+		}
+
+		if err := h.passwords.Validate(payload.Password, user.Username); err != nil {
+			return passwordPolicyError("password", err)
+		}
+
+		before := *user
+		user.PasswordHash = passwordHash
+		user.Status = models.UserStatusActive
+		if err := m.UpdateUser(r.Context(), user); err != nil {
+			return err
+		}
+
+		return h.recordAuditEventAs(m, r, user, models.AuditActionAuthActivate, models.AuditTargetUser, user.ID.String(), before, user)
+	}); err != nil {
+		var appErr *appError
+		switch {
+		case errors.As(err, &appErr):
+			h.errorResponse(w, r, err)
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	h.successResponse(w, r, "account_activated", user)
+}
+
+func (h *Handlers) ResendInvitation(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("ResendInvitation must be used after GetUserMiddleware"))
+		return
+	}
+
+	if user.Status != models.UserStatusPending {
+		h.errorResponse(w, r, conflict("user_not_pending"))
+		return
+	}
+
+	var token string
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		var err error
+		token, err = h.createInvitation(r.Context(), m, user)
+		if err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersResendInvitation, models.AuditTargetUser, user.ID.String(), nil, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	if err := h.publishInvitationMail(user, token); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "invitation_resent", nil)
+}
+
+func (h *Handlers) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("RevokeInvitation must be used after GetUserMiddleware"))
+		return
+	}
+
+	if user.Status != models.UserStatusPending {
+		h.errorResponse(w, r, conflict("user_not_pending"))
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		n, err := m.RevokeUserInvitations(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersRevokeInvitation, models.AuditTargetUser, user.ID.String(), nil, nil)
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.errorResponse(w, r, conflict("no_pending_invitation"))
+			return
+		}
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "invitation_revoked", nil)
+}
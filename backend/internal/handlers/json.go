@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
 )
 
 type response struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Data    any    `json:"data"`
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Code    string            `json:"code,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Data    any               `json:"data"`
 }
 
 func (h *Handlers) logInternalServerError(r *http.Request, err error) {
@@ -18,7 +22,7 @@ func (h *Handlers) logInternalServerError(r *http.Request, err error) {
 
 func (h *Handlers) readJSON(r *http.Request, dst any) error {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
-		return err
+		return errInvalidJSON
 	}
 	return nil
 }
@@ -33,23 +37,36 @@ func (h *Handlers) writeJSON(w http.ResponseWriter, r *http.Request, status int,
 	}
 }
 
-func (h *Handlers) successResponse(w http.ResponseWriter, r *http.Request, message string, data any) {
+// successResponse writes the data with the message named by key in the
+// "success" section of the i18n catalogs, formatted with args.
+func (h *Handlers) successResponse(w http.ResponseWriter, r *http.Request, key string, data any, args ...any) {
 	h.writeJSON(w, r, http.StatusOK, response{
 		Success: true,
-		Message: message,
+		Message: i18n.T(h.locale(r), "success."+key, args...),
 		Data:    data,
 	})
 }
 
+// errorResponse writes the status and code of an *appError, with its message
+// in the locale of the request. Any other error has no message for the user,
+// so it is logged and answered as an internal error.
 func (h *Handlers) errorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	h.writeJSON(w, r, http.StatusOK, response{
+	var appErr *appError
+	if !errors.As(err, &appErr) {
+		h.logInternalServerError(r, err)
+		appErr = errInternal
+	}
+
+	h.writeJSON(w, r, appErr.Status, response{
 		Success: false,
-		Message: err.Error(),
-		Data:    nil,
+		Message: appErr.message(h.locale(r)),
+		Code:    appErr.Code,
+		Fields:  appErr.Fields,
+		Data:    appErr.Data,
 	})
 }
 
 func (h *Handlers) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
 	h.logInternalServerError(r, err)
-	h.errorResponse(w, r, errors.New("服务器内部错误"))
+	h.errorResponse(w, r, errInternal)
 }
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// GetJWKS publishes the public keys of the keyset as a plain JWK set rather
+// than in the usual response envelope, since other services consume it.
+func (h *Handlers) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	h.writeJSON(w, r, http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// locale returns the locale of the response: the preference of the
+// requester when they saved one, otherwise what the browser asks for.
+func (h *Handlers) locale(r *http.Request) string {
+	var preference string
+	if requester, ok := r.Context().Value(requesterCtxKey).(*models.User); ok {
+		preference = requester.Locale
+	}
+	return i18n.Negotiate(preference, r.Header.Get("Accept-Language"))
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+const (
+	// failures tolerated before each further attempt has to wait
+	loginDelayAfter = 3
+	loginMaxDelay   = 30 * time.Second
+)
+
+type loginThrottledError struct {
+	wait   time.Duration
+	locked bool
+}
+
+func (e *loginThrottledError) Error() string {
+	return e.appError().Error()
+}
+
+func (e *loginThrottledError) appError() *appError {
+	if e.locked {
+		return newAppError(http.StatusTooManyRequests, "login_locked", int(math.Ceil(e.wait.Minutes())))
+	}
+	return newAppError(http.StatusTooManyRequests, "login_throttled", int(math.Ceil(e.wait.Seconds())))
+}
+
+// checkLoginThrottle returns a *loginThrottledError if the username or the
+// client IP address is locked or still has to wait after its last failure.
+func (h *Handlers) checkLoginThrottle(ctx context.Context, username, ip string) error {
+	for _, key := range []struct{ scope, key string }{
+		{models.LoginScopeUsername, username},
+		{models.LoginScopeIP, ip},
+	} {
+		attempt, err := h.models.SelectLoginAttempt(ctx, key.scope, key.key)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(attempt.Now) {
+			return &loginThrottledError{wait: attempt.LockedUntil.Sub(attempt.Now), locked: true}
+		}
+
+		// double the delay with every failure past loginDelayAfter
+		if attempt.Failures < loginDelayAfter || attempt.Now.Sub(attempt.LastFailureAt) > h.config.LoginThrottle.Window {
+			continue
+		}
+		delay := loginMaxDelay
+		if shift := attempt.Failures - loginDelayAfter; shift < 5 {
+			delay = min(time.Second<<shift, loginMaxDelay)
+		}
+		if wait := attempt.LastFailureAt.Add(delay).Sub(attempt.Now); wait > 0 {
+			return &loginThrottledError{wait: wait}
+		}
+	}
+
+	return nil
+}
+
+func (h *Handlers) recordLoginFailure(ctx context.Context, username, ip string) error {
+	throttle := h.config.LoginThrottle
+	if _, err := h.models.RecordLoginFailure(ctx, models.LoginScopeUsername, username, throttle.Window, throttle.Lockout, throttle.MaxUsernameFailures); err != nil {
+		return err
+	}
+	if _, err := h.models.RecordLoginFailure(ctx, models.LoginScopeIP, ip, throttle.Window, throttle.Lockout, throttle.MaxIPFailures); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (h *Handlers) throttledResponse(w http.ResponseWriter, r *http.Request, err *loginThrottledError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.wait.Seconds()))))
+	h.errorResponse(w, r, err.appError())
+}
+
+func (h *Handlers) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("UnlockUser must be used after GetUserMiddleware"))
+		return
+	}
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("UnlockUser should be used after GetRequesterMiddleware")
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if _, err := m.UnlockLogin(r.Context(), models.LoginScopeUsername, user.Username, requester.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersUnlock, models.AuditTargetUser, user.ID.String(), nil, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "login_lock_released", nil)
+}
+
+func (h *Handlers) GetLoginLockEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := &models.LoginLockEventFilter{
+		Scope:      query.Get("scope"),
+		Key:        query.Get("key"),
+		ActiveOnly: query.Get("active") == "true",
+	}
+	switch filter.Scope {
+	case "", models.LoginScopeUsername, models.LoginScopeIP, models.LoginScopeResetEmail, models.LoginScopeResetIP:
+	default:
+		h.errorResponse(w, r, badRequest("invalid_lock_scope"))
+		return
+	}
+
+	page, pageSize := 1, 20
+	if pageParam := query.Get("page"); pageParam != "" {
+		p, err := strconv.Atoi(pageParam)
+		if err != nil || p < 1 {
+			h.errorResponse(w, r, badRequest("invalid_page"))
+			return
+		}
+		page = p
+	}
+	if pageSizeParam := query.Get("pageSize"); pageSizeParam != "" {
+		ps, err := strconv.Atoi(pageSizeParam)
+		if err != nil || ps < 1 || ps > 100 {
+			h.errorResponse(w, r, badRequest("invalid_limit"))
+			return
+		}
+		pageSize = ps
+	}
+	filter.Limit = pageSize
+	filter.Offset = (page - 1) * pageSize
+
+	events, total, err := h.models.SelectLoginLockEvents(r.Context(), filter)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "login_locks_listed", map[string]any{
+		"events":   events,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// UnlockLoginLockEvent lifts the lock behind an event, which is how locked
+// IP addresses are released.
+func (h *Handlers) UnlockLoginLockEvent(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("UnlockLoginLockEvent should be used after GetRequesterMiddleware")
+	}
+
+	eventID, err := uuid.Parse(chi.URLParam(r, "lockEventID"))
+	if err != nil {
+		h.errorResponse(w, r, badRequest("invalid_lock_event_id"))
+		return
+	}
+
+	event, err := h.models.SelectLoginLockEventByID(r.Context(), eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, notFound("lock_event_not_found"))
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+	if !event.Active {
+		h.errorResponse(w, r, conflict("lock_already_released"))
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if _, err := m.UnlockLogin(r.Context(), event.Scope, event.Key, requester.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionLoginLockEventsUnlock, models.AuditTargetLoginLockEvent, event.ID.String(), event, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "login_lock_released", nil)
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/workers"
+)
+
+// publishMail queues the mail template called name, rendered in the locale,
+// to the user.
+func (h *Handlers) publishMail(user *models.User, locale, name string, data any) error {
+	subject, body, err := i18n.Mail(locale, name, data)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return h.mail.PublishMail(ctx, workers.MailPayload{
+		To:      user.Email,
+		Subject: subject,
+		Body:    body,
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func (h *Handlers) GetMyInfo(w http.ResponseWriter, r *http.Request) {
@@ -15,7 +14,7 @@ func (h *Handlers) GetMyInfo(w http.ResponseWriter, r *http.Request) {
 		panic("GetMyInfoHandler should be used after GetRequesterMiddleware")
 	}
 
-	h.successResponse(w, r, "获取个人信息成功", myInfo)
+	h.successResponse(w, r, "me_retrieved", myInfo)
 }
 
 func (h *Handlers) UpdateMyPassword(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +22,10 @@ func (h *Handlers) UpdateMyPassword(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		panic("UpdateMyPasswordHandler should be used after GetMyInfoHandler")
 	}
+	session, ok := r.Context().Value(sessionCtxKey).(*models.Session)
+	if !ok {
+		panic("UpdateMyPasswordHandler should be used after GetRequesterMiddleware")
+	}
 
 	var payload struct {
 		OldPassword string `json:"oldPassword"`
@@ -34,18 +37,53 @@ func (h *Handlers) UpdateMyPassword(w http.ResponseWriter, r *http.Request) {
 	}
 	switch {
 	case payload.OldPassword == "":
-		h.errorResponse(w, r, errors.New("旧密码为空"))
+		h.errorResponse(w, r, invalidField("oldPassword", "old_password_required"))
 		return
 	case payload.NewPassword == "":
-		h.errorResponse(w, r, errors.New("新密码为空"))
+		h.errorResponse(w, r, invalidField("newPassword", "new_password_required"))
 		return
 	}
 
 	// verify the old password
-	if err := bcrypt.CompareHashAndPassword([]byte(requester.PasswordHash), []byte(payload.OldPassword)); err != nil {
+	match, _, err := h.passwords.Verify(payload.OldPassword, requester.PasswordHash)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	if !match {
+		h.errorResponse(w, r, invalidField("oldPassword", "password_incorrect"))
+		return
+	}
+
+	// update the password
+	if err := h.passwords.Validate(payload.NewPassword, requester.Username); err != nil {
+		h.errorResponse(w, r, passwordPolicyError("newPassword", err))
+		return
+	}
+	newPasswordHash, err := h.passwords.Hash(payload.NewPassword)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	requester.PasswordHash = newPasswordHash
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateUser(r.Context(), requester); err != nil {
+			return err
+		}
+		// keep the current session but log out every other device
+		if _, err := m.RevokeUserSessions(r.Context(), requester.ID, session.ID); err != nil {
+			return err
+		}
+		// a leaked api token must not outlive the password either
+		if _, err := m.RevokeUserAPITokens(r.Context(), requester.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeUpdatePassword, models.AuditTargetUser, requester.ID.String(), nil, nil)
+	}); err != nil {
 		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			h.errorResponse(w, r, errors.New("密码错误"))
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
 			return
 		default:
 			h.internalServerError(w, r, err)
@@ -53,18 +91,37 @@ func (h *Handlers) UpdateMyPassword(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// update the password
-	newPasswordHash, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
-		h.internalServerError(w, r, err)
+	// response
+	h.successResponse(w, r, "password_updated", nil)
+}
+
+func (h *Handlers) UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("UpdateMyProfile should be used after GetRequesterMiddleware")
+	}
+
+	var payload profilePayload
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
 		return
 	}
 
-	requester.PasswordHash = string(newPasswordHash)
-	if err := h.models.UpdateUser(requester); err != nil {
+	before := *requester
+	if err := payload.apply(requester); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateUser(r.Context(), requester); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeUpdateProfile, models.AuditTargetUser, requester.ID.String(), before, requester)
+	}); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			h.errorResponse(w, r, errors.New("发生了数据冲突，请重试"))
+			h.errorResponse(w, r, errEditConflict)
 			return
 		default:
 			h.internalServerError(w, r, err)
@@ -72,6 +129,5 @@ func (h *Handlers) UpdateMyPassword(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// response
-	h.successResponse(w, r, "修改密码成功", nil)
+	h.successResponse(w, r, "profile_updated", requester)
 }
@@ -6,12 +6,16 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
 )
 
 func (h *Handlers) LoggerMiddleware(next http.Handler) http.Handler {
@@ -29,30 +33,73 @@ func (h *Handlers) LoggerMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (h *Handlers) GetRequesterMiddleware(next http.Handler) http.Handler {
+// DeadlineMiddleware cancels the context of a request, and so its queries,
+// once the server could no longer write the response anyway.
+func (h *Handlers) DeadlineMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RecovererMiddleware turns panics into the usual JSON error response.
+func (h *Handlers) RecovererMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// get the token from cookie
-		cookie, err := r.Cookie("__ecnc_shift_manager_token")
-		if err != nil {
-			switch {
-			case errors.Is(err, http.ErrNoCookie):
-				h.errorResponse(w, r, errors.New("用户未登录"))
-				return
-			default:
-				h.internalServerError(w, r, err)
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
 				return
 			}
-		}
+			// the server relies on this panic to abort the response
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
 
-		// parse the token
-		claims := &jwt.RegisteredClaims{}
-		_, err = jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
-			return []byte(h.config.JWTSecret), nil
-		})
+			h.logger.Error(
+				"panic recovered",
+				slog.Any("panic", rvr),
+				slog.String("method", r.Method),
+				slog.String("uri", r.URL.RequestURI()),
+				slog.String("stack", string(debug.Stack())),
+			)
+			h.errorResponse(w, r, errInternal)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+var (
+	errNotLoggedIn    = unauthorized("not_logged_in")
+	errInvalidToken   = unauthorized("invalid_token")
+	errSessionExpired = unauthorized("session_expired")
+)
+
+// GetRequesterMiddleware authenticates the request either with the session
+// cookie or with a personal API token sent as "Authorization: Bearer".
+func (h *Handlers) GetRequesterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var requester *models.User
+		var err error
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			var apiToken *models.APIToken
+			requester, apiToken, err = h.authenticateAPIToken(r.Context(), strings.TrimSpace(bearer))
+			ctx = context.WithValue(ctx, apiTokenCtxKey, apiToken)
+		} else {
+			var session *models.Session
+			requester, session, err = h.authenticateSession(r)
+			ctx = context.WithValue(ctx, sessionCtxKey, session)
+		}
 		if err != nil {
 			switch {
-			case errors.Is(err, http.ErrNoCookie):
-				h.errorResponse(w, r, errors.New("无效的访问令牌"))
+			case errors.Is(err, errNotLoggedIn), errors.Is(err, errInvalidToken), errors.Is(err, errSessionExpired):
+				h.errorResponse(w, r, err)
 				return
 			default:
 				h.internalServerError(w, r, err)
@@ -60,35 +107,134 @@ func (h *Handlers) GetRequesterMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
-		// get the requester details
-		requesterUsername := claims.Subject
-		requester, err := h.models.SelectUserByUsername(requesterUsername)
+		if requester.Status != models.UserStatusActive {
+			h.errorResponse(w, r, forbidden("account_deactivated"))
+			return
+		}
+
+		// get the requester permissions, narrowed to the scopes of the token
+		requester.Permissions, err = h.models.SelectPermissionsByRoleName(r.Context(), requester.Role)
 		if err != nil {
-			switch {
-			case errors.Is(err, sql.ErrNoRows):
-				h.errorResponse(w, r, errors.New("无效的访问令牌"))
-				return
-			default:
-				h.internalServerError(w, r, err)
+			h.internalServerError(w, r, err)
+			return
+		}
+		if apiToken, ok := ctx.Value(apiTokenCtxKey).(*models.APIToken); ok {
+			requester.Permissions = slices.DeleteFunc(requester.Permissions, func(p string) bool {
+				return !slices.Contains(apiToken.Scopes, p)
+			})
+		}
+
+		requester.TwoFactorRequired = h.twoFactorRequired(requester)
+
+		// mark every response of an impersonation session
+		if session, ok := ctx.Value(sessionCtxKey).(*models.Session); ok && session.ImpersonatorID != nil {
+			impersonator, err := h.authenticateImpersonator(r.Context(), session)
+			if err != nil {
+				switch {
+				case errors.Is(err, errSessionExpired):
+					h.errorResponse(w, r, err)
+				default:
+					h.internalServerError(w, r, err)
+				}
 				return
 			}
+			requester.ImpersonatedBy = impersonator.Username
+			w.Header().Set(impersonatorHeader, impersonator.Username)
+			ctx = context.WithValue(ctx, impersonatorCtxKey, impersonator)
 		}
 
-		ctx := context.WithValue(r.Context(), requesterCtxKey, requester)
+		ctx = context.WithValue(ctx, requesterCtxKey, requester)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (h *Handlers) AuthGuardMiddleware(levelRequired int32) func(http.Handler) http.Handler {
+func (h *Handlers) authenticateSession(r *http.Request) (*models.User, *models.Session, error) {
+	// get the token from cookie
+	cookie, err := r.Cookie(tokenCookieName)
+	if err != nil {
+		if errors.Is(err, http.ErrNoCookie) {
+			return nil, nil, errNotLoggedIn
+		}
+		return nil, nil, err
+	}
+
+	// parse the token
+	claims := &jwt.RegisteredClaims{}
+	if err := h.keys.Parse(r.Context(), cookie.Value, claims); err != nil {
+		return nil, nil, errInvalidToken
+	}
+
+	// check the session has not been revoked
+	sessionID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, nil, errInvalidToken
+	}
+	session, err := h.models.TouchSession(r.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errSessionExpired
+		}
+		return nil, nil, err
+	}
+
+	// get the requester details
+	requester, err := h.models.SelectUserByID(r.Context(), session.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errInvalidToken
+		}
+		return nil, nil, err
+	}
+	if requester.Username != claims.Subject {
+		return nil, nil, errInvalidToken
+	}
+
+	return requester, session, nil
+}
+
+func (h *Handlers) authenticateAPIToken(ctx context.Context, token string) (*models.User, *models.APIToken, error) {
+	apiToken, err := h.models.TouchAPIToken(ctx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	requester, err := h.models.SelectUserByID(ctx, apiToken.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	return requester, apiToken, nil
+}
+
+// SessionOnlyMiddleware keeps API tokens away from routes that manage the
+// credentials of the account itself.
+func (h *Handlers) SessionOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(sessionCtxKey).(*models.Session); !ok {
+			h.errorResponse(w, r, forbidden("api_token_not_allowed"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handlers) PermissionGuardMiddleware(permission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
 			if !ok {
-				panic("AuthGuardMiddleware must used after GetRequesterMiddleware")
+				panic("PermissionGuardMiddleware must used after GetRequesterMiddleware")
 			}
 
-			if requester.Level < levelRequired {
-				h.errorResponse(w, r, errors.New("权限不足"))
+			if !slices.Contains(requester.Permissions, permission) {
+				h.errorResponse(w, r, errForbidden)
 				return
 			}
 
@@ -97,20 +243,38 @@ func (h *Handlers) AuthGuardMiddleware(levelRequired int32) func(http.Handler) h
 	}
 }
 
+// TwoFactorGuardMiddleware blocks requesters whose role requires 2FA until
+// they have enabled it.
+func (h *Handlers) TwoFactorGuardMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+		if !ok {
+			panic("TwoFactorGuardMiddleware must used after GetRequesterMiddleware")
+		}
+
+		if requester.TwoFactorRequired && !requester.TwoFactorEnabled {
+			h.errorResponse(w, r, forbidden("two_factor_enrollment_required"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (h *Handlers) GetUserMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userIDParam := chi.URLParam(r, "userID")
 		userID, err := uuid.Parse(userIDParam)
 		if err != nil {
-			h.errorResponse(w, r, errors.New("无效的用户ID"))
+			h.errorResponse(w, r, badRequest("invalid_user_id"))
 			return
 		}
 
-		user, err := h.models.SelectUserByID(userID)
+		user, err := h.models.SelectUserByID(r.Context(), userID)
 		if err != nil {
 			switch {
 			case errors.Is(err, sql.ErrNoRows):
-				h.errorResponse(w, r, errors.New("用户不存在"))
+				h.errorResponse(w, r, notFound("user_not_found"))
 			default:
 				h.internalServerError(w, r, err)
 				return
@@ -122,3 +286,27 @@ func (h *Handlers) GetUserMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// UserLevelGuardMiddleware keeps users.manage to the users below the level
+// of the requester, so that it cannot be used to take over the accounts of
+// the admins above. The requester themselves is left to each handler, which
+// knows whether managing oneself makes sense.
+func (h *Handlers) UserLevelGuardMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+		if !ok {
+			panic("UserLevelGuardMiddleware must used after GetRequesterMiddleware")
+		}
+		user, ok := r.Context().Value(userCtxKey).(*models.User)
+		if !ok {
+			panic("UserLevelGuardMiddleware must used after GetUserMiddleware")
+		}
+
+		if user.ID != requester.ID && user.Level >= requester.Level {
+			h.errorResponse(w, r, forbidden("user_target_level"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
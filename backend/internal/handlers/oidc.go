@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcFlowCookieName = "__ecnc_shift_manager_oidc"
+	oidcFlowAudience   = "oidc_flow"
+	oidcFlowTTL        = 10 * time.Minute
+)
+
+// oidcFlowClaims carry the state of an authorization request between the
+// redirect to the identity provider and the callback, signed so that the
+// client cannot tamper with them.
+type oidcFlowClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	jwt.RegisteredClaims
+}
+
+func (h *Handlers) oidcClient(ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
+	h.oidcMu.Lock()
+	defer h.oidcMu.Unlock()
+
+	if h.oidcProvider == nil {
+		provider, err := oidc.NewProvider(ctx, h.config.OIDC.IssuerURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.oidcProvider = provider
+	}
+
+	return h.oidcProvider, &oauth2.Config{
+		ClientID:     h.config.OIDC.ClientID,
+		ClientSecret: h.config.OIDC.ClientSecret,
+		Endpoint:     h.oidcProvider.Endpoint(),
+		RedirectURL:  h.config.OIDC.RedirectURL,
+		Scopes:       h.config.OIDC.Scopes,
+	}, nil
+}
+
+// oidcFlowCookie is always SameSite=Lax, since the callback is a top-level
+// navigation from the identity provider that a strict cookie would miss.
+func (h *Handlers) oidcFlowCookie(value string, expires time.Time) *http.Cookie {
+	cookie := h.newCookie(oidcFlowCookieName, value, expires, true)
+	if cookie.SameSite != http.SameSiteNoneMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	return cookie
+}
+
+// redirectToApp sends the browser back to a path of the frontend.
+func (h *Handlers) redirectToApp(w http.ResponseWriter, r *http.Request, path string) {
+	http.Redirect(w, r, strings.TrimSuffix(h.config.AppBaseURL, "/")+path, http.StatusFound)
+}
+
+// redirectToLoginWithError shows the message of the error code on the login
+// page.
+func (h *Handlers) redirectToLoginWithError(w http.ResponseWriter, r *http.Request, code string) {
+	message := i18n.T(h.locale(r), "error."+code)
+	h.redirectToApp(w, r, "/auth/login?error="+url.QueryEscape(message))
+}
+
+func (h *Handlers) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.config.OIDC.IssuerURL == "" {
+		h.errorResponse(w, r, notFound("oidc_disabled"))
+		return
+	}
+
+	_, oauth2Config, err := h.oidcClient(r.Context())
+	if err != nil {
+		h.logInternalServerError(r, err)
+		h.redirectToLoginWithError(w, r, "oidc_unavailable")
+		return
+	}
+
+	// only redirect back to paths of the frontend itself
+	redirect := r.URL.Query().Get("redirect")
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		redirect = "/"
+	}
+
+	state, err := utils.GenerateToken()
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	nonce, err := utils.GenerateToken()
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	expiresAt := time.Now().Add(oidcFlowTTL)
+	ss, err := h.keys.Sign(r.Context(), oidcFlowClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		Redirect: redirect,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcFlowAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	http.SetCookie(w, h.oidcFlowCookie(ss, expiresAt))
+
+	authURL := oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *Handlers) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.config.OIDC.IssuerURL == "" {
+		h.errorResponse(w, r, notFound("oidc_disabled"))
+		return
+	}
+
+	// the flow cookie is single use
+	http.SetCookie(w, h.oidcFlowCookie("", time.Now().Add(-time.Hour)))
+
+	const errExpired = "oidc_flow_expired"
+	cookie, err := r.Cookie(oidcFlowCookieName)
+	if err != nil {
+		h.redirectToLoginWithError(w, r, errExpired)
+		return
+	}
+	flow := &oidcFlowClaims{}
+	if err := h.keys.Parse(r.Context(), cookie.Value, flow, jwt.WithAudience(oidcFlowAudience)); err != nil {
+		h.redirectToLoginWithError(w, r, errExpired)
+		return
+	}
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(flow.State)) != 1 {
+		h.redirectToLoginWithError(w, r, errExpired)
+		return
+	}
+	if idpErr := query.Get("error"); idpErr != "" {
+		h.logger.Info("oidc login rejected by identity provider", slog.String("error", idpErr), slog.String("description", query.Get("error_description")))
+		h.redirectToLoginWithError(w, r, "oidc_failed")
+		return
+	}
+
+	provider, oauth2Config, err := h.oidcClient(r.Context())
+	if err != nil {
+		h.logInternalServerError(r, err)
+		h.redirectToLoginWithError(w, r, "oidc_unavailable")
+		return
+	}
+
+	// exchange the code and verify the id token it comes with
+	token, err := oauth2Config.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		h.logInternalServerError(r, err)
+		h.redirectToLoginWithError(w, r, "oidc_failed")
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		h.logInternalServerError(r, errors.New("token response has no id_token"))
+		h.redirectToLoginWithError(w, r, "oidc_failed")
+		return
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: h.config.OIDC.ClientID}).Verify(r.Context(), rawIDToken)
+	if err != nil || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(flow.Nonce)) != 1 {
+		h.logInternalServerError(r, fmt.Errorf("invalid id token: %w", err))
+		h.redirectToLoginWithError(w, r, "oidc_failed")
+		return
+	}
+
+	user, err := h.oidcUser(r.Context(), idToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.redirectToLoginWithError(w, r, "oidc_user_not_linked")
+		case errors.Is(err, errOIDCClaim):
+			h.redirectToLoginWithError(w, r, "oidc_claim_missing")
+		case errors.Is(err, errOIDCEmailUnverified):
+			h.redirectToLoginWithError(w, r, "oidc_email_unverified")
+		default:
+			h.logInternalServerError(r, err)
+			h.redirectToLoginWithError(w, r, "oidc_failed")
+		}
+		return
+	}
+	if user.Status != models.UserStatusActive {
+		h.redirectToLoginWithError(w, r, "account_deactivated")
+		return
+	}
+
+	// 2FA is still asked for, passing the partial token in the fragment so
+	// that it never reaches server logs
+	if user.TwoFactorEnabled {
+		partialToken, err := h.issuePartialToken(r.Context(), user)
+		if err != nil {
+			h.logInternalServerError(r, err)
+			h.redirectToLoginWithError(w, r, "oidc_failed")
+			return
+		}
+		h.redirectToApp(w, r, "/auth/login#partialToken="+url.QueryEscape(partialToken))
+		return
+	}
+
+	if err := h.establishSession(w, r, user); err != nil {
+		h.logInternalServerError(r, err)
+		h.redirectToLoginWithError(w, r, "oidc_failed")
+		return
+	}
+
+	h.redirectToApp(w, r, flow.Redirect)
+}
+
+var (
+	errOIDCClaim           = errors.New("oidc: missing claim")
+	errOIDCEmailUnverified = errors.New("oidc: email not verified")
+)
+
+// oidcUser maps the configured claim of the id token to a user, by email or
+// by student ID.
+func (h *Handlers) oidcUser(ctx context.Context, idToken *oidc.IDToken) (*models.User, error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	value, _ := claims[h.config.OIDC.Claim].(string)
+	if value == "" {
+		return nil, errOIDCClaim
+	}
+
+	switch h.config.OIDC.UserField {
+	case "student_id":
+		return h.models.SelectUserByStudentID(ctx, value)
+	default:
+		// an address the provider has not verified proves nothing, and
+		// neither does one it says nothing about
+		if verified, _ := claims["email_verified"].(bool); !verified && h.config.OIDC.Claim == "email" {
+			return nil, errOIDCEmailUnverified
+		}
+		return h.models.SelectUserByEmail(ctx, value)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+// passwordResetMailTimeout bounds the lookup, the token and the mail of a
+// reset request, which outlive the request itself.
+const passwordResetMailTimeout = 10 * time.Second
+
+func (h *Handlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+	if payload.Email == "" {
+		h.errorResponse(w, r, invalidField("email", "email_required"))
+		return
+	}
+
+	// every request counts, whether or not the account exists, so that the
+	// throttle tells nothing about which addresses are registered
+	wait, err := h.throttlePasswordReset(r.Context(), strings.ToLower(strings.TrimSpace(payload.Email)), h.clientIP(r))
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		h.errorResponse(w, r, newAppError(http.StatusTooManyRequests, "password_reset_throttled", int(math.Ceil(wait.Minutes()))))
+		return
+	}
+
+	// the response is the same whether or not the account exists, and so is
+	// its timing: the token and the mail are handled after it, and their
+	// errors only logged
+	go h.sendPasswordReset(payload.Email, r.Header.Get("Accept-Language"))
+
+	h.successResponse(w, r, "password_reset_requested", nil)
+}
+
+// sendPasswordReset mails a reset link to the active user with the email, if
+// there is one.
+func (h *Handlers) sendPasswordReset(email, acceptLanguage string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetMailTimeout)
+	defer cancel()
+
+	if err := h.issuePasswordReset(ctx, email, acceptLanguage); err != nil {
+		h.logger.Error("failed to send a password reset mail", slog.String("error", err.Error()))
+	}
+}
+
+func (h *Handlers) issuePasswordReset(ctx context.Context, email, acceptLanguage string) error {
+	user, err := h.models.SelectUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if user.Status != models.UserStatusActive {
+		return nil
+	}
+
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	if err := h.models.InsertPasswordResetToken(ctx, &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(h.config.PasswordReset.TokenTTL),
+	}); err != nil {
+		return err
+	}
+
+	// the recipient is the one asking, so their browser can tell the locale
+	locale := i18n.Negotiate(user.Locale, acceptLanguage)
+	return h.publishMail(user, locale, "password_reset", map[string]any{
+		"FullName":   user.FullName,
+		"TTLMinutes": int(h.config.PasswordReset.TokenTTL.Minutes()),
+		"Link":       h.config.AppBaseURL + "/reset-password?token=" + url.QueryEscape(token),
+	})
+}
+
+// throttlePasswordReset counts a reset request against the email address and
+// the client IP address, and returns how long to wait if either is over its
+// limit for the window.
+func (h *Handlers) throttlePasswordReset(ctx context.Context, email, ip string) (time.Duration, error) {
+	cfg := h.config.PasswordReset
+
+	var wait time.Duration
+	for _, key := range []struct {
+		scope, key string
+		max        int
+	}{
+		{models.LoginScopeResetEmail, email, cfg.MaxEmailRequests},
+		{models.LoginScopeResetIP, ip, cfg.MaxIPRequests},
+	} {
+		// the request that reaches max is still served, and locks out the
+		// ones after it
+		attempt, err := h.models.RecordLoginFailure(ctx, key.scope, key.key, cfg.Window, cfg.Window, key.max)
+		if err != nil {
+			return 0, err
+		}
+		if attempt.LockedUntil != nil && attempt.Failures > key.max {
+			wait = max(wait, attempt.LockedUntil.Sub(attempt.Now))
+		}
+	}
+
+	return wait, nil
+}
+
+func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+	switch {
+	case payload.Token == "":
+		h.errorResponse(w, r, invalidField("token", "reset_token_required"))
+		return
+	case payload.NewPassword == "":
+		h.errorResponse(w, r, invalidField("newPassword", "new_password_required"))
+		return
+	}
+
+	// the username is only known once the token is consumed, so it is
+	// checked again below
+	if err := h.passwords.Validate(payload.NewPassword, ""); err != nil {
+		h.errorResponse(w, r, passwordPolicyError("newPassword", err))
+		return
+	}
+	newPasswordHash, err := h.passwords.Hash(payload.NewPassword)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	errInvalidToken := badRequest("reset_token_invalid")
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		userID, err := m.ConsumePasswordResetToken(r.Context(), utils.HashToken(payload.Token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errInvalidToken
+			}
+			return err
+		}
+
+		user, err := m.SelectUserByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if user.Status != models.UserStatusActive {
+			return errInvalidToken
+		}
+
+		if err := h.passwords.Validate(payload.NewPassword, user.Username); err != nil {
+			return passwordPolicyError("newPassword", err)
+		}
+
+		user.PasswordHash = newPasswordHash
+		if err := m.UpdateUser(r.Context(), user); err != nil {
+			return err
+		}
+		if _, err := m.RevokeUserSessions(r.Context(), user.ID, uuid.Nil); err != nil {
+			return err
+		}
+		if _, err := m.RevokeUserAPITokens(r.Context(), user.ID); err != nil {
+			return err
+		}
+
+		return h.recordAuditEventAs(m, r, user, models.AuditActionAuthResetPassword, models.AuditTargetUser, user.ID.String(), nil, nil)
+	}); err != nil {
+		var appErr *appError
+		switch {
+		case errors.As(err, &appErr):
+			h.errorResponse(w, r, err)
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	h.successResponse(w, r, "password_reset", nil)
+}
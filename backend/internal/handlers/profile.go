@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+// profilePayload holds the profile fields users may edit themselves.
+type profilePayload struct {
+	Phone              string `json:"phone"`
+	College            string `json:"college"`
+	Grade              string `json:"grade"`
+	ExpectedGraduation string `json:"expectedGraduation"`
+
+	// Locale is left alone when absent, and an empty locale follows the
+	// browser
+	Locale *string `json:"locale"`
+}
+
+func (p *profilePayload) apply(user *models.User) error {
+	if p.Phone != "" && !utils.IsValidPhone(p.Phone) {
+		return invalidField("phone", "phone_invalid")
+	}
+
+	var expectedGraduation *time.Time
+	if p.ExpectedGraduation != "" {
+		t, err := time.Parse("2006-01-02", p.ExpectedGraduation)
+		if err != nil {
+			return invalidField("expectedGraduation", "expected_graduation_invalid")
+		}
+		expectedGraduation = &t
+	}
+
+	if p.Locale != nil && *p.Locale != "" && !i18n.IsSupported(*p.Locale) {
+		return invalidField("locale", "locale_unsupported")
+	}
+
+	user.Phone = p.Phone
+	user.College = p.College
+	user.Grade = p.Grade
+	user.ExpectedGraduation = expectedGraduation
+	if p.Locale != nil {
+		user.Locale = *p.Locale
+	}
+
+	return nil
+}
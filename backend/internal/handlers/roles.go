@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+func (h *Handlers) CreateRole(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("CreateRole should be used after GetRequesterMiddleware")
+	}
+
+	var payload struct {
+		Name        string `json:"name"`
+		Level       int32  `json:"level"`
+		Description string `json:"description"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	switch {
+	case payload.Name == "":
+		h.errorResponse(w, r, invalidField("name", "role_name_required"))
+		return
+	case payload.Level <= 0:
+		h.errorResponse(w, r, invalidField("level", "role_level_invalid"))
+		return
+	case payload.Level >= requester.Level:
+		h.errorResponse(w, r, forbidden("role_target_level").withField("level"))
+		return
+	}
+
+	role := &models.Role{
+		Name:        payload.Name,
+		Level:       payload.Level,
+		Description: payload.Description,
+	}
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.InsertRole(r.Context(), role); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionRolesCreate, models.AuditTargetRole, role.ID.String(), nil, role)
+	}); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "roles_name_key" {
+			h.errorResponse(w, r, conflict("role_name_taken").withField("name"))
+			return
+		}
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "role_created", role)
+}
+
+func (h *Handlers) GetAllRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.models.SelectAllRoles(r.Context())
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "roles_listed", roles)
+}
+
+// GetAssignableRoles lists the roles below the requester's level, the ones
+// users.manage can give when creating users or changing their role. Unlike
+// GetAllRoles it needs no roles.manage.
+func (h *Handlers) GetAssignableRoles(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("GetAssignableRoles should be used after GetRequesterMiddleware")
+	}
+
+	roles, err := h.models.SelectAllRoles(r.Context())
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	roles = slices.DeleteFunc(roles, func(role *models.Role) bool { return role.Level >= requester.Level })
+
+	h.successResponse(w, r, "roles_listed", roles)
+}
+
+func (h *Handlers) GetRoleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		roleIDParam := chi.URLParam(r, "roleID")
+		roleID, err := uuid.Parse(roleIDParam)
+		if err != nil {
+			h.errorResponse(w, r, badRequest("invalid_role_id"))
+			return
+		}
+
+		role, err := h.models.SelectRoleByID(r.Context(), roleID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				h.errorResponse(w, r, notFound("role_not_found"))
+				return
+			}
+			h.internalServerError(w, r, err)
+			return
+		}
+
+		role.Permissions, err = h.models.SelectPermissionsByRoleName(r.Context(), role.Name)
+		if err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), roleCtxKey, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RoleLevelGuardMiddleware keeps roles.manage to the roles below the level
+// of the requester.
+func (h *Handlers) RoleLevelGuardMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+		if !ok {
+			panic("RoleLevelGuardMiddleware must used after GetRequesterMiddleware")
+		}
+		role, ok := r.Context().Value(roleCtxKey).(*models.Role)
+		if !ok {
+			panic("RoleLevelGuardMiddleware must used after GetRoleMiddleware")
+		}
+
+		if role.Level >= requester.Level {
+			h.errorResponse(w, r, forbidden("role_target_level"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handlers) GetRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := r.Context().Value(roleCtxKey).(*models.Role)
+	if !ok {
+		h.internalServerError(w, r, errors.New("GetRole must be used after GetRoleMiddleware"))
+		return
+	}
+
+	h.successResponse(w, r, "role_retrieved", role)
+}
+
+func (h *Handlers) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("UpdateRole should be used after GetRequesterMiddleware")
+	}
+	role, ok := r.Context().Value(roleCtxKey).(*models.Role)
+	if !ok {
+		h.internalServerError(w, r, errors.New("UpdateRole must be used after GetRoleMiddleware"))
+		return
+	}
+
+	var payload struct {
+		Name        string `json:"name"`
+		Level       int32  `json:"level"`
+		Description string `json:"description"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	switch {
+	case payload.Name == "":
+		h.errorResponse(w, r, invalidField("name", "role_name_required"))
+		return
+	case payload.Level <= 0:
+		h.errorResponse(w, r, invalidField("level", "role_level_invalid"))
+		return
+	case payload.Level >= requester.Level:
+		h.errorResponse(w, r, forbidden("role_target_level").withField("level"))
+		return
+	}
+
+	// the initial admin role is looked up by name at startup, so only its description may change
+	if role.Name == h.config.InitialAdmin.Role && (payload.Name != role.Name || payload.Level != role.Level) {
+		h.errorResponse(w, r, forbidden("initial_admin_role_update_forbidden"))
+		return
+	}
+
+	before := *role
+	role.Name = payload.Name
+	role.Level = payload.Level
+	role.Description = payload.Description
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateRole(r.Context(), role); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionRolesUpdate, models.AuditTargetRole, role.ID.String(), before, role)
+	}); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "roles_name_key":
+			h.errorResponse(w, r, conflict("role_name_taken").withField("name"))
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	h.successResponse(w, r, "role_updated", role)
+}
+
+func (h *Handlers) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := r.Context().Value(roleCtxKey).(*models.Role)
+	if !ok {
+		h.internalServerError(w, r, errors.New("DeleteRole must be used after GetRoleMiddleware"))
+		return
+	}
+
+	if role.Name == h.config.InitialAdmin.Role {
+		h.errorResponse(w, r, forbidden("initial_admin_role_delete_forbidden"))
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.DeleteRole(r.Context(), role.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionRolesDelete, models.AuditTargetRole, role.ID.String(), role, nil)
+	}); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, notFound("role_not_found"))
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "users_role_id_fkey":
+			h.errorResponse(w, r, conflict("role_in_use"))
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	h.successResponse(w, r, "role_deleted", nil)
+}
+
+func (h *Handlers) GetAllPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.models.SelectAllPermissions(r.Context())
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "permissions_listed", permissions)
+}
+
+func (h *Handlers) UpdateRolePermissions(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("UpdateRolePermissions should be used after GetRequesterMiddleware")
+	}
+	role, ok := r.Context().Value(roleCtxKey).(*models.Role)
+	if !ok {
+		h.internalServerError(w, r, errors.New("UpdateRolePermissions must be used after GetRoleMiddleware"))
+		return
+	}
+
+	var payload struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	if role.Name == h.config.InitialAdmin.Role {
+		h.errorResponse(w, r, forbidden("initial_admin_role_permissions_forbidden"))
+		return
+	}
+
+	// check every permission exists
+	permissions, err := h.models.SelectAllPermissions(r.Context())
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	for _, name := range payload.Permissions {
+		if !slices.ContainsFunc(permissions, func(p *models.Permission) bool { return p.Name == name }) {
+			h.errorResponse(w, r, invalidField("permissions", "permission_not_found", name))
+			return
+		}
+		// permissions the role already has may stay, but only the ones the
+		// requester holds may be granted
+		if !slices.Contains(role.Permissions, name) && !slices.Contains(requester.Permissions, name) {
+			h.errorResponse(w, r, forbidden("permission_not_held", name).withField("permissions"))
+			return
+		}
+	}
+
+	before := *role
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateRolePermissions(r.Context(), role.ID, payload.Permissions); err != nil {
+			return err
+		}
+
+		role.Permissions, err = m.SelectPermissionsByRoleName(r.Context(), role.Name)
+		if err != nil {
+			return err
+		}
+
+		return h.recordAuditEvent(m, r, models.AuditActionRolesUpdatePermissions, models.AuditTargetRole, role.ID.String(), before, role)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "role_permissions_updated", role)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+//go:embed templates/schedule_plan_export.html
+var exportTemplateFS embed.FS
+
+var schedulePlanExportTemplate = template.Must(
+	template.New("schedule_plan_export.html").
+		Funcs(template.FuncMap{"t": i18n.T}).
+		ParseFS(exportTemplateFS, "templates/schedule_plan_export.html"),
+)
+
+// weekdays are numbered 1 (Monday) to 7, as in the applicable days of shifts
+const weekdayCount = 7
+
+func weekdayNames(locale string) []string {
+	names := make([]string, weekdayCount)
+	for i := range names {
+		names[i] = i18n.T(locale, fmt.Sprintf("weekday.%d", i+1))
+	}
+	return names
+}
+
+type scheduleGridCell struct {
+	Applicable bool
+	FullNames  []string
+}
+
+type scheduleGridRow struct {
+	Label string
+	Cells []scheduleGridCell
+}
+
+type scheduleGrid struct {
+	Locale   string
+	Plan     *models.SchedulePlan
+	Weekdays []string
+	Rows     []scheduleGridRow
+}
+
+// buildScheduleGrid lays out the plan as one row per template shift and one
+// column per weekday, filling each cell with the assigned full names.
+func buildScheduleGrid(sp *models.SchedulePlan, st *models.ScheduleTemplate, assignments []*models.SchedulePlanAssignment, locale string) *scheduleGrid {
+	type cellKey struct {
+		shiftID   uuid.UUID
+		dayOfWeek int32
+	}
+	names := make(map[cellKey][]string)
+	for _, a := range assignments {
+		key := cellKey{shiftID: a.ScheduleTemplateShiftID, dayOfWeek: a.DayOfWeek}
+		names[key] = append(names[key], a.FullName)
+	}
+
+	shifts := make([]*models.ScheduleTemplateShift, len(st.Shifts))
+	copy(shifts, st.Shifts)
+	sort.Slice(shifts, func(i, j int) bool {
+		return shifts[i].StartTime < shifts[j].StartTime
+	})
+
+	grid := &scheduleGrid{
+		Locale:   locale,
+		Plan:     sp,
+		Weekdays: weekdayNames(locale),
+		Rows:     make([]scheduleGridRow, 0, len(shifts)),
+	}
+	for _, shift := range shifts {
+		row := scheduleGridRow{
+			Label: fmt.Sprintf("%s-%s", shift.StartTime.Format("15:04"), shift.EndTime.Format("15:04")),
+			Cells: make([]scheduleGridCell, weekdayCount),
+		}
+		for _, day := range shift.ApplicableDays {
+			if day < 1 || day > weekdayCount {
+				continue
+			}
+			row.Cells[day-1] = scheduleGridCell{
+				Applicable: true,
+				FullNames:  names[cellKey{shiftID: shift.ID, dayOfWeek: day}],
+			}
+		}
+		grid.Rows = append(grid.Rows, row)
+	}
+
+	return grid
+}
+
+func (h *Handlers) ExportSchedulePlan(w http.ResponseWriter, r *http.Request) {
+	schedulePlan, ok := r.Context().Value(schedulePlanKey).(*models.SchedulePlan)
+	if !ok {
+		h.internalServerError(w, r, errors.New("ExportSchedulePlan must be used after GetSchedulePlanMiddleware"))
+		return
+	}
+
+	// drafts stay with the editors until they are published
+	if schedulePlan.PublishedAt == nil {
+		h.errorResponse(w, r, conflict("schedule_plan_not_published"))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "html" {
+		h.errorResponse(w, r, badRequest("invalid_export_format"))
+		return
+	}
+
+	st, err := h.models.SelectScheduleTemplateByName(r.Context(), schedulePlan.ScheduleTemplateName)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	assignments, err := h.models.SelectSchedulePlanAssignments(r.Context(), schedulePlan.ID)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	grid := buildScheduleGrid(schedulePlan, st, assignments, h.locale(r))
+
+	var buf bytes.Buffer
+	switch format {
+	case "csv":
+		if err := writeScheduleGridCSV(&buf, grid); err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(schedulePlan.Name+".csv"))
+	case "html":
+		if err := schedulePlanExportTemplate.Execute(&buf, grid); err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		h.logInternalServerError(r, err)
+	}
+}
+
+func writeScheduleGridCSV(buf *bytes.Buffer, grid *scheduleGrid) error {
+	// the byte order mark lets spreadsheet programs detect the encoding
+	buf.WriteString("\uFEFF")
+
+	cw := csv.NewWriter(buf)
+	if err := cw.Write(append([]string{i18n.T(grid.Locale, "export.shift")}, grid.Weekdays...)); err != nil {
+		return err
+	}
+	for _, row := range grid.Rows {
+		record := make([]string, 0, len(row.Cells)+1)
+		record = append(record, row.Label)
+		for _, cell := range row.Cells {
+			if !cell.Applicable {
+				record = append(record, "-")
+				continue
+			}
+			record = append(record, csvText(strings.Join(cell.FullNames, " / ")))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// csvText keeps text that users entered from being read as a formula by
+// spreadsheet programs, which evaluate cells starting with these characters.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -28,7 +30,7 @@ func (h *Handlers) CreateSchedulePlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := h.validate.Struct(payload); err != nil {
-		h.errorResponse(w, r, err)
+		h.errorResponse(w, r, validationError(err))
 		return
 	}
 
@@ -41,14 +43,19 @@ func (h *Handlers) CreateSchedulePlan(w http.ResponseWriter, r *http.Request) {
 		ActiveEndTime:        payload.ActiveEndTime,
 		ScheduleTemplateName: payload.ScheduleTemplateName,
 	}
-	if err := h.models.InsertSchedulePlan(sp); err != nil {
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.InsertSchedulePlan(r.Context(), sp); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionSchedulePlansCreate, models.AuditTargetSchedulePlan, sp.ID.String(), nil, sp)
+	}); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			if pgErr.ConstraintName == "schedule_plans_name_key" {
-				h.errorResponse(w, r, errors.New("排班计划名已存在"))
+				h.errorResponse(w, r, conflict("schedule_plan_name_taken").withField("name"))
 				return
 			} else if pgErr.ConstraintName == "schedule_plans_schedule_template_name_fkey" {
-				h.errorResponse(w, r, errors.New("排班模板不存在"))
+				h.errorResponse(w, r, invalidField("scheduleTemplateName", "schedule_template_not_found"))
 				return
 			}
 			h.internalServerError(w, r, err)
@@ -58,7 +65,7 @@ func (h *Handlers) CreateSchedulePlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.successResponse(w, r, "创建排班计划成功", sp)
+	h.successResponse(w, r, "schedule_plan_created", sp)
 }
 
 func (h *Handlers) GetSchedulePlanMiddleware(next http.Handler) http.Handler {
@@ -66,14 +73,14 @@ func (h *Handlers) GetSchedulePlanMiddleware(next http.Handler) http.Handler {
 		schedulePlanIDParam := chi.URLParam(r, "schedulePlanID")
 		schedulePlanID, err := uuid.Parse(schedulePlanIDParam)
 		if err != nil {
-			h.errorResponse(w, r, errors.New("无效的排班计划ID"))
+			h.errorResponse(w, r, badRequest("invalid_schedule_plan_id"))
 			return
 		}
 
-		schedulePlan, err := h.models.SelectSchedulePlanByID(schedulePlanID)
+		schedulePlan, err := h.models.SelectSchedulePlanByID(r.Context(), schedulePlanID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				h.errorResponse(w, r, errors.New("排班计划不存在"))
+				h.errorResponse(w, r, notFound("schedule_plan_not_found"))
 				return
 			}
 			h.internalServerError(w, r, err)
@@ -92,5 +99,154 @@ func (h *Handlers) GetSchedulePlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.successResponse(w, r, "获取排班计划成功", schedulePlan)
+	h.successResponse(w, r, "schedule_plan_retrieved", schedulePlan)
+}
+
+// UpdateSchedulePlanAssignments replaces the assistants assigned to the
+// shifts of a plan, which the export lays out as its week grid.
+func (h *Handlers) UpdateSchedulePlanAssignments(w http.ResponseWriter, r *http.Request) {
+	schedulePlan, ok := r.Context().Value(schedulePlanKey).(*models.SchedulePlan)
+	if !ok {
+		h.internalServerError(w, r, errors.New("UpdateSchedulePlanAssignments must be used after GetSchedulePlanMiddleware"))
+		return
+	}
+
+	var payload struct {
+		Assignments []struct {
+			ScheduleTemplateShiftID uuid.UUID `json:"scheduleTemplateShiftId"`
+			DayOfWeek               int32     `json:"dayOfWeek"`
+			UserID                  uuid.UUID `json:"userId"`
+		} `json:"assignments"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	template, err := h.models.SelectScheduleTemplateByName(r.Context(), schedulePlan.ScheduleTemplateName)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	shifts := make(map[uuid.UUID]*models.ScheduleTemplateShift, len(template.Shifts))
+	for _, shift := range template.Shifts {
+		shifts[shift.ID] = shift
+	}
+
+	type slot struct {
+		shiftID   uuid.UUID
+		dayOfWeek int32
+	}
+	type seat struct {
+		slot
+		userID uuid.UUID
+	}
+	var (
+		filled      = make(map[slot]int32)
+		seated      = make(map[seat]bool)
+		activeUsers = make(map[uuid.UUID]bool)
+		assignments = make([]*models.SchedulePlanAssignment, 0, len(payload.Assignments))
+	)
+	for i, a := range payload.Assignments {
+		field := fmt.Sprintf("assignments[%d]", i)
+
+		shift, ok := shifts[a.ScheduleTemplateShiftID]
+		if !ok {
+			h.errorResponse(w, r, invalidField(field+".scheduleTemplateShiftId", "assignment_shift_not_in_template", i))
+			return
+		}
+		if !slices.Contains(shift.ApplicableDays, a.DayOfWeek) {
+			h.errorResponse(w, r, invalidField(field+".dayOfWeek", "assignment_day_not_applicable", i))
+			return
+		}
+
+		s := slot{shiftID: a.ScheduleTemplateShiftID, dayOfWeek: a.DayOfWeek}
+		if seated[seat{s, a.UserID}] {
+			h.errorResponse(w, r, invalidField(field, "assignment_duplicate", i))
+			return
+		}
+		seated[seat{s, a.UserID}] = true
+		if filled[s]++; filled[s] > shift.RequiredAssistants {
+			h.errorResponse(w, r, invalidField(field, "assignment_shift_full", i, shift.RequiredAssistants))
+			return
+		}
+
+		if _, checked := activeUsers[a.UserID]; !checked {
+			user, err := h.models.SelectUserByID(r.Context(), a.UserID)
+			if err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					h.errorResponse(w, r, invalidField(field+".userId", "user_not_found"))
+				default:
+					h.internalServerError(w, r, err)
+				}
+				return
+			}
+			activeUsers[a.UserID] = user.Status == models.UserStatusActive
+		}
+		if !activeUsers[a.UserID] {
+			h.errorResponse(w, r, invalidField(field+".userId", "assignment_user_inactive", i))
+			return
+		}
+
+		assignments = append(assignments, &models.SchedulePlanAssignment{
+			ScheduleTemplateShiftID: a.ScheduleTemplateShiftID,
+			DayOfWeek:               a.DayOfWeek,
+			UserID:                  a.UserID,
+		})
+	}
+
+	var updated []*models.SchedulePlanAssignment
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		before, err := m.SelectSchedulePlanAssignments(r.Context(), schedulePlan.ID)
+		if err != nil {
+			return err
+		}
+		if err := m.ReplaceSchedulePlanAssignments(r.Context(), schedulePlan.ID, assignments); err != nil {
+			return err
+		}
+		updated, err = m.SelectSchedulePlanAssignments(r.Context(), schedulePlan.ID)
+		if err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionSchedulePlansUpdateAssignments, models.AuditTargetSchedulePlan, schedulePlan.ID.String(), before, updated)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "schedule_plan_assignments_updated", updated)
+}
+
+// PublishSchedulePlan releases the plan to be exported. Assignments may still
+// change afterwards.
+func (h *Handlers) PublishSchedulePlan(w http.ResponseWriter, r *http.Request) {
+	schedulePlan, ok := r.Context().Value(schedulePlanKey).(*models.SchedulePlan)
+	if !ok {
+		h.internalServerError(w, r, errors.New("PublishSchedulePlan must be used after GetSchedulePlanMiddleware"))
+		return
+	}
+
+	if schedulePlan.PublishedAt != nil {
+		h.errorResponse(w, r, conflict("schedule_plan_already_published"))
+		return
+	}
+
+	before := *schedulePlan
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.PublishSchedulePlan(r.Context(), schedulePlan); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionSchedulePlansPublish, models.AuditTargetSchedulePlan, schedulePlan.ID.String(), before, schedulePlan)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	h.successResponse(w, r, "schedule_plan_published", schedulePlan)
 }
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -33,30 +32,30 @@ func (h *Handlers) CreateScheduleTemplate(w http.ResponseWriter, r *http.Request
 
 	// validate the input
 	if payload.Name == "" {
-		h.errorResponse(w, r, errors.New("班表模板名字为空"))
+		h.errorResponse(w, r, invalidField("name", "schedule_template_name_required"))
 		return
 	}
 
 	for id, shift := range payload.Shifts {
 		if shift.StartTime == "" {
-			h.errorResponse(w, r, fmt.Errorf("班次 %d 的开始时间为空", id))
+			h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].startTime", id), "shift_start_time_required", id))
 			return
 		}
 		if shift.EndTime == "" {
-			h.errorResponse(w, r, fmt.Errorf("班次 %d 的结束时间为空", id))
+			h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].endTime", id), "shift_end_time_required", id))
 			return
 		}
 		if shift.RequiredAssistants <= 0 {
-			h.errorResponse(w, r, fmt.Errorf("班次 %d 的所需助理数必须大于 0", id))
+			h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].requiredAssistants", id), "shift_required_assistants_invalid", id))
 			return
 		}
 		if len(shift.ApplicableDays) == 0 {
-			h.errorResponse(w, r, fmt.Errorf("班次 %d 的适用日期为空", id))
+			h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].applicableDays", id), "shift_applicable_days_required", id))
 			return
 		}
 		for _, day := range shift.ApplicableDays {
 			if day < 1 || day > 7 {
-				h.errorResponse(w, r, fmt.Errorf("班次 %d 的适用日期 %d 不在 1-7 之间", id, day))
+				h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].applicableDays", id), "shift_applicable_day_invalid", id, day))
 				return
 			}
 		}
@@ -69,10 +68,21 @@ func (h *Handlers) CreateScheduleTemplate(w http.ResponseWriter, r *http.Request
 		Shifts:      make([]*models.ScheduleTemplateShift, 0, len(payload.Shifts)),
 	}
 
-	for _, shift := range payload.Shifts {
+	for id, shift := range payload.Shifts {
+		startTime, err := models.ParseTimeOfDay(shift.StartTime)
+		if err != nil {
+			h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].startTime", id), "shift_start_time_invalid", id))
+			return
+		}
+		endTime, err := models.ParseTimeOfDay(shift.EndTime)
+		if err != nil {
+			h.errorResponse(w, r, invalidField(fmt.Sprintf("shifts[%d].endTime", id), "shift_end_time_invalid", id))
+			return
+		}
+
 		sts := &models.ScheduleTemplateShift{
-			StartTime:          shift.StartTime,
-			EndTime:            shift.EndTime,
+			StartTime:          startTime,
+			EndTime:            endTime,
 			RequiredAssistants: shift.RequiredAssistants,
 			ApplicableDays:     shift.ApplicableDays,
 		}
@@ -85,15 +95,25 @@ func (h *Handlers) CreateScheduleTemplate(w http.ResponseWriter, r *http.Request
 
 	// check the validity of the schedule template
 	if err := utils.ValidateScheduleTemplate(st); err != nil {
-		h.errorResponse(w, r, err)
+		var stErr *utils.ScheduleTemplateError
+		if !errors.As(err, &stErr) {
+			h.internalServerError(w, r, err)
+			return
+		}
+		h.errorResponse(w, r, invalidField("shifts", stErr.Code, stErr.Args...))
 		return
 	}
 
 	// insert the schedule template into the database
-	if err := h.models.InsertScheduleTemplate(st); err != nil {
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.InsertScheduleTemplate(r.Context(), st); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionScheduleTemplatesCreate, models.AuditTargetScheduleTemplate, st.ID.String(), nil, st)
+	}); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.ConstraintName == "schedule_templates_name_key" {
-			h.errorResponse(w, r, errors.New("班表模板名字重复"))
+			h.errorResponse(w, r, conflict("schedule_template_name_taken").withField("name"))
 			return
 		} else {
 			h.internalServerError(w, r, err)
@@ -101,21 +121,21 @@ func (h *Handlers) CreateScheduleTemplate(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	h.successResponse(w, r, "班表模板创建成功", st)
+	h.successResponse(w, r, "schedule_template_created", st)
 }
 
 func (h *Handlers) GetScheduleTemplates(w http.ResponseWriter, r *http.Request) {
 	scheduleTemplateIDAsString := chi.URLParam(r, "scheduleTemplateID")
 	scheduleTemplateID, err := uuid.Parse(scheduleTemplateIDAsString)
 	if err != nil {
-		h.errorResponse(w, r, errors.New("班表模板 ID 非法"))
+		h.errorResponse(w, r, badRequest("invalid_schedule_template_id"))
 		return
 	}
 
-	sts, err := h.models.SelectScheduleTemplate(scheduleTemplateID)
+	sts, err := h.models.SelectScheduleTemplate(r.Context(), scheduleTemplateID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			h.errorResponse(w, r, errors.New("班表模板不存在"))
+			h.errorResponse(w, r, notFound("schedule_template_not_found"))
 			return
 		} else {
 			h.internalServerError(w, r, err)
@@ -123,33 +143,49 @@ func (h *Handlers) GetScheduleTemplates(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	h.successResponse(w, r, "班表模板获取成功", sts)
+	h.successResponse(w, r, "schedule_template_retrieved", sts)
 }
 
 func (h *Handlers) GetAllScheduleTemplateMeta(w http.ResponseWriter, r *http.Request) {
-	sts, err := h.models.SelectAllScheduleTemplateMeta()
+	sts, err := h.models.SelectAllScheduleTemplateMeta(r.Context())
 	if err != nil {
 		h.internalServerError(w, r, err)
 		return
 	}
 
-	h.successResponse(w, r, "班表模板元数据获取成功", sts)
+	h.successResponse(w, r, "schedule_templates_listed", sts)
 }
 
 func (h *Handlers) DeleteScheduleTemplate(w http.ResponseWriter, r *http.Request) {
 	scheduleTemplateIDAsString := chi.URLParam(r, "scheduleTemplateID")
-	scheduleTemplateID, err := strconv.ParseInt(scheduleTemplateIDAsString, 10, 64)
+	scheduleTemplateID, err := uuid.Parse(scheduleTemplateIDAsString)
 	if err != nil {
-		h.errorResponse(w, r, errors.New("班表模板 ID 非法"))
+		h.errorResponse(w, r, badRequest("invalid_schedule_template_id"))
 		return
 	}
 
-	if err := h.models.DeleteScheduleTemplate(scheduleTemplateID); err != nil {
+	st, err := h.models.SelectScheduleTemplate(r.Context(), scheduleTemplateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.errorResponse(w, r, notFound("schedule_template_not_found"))
+			return
+		} else {
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.DeleteScheduleTemplate(r.Context(), st.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionScheduleTemplatesDelete, models.AuditTargetScheduleTemplate, st.ID.String(), st, nil)
+	}); err != nil {
 		h.internalServerError(w, r, err)
 		return
 	}
 
-	h.successResponse(w, r, "班表模板删除成功", nil)
+	h.successResponse(w, r, "schedule_template_deleted", nil)
 }
 
 func (h *Handlers) UpdateScheduleTemplateDescription(w http.ResponseWriter, r *http.Request) {
@@ -165,14 +201,26 @@ func (h *Handlers) UpdateScheduleTemplateDescription(w http.ResponseWriter, r *h
 	scheduleTemplateIDAsString := chi.URLParam(r, "scheduleTemplateID")
 	scheduleTemplateID, err := uuid.Parse(scheduleTemplateIDAsString)
 	if err != nil {
-		h.errorResponse(w, r, errors.New("班表模板 ID 非法"))
+		h.errorResponse(w, r, badRequest("invalid_schedule_template_id"))
 		return
 	}
 
-	st, err := h.models.UpdateScheduleTemplateDescription(scheduleTemplateID, payload.Description)
-	if err != nil {
+	var st *models.ScheduleTemplate
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		before, err := m.SelectScheduleTemplate(r.Context(), scheduleTemplateID)
+		if err != nil {
+			return err
+		}
+
+		st, err = m.UpdateScheduleTemplateDescription(r.Context(), scheduleTemplateID, payload.Description)
+		if err != nil {
+			return err
+		}
+
+		return h.recordAuditEvent(m, r, models.AuditActionScheduleTemplatesUpdateDescription, models.AuditTargetScheduleTemplate, st.ID.String(), before, st)
+	}); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			h.errorResponse(w, r, errors.New("班表模板不存在"))
+			h.errorResponse(w, r, notFound("schedule_template_not_found"))
 			return
 		} else {
 			h.internalServerError(w, r, err)
@@ -180,5 +228,5 @@ func (h *Handlers) UpdateScheduleTemplateDescription(w http.ResponseWriter, r *h
 		}
 	}
 
-	h.successResponse(w, r, "班表模板描述更新成功", st)
+	h.successResponse(w, r, "schedule_template_description_updated", st)
 }
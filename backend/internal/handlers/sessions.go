@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+func (h *Handlers) GetMySessions(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("GetMySessions should be used after GetRequesterMiddleware")
+	}
+	current, ok := r.Context().Value(sessionCtxKey).(*models.Session)
+	if !ok {
+		panic("GetMySessions should be used after GetRequesterMiddleware")
+	}
+
+	sessions, err := h.models.SelectActiveSessionsByUserID(r.Context(), requester.ID)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == current.ID
+	}
+
+	h.successResponse(w, r, "sessions_listed", sessions)
+}
+
+func (h *Handlers) RevokeMySession(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("RevokeMySession should be used after GetRequesterMiddleware")
+	}
+	current, ok := r.Context().Value(sessionCtxKey).(*models.Session)
+	if !ok {
+		panic("RevokeMySession should be used after GetRequesterMiddleware")
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		h.errorResponse(w, r, badRequest("invalid_session_id"))
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.RevokeSession(r.Context(), requester.ID, sessionID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeRevokeSession, models.AuditTargetSession, sessionID.String(), nil, nil)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, notFound("session_not_found"))
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if sessionID == current.ID {
+		h.clearTokenCookie(w)
+	}
+
+	h.successResponse(w, r, "session_revoked", nil)
+}
+
+// RevokeAllMySessions logs the requester out everywhere, including the
+// session making the request.
+func (h *Handlers) RevokeAllMySessions(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("RevokeAllMySessions should be used after GetRequesterMiddleware")
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if _, err := m.RevokeUserSessions(r.Context(), requester.ID, uuid.Nil); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeRevokeAllSessions, models.AuditTargetUser, requester.ID.String(), nil, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.clearTokenCookie(w)
+
+	h.successResponse(w, r, "sessions_revoked", nil)
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+const (
+	twoFactorIssuer     = "ECNC Shift Manager"
+	twoFactorAudience   = "login_2fa"
+	twoFactorTokenTTL   = 5 * time.Minute
+	recoveryCodesPerSet = 10
+)
+
+var (
+	errInvalidSecondFactor = badRequest("second_factor_invalid")
+	errMissingSecondFactor = badRequest("second_factor_required")
+)
+
+func (h *Handlers) twoFactorRequired(user *models.User) bool {
+	return h.config.TwoFactor.RequiredLevel > 0 && int(user.Level) >= h.config.TwoFactor.RequiredLevel
+}
+
+// issuePartialToken returns a short-lived token proving the password of the
+// user was checked, which Login2FA exchanges for a session.
+func (h *Handlers) issuePartialToken(ctx context.Context, user *models.User) (string, error) {
+	claims := jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   user.ID.String(),
+		Audience:  jwt.ClaimStrings{twoFactorAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(twoFactorTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	return h.keys.Sign(ctx, claims)
+}
+
+func (h *Handlers) parsePartialToken(ctx context.Context, s string) (uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	if err := h.keys.Parse(ctx, s, claims, jwt.WithAudience(twoFactorAudience)); err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(claims.Subject)
+}
+
+// verifySecondFactor checks either a TOTP code or a recovery code, consuming
+// it so that it cannot be used again, and reports whether a recovery code
+// was used.
+func (h *Handlers) verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	switch {
+	case code != "":
+		totp, err := h.models.SelectUserTOTP(ctx, user.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, errInvalidSecondFactor
+			}
+			return false, err
+		}
+		if totp.EnabledAt == nil {
+			return false, errInvalidSecondFactor
+		}
+
+		step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now(), totp.LastUsedStep)
+		if !ok {
+			return false, errInvalidSecondFactor
+		}
+		if err := h.models.UseUserTOTPStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, errInvalidSecondFactor
+			}
+			return false, err
+		}
+		return false, nil
+	case recoveryCode != "":
+		codeHash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+		if err := h.models.ConsumeRecoveryCode(ctx, user.ID, codeHash); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, errInvalidSecondFactor
+			}
+			return false, err
+		}
+		return true, nil
+	default:
+		return false, errMissingSecondFactor
+	}
+}
+
+// newRecoveryCodes returns a fresh set of recovery codes with their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodesPerSet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+
+	return codes, hashes, nil
+}
+
+func (h *Handlers) Login2FA(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		PartialToken string `json:"partialToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	errExpired := unauthorized("partial_token_expired")
+	userID, err := h.parsePartialToken(r.Context(), payload.PartialToken)
+	if err != nil {
+		h.errorResponse(w, r, errExpired)
+		return
+	}
+
+	user, err := h.models.SelectUserByID(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errExpired)
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+	if user.Status != models.UserStatusActive {
+		h.errorResponse(w, r, forbidden("account_deactivated"))
+		return
+	}
+
+	// wrong codes count as failed logins, so guessing is throttled too
+	ip := h.clientIP(r)
+	if err := h.checkLoginThrottle(r.Context(), user.Username, ip); err != nil {
+		var throttledErr *loginThrottledError
+		switch {
+		case errors.As(err, &throttledErr):
+			h.throttledResponse(w, r, throttledErr)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	usedRecoveryCode, err := h.verifySecondFactor(r.Context(), user, payload.Code, payload.RecoveryCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			if err := h.recordLoginFailure(r.Context(), user.Username, ip); err != nil {
+				h.internalServerError(w, r, err)
+				return
+			}
+			h.errorResponse(w, r, err)
+		case errors.Is(err, errMissingSecondFactor):
+			h.errorResponse(w, r, err)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if usedRecoveryCode {
+		if err := h.recordAuditEventAs(h.models, r, user, models.AuditActionAuthUseRecoveryCode, models.AuditTargetUser, user.ID.String(), nil, nil); err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.completeLogin(w, r, user)
+}
+
+func (h *Handlers) GetMyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("GetMyTwoFactor should be used after GetRequesterMiddleware")
+	}
+
+	remaining := 0
+	if requester.TwoFactorEnabled {
+		var err error
+		remaining, err = h.models.CountUnusedRecoveryCodes(r.Context(), requester.ID)
+		if err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.successResponse(w, r, "two_factor_status_retrieved", map[string]any{
+		"enabled":                requester.TwoFactorEnabled,
+		"required":               requester.TwoFactorRequired,
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+func (h *Handlers) EnrollMyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("EnrollMyTwoFactor should be used after GetRequesterMiddleware")
+	}
+
+	errEnabled := conflict("two_factor_already_enabled")
+	if requester.TwoFactorEnabled {
+		h.errorResponse(w, r, errEnabled)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	if err := h.models.UpsertPendingUserTOTP(r.Context(), requester.ID, secret); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEnabled)
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.successResponse(w, r, "two_factor_enrollment_started", map[string]any{
+		"secret":     secret,
+		"otpauthUrl": utils.TOTPURL(twoFactorIssuer, requester.Username, secret),
+	})
+}
+
+func (h *Handlers) EnableMyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("EnableMyTwoFactor should be used after GetRequesterMiddleware")
+	}
+
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	totp, err := h.models.SelectUserTOTP(r.Context(), requester.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, conflict("two_factor_enrollment_not_started"))
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+	if totp.EnabledAt != nil {
+		h.errorResponse(w, r, conflict("two_factor_already_enabled"))
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(totp.Secret, payload.Code, time.Now(), 0)
+	if !ok {
+		h.errorResponse(w, r, errInvalidSecondFactor)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.EnableUserTOTP(r.Context(), requester.ID, step); err != nil {
+			return err
+		}
+		if err := m.ReplaceRecoveryCodes(r.Context(), requester.ID, hashes); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeEnable2FA, models.AuditTargetUser, requester.ID.String(), nil, nil)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.successResponse(w, r, "two_factor_enabled", map[string]any{
+		"recoveryCodes": codes,
+	})
+}
+
+func (h *Handlers) DisableMyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("DisableMyTwoFactor should be used after GetRequesterMiddleware")
+	}
+
+	var payload struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	switch {
+	case !requester.TwoFactorEnabled:
+		h.errorResponse(w, r, conflict("two_factor_not_enabled"))
+		return
+	case requester.TwoFactorRequired:
+		h.errorResponse(w, r, forbidden("two_factor_required_by_role"))
+		return
+	}
+
+	// verify the password
+	match, _, err := h.passwords.Verify(payload.Password, requester.PasswordHash)
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	if !match {
+		h.errorResponse(w, r, invalidField("password", "password_incorrect"))
+		return
+	}
+
+	if _, err := h.verifySecondFactor(r.Context(), requester, payload.Code, payload.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor), errors.Is(err, errMissingSecondFactor):
+			h.errorResponse(w, r, err)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.DeleteUserTOTP(r.Context(), requester.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeDisable2FA, models.AuditTargetUser, requester.ID.String(), nil, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "two_factor_disabled", nil)
+}
+
+func (h *Handlers) RegenerateMyRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("RegenerateMyRecoveryCodes should be used after GetRequesterMiddleware")
+	}
+
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	if !requester.TwoFactorEnabled {
+		h.errorResponse(w, r, conflict("two_factor_not_enabled"))
+		return
+	}
+
+	// only the authenticator app may be used to replace the recovery codes
+	if _, err := h.verifySecondFactor(r.Context(), requester, payload.Code, ""); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor), errors.Is(err, errMissingSecondFactor):
+			h.errorResponse(w, r, err)
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.ReplaceRecoveryCodes(r.Context(), requester.ID, hashes); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionMeRegenerateRecoveryCodes, models.AuditTargetUser, requester.ID.String(), nil, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "recovery_codes_regenerated", map[string]any{
+		"recoveryCodes": codes,
+	})
+}
+
+// ResetUserTwoFactor turns 2FA off for a user who lost both the
+// authenticator app and the recovery codes.
+func (h *Handlers) ResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("ResetUserTwoFactor must be used after GetUserMiddleware"))
+		return
+	}
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("ResetUserTwoFactor should be used after GetRequesterMiddleware")
+	}
+
+	// turning off one's own 2FA takes the password and a code in
+	// DisableMyTwoFactor
+	if user.ID == requester.ID {
+		h.errorResponse(w, r, forbidden("self_reset_2fa_forbidden"))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		h.errorResponse(w, r, conflict("user_two_factor_not_enabled"))
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.DeleteUserTOTP(r.Context(), user.ID); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersReset2FA, models.AuditTargetUser, user.ID.String(), nil, nil)
+	}); err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	h.successResponse(w, r, "two_factor_reset", nil)
+}
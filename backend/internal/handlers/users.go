@@ -1,29 +1,34 @@
 package handlers
 
 import (
-	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
-	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/workers"
-	amqp "github.com/rabbitmq/amqp091-go"
-	"github.com/wneessen/go-mail"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("CreateUser should be used after GetRequesterMiddleware")
+	}
+
 	var payload struct {
 		Username string `json:"username"`
 		Email    string `json:"email"`
 		FullName string `json:"fullName"`
 		Role     string `json:"role"`
+		Locale   string `json:"locale"`
 	}
 
 	if err := h.readJSON(r, &payload); err != nil {
@@ -34,50 +39,74 @@ func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// check the payload
 	switch {
 	case (payload.Username == ""):
-		h.errorResponse(w, r, errors.New("用户名为空"))
+		h.errorResponse(w, r, invalidField("username", "username_required"))
 		return
 	case (payload.Email == ""):
-		h.errorResponse(w, r, errors.New("邮箱为空"))
+		h.errorResponse(w, r, invalidField("email", "email_required"))
 		return
 	case (!utils.IsValidEmail(payload.Email)):
-		h.errorResponse(w, r, errors.New("邮箱非法"))
+		h.errorResponse(w, r, invalidField("email", "email_invalid"))
 		return
 	case (payload.FullName == ""):
-		h.errorResponse(w, r, errors.New("姓名为空"))
+		h.errorResponse(w, r, invalidField("fullName", "full_name_required"))
 		return
 	case (payload.Role == ""):
-		h.errorResponse(w, r, errors.New("角色为空"))
+		h.errorResponse(w, r, invalidField("role", "role_required"))
 		return
-	case (!utils.IsValidRole(payload.Role)):
-		h.errorResponse(w, r, errors.New("角色非法"))
+	case (payload.Locale != "" && !i18n.IsSupported(payload.Locale)):
+		h.errorResponse(w, r, invalidField("locale", "locale_unsupported"))
 		return
 	}
 
-	// generate random password and hash
-	random_password := utils.GenerateRandomPassword(12)
-	passwordHashBytes, err := bcrypt.GenerateFromPassword([]byte(random_password), bcrypt.DefaultCost)
+	// check the role exists and is below the requester, so that users.manage
+	// cannot be used to create accounts above the one holding it
+	role, err := h.models.SelectRoleByName(r.Context(), payload.Role)
 	if err != nil {
-		h.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, invalidField("role", "role_invalid"))
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+	if role.Level >= requester.Level {
+		h.errorResponse(w, r, forbidden("role_level_too_high").withField("role"))
 		return
 	}
 
-	// insert the user to the database
+	// insert the pending user together with the invitation
 	user := &models.User{
-		Username:     payload.Username,
-		Email:        payload.Email,
-		PasswordHash: string(passwordHashBytes),
-		FullName:     payload.FullName,
-		Role:         payload.Role,
+		Username: payload.Username,
+		Email:    payload.Email,
+		FullName: payload.FullName,
+		Role:     payload.Role,
+		Status:   models.UserStatusPending,
+		Locale:   payload.Locale,
 	}
-	if err := h.models.InsertUser(user); err != nil {
+	var token string
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.InsertUser(r.Context(), user); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = h.createInvitation(r.Context(), m, user)
+		if err != nil {
+			return err
+		}
+
+		return h.recordAuditEvent(m, r, models.AuditActionUsersCreate, models.AuditTargetUser, user.ID.String(), nil, user)
+	}); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			switch {
 			case (pgErr.ConstraintName == "users_username_key"):
-				h.errorResponse(w, r, errors.New("用户名已存在"))
+				h.errorResponse(w, r, conflict("username_taken").withField("username"))
 				return
 			case (pgErr.ConstraintName == "users_email_key"):
-				h.errorResponse(w, r, errors.New("邮箱已存在"))
+				h.errorResponse(w, r, conflict("email_taken").withField("email"))
 				return
 			default:
 				h.internalServerError(w, r, err)
@@ -89,57 +118,108 @@ func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// send the username and password to the e-mail
-	message := mail.NewMsg()
-	if err := message.From(h.config.MailClient.Sender); err != nil {
+	// send the activation link to the e-mail
+	if err := h.publishInvitationMail(user, token); err != nil {
 		h.internalServerError(w, r, err)
 		return
 	}
-	if err := message.To(payload.Email); err != nil {
-		h.internalServerError(w, r, err)
+
+	// return a success message
+	h.successResponse(w, r, "user_created", user)
+}
+
+func (h *Handlers) GetAllUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := &models.UserFilter{
+		Search: strings.TrimSpace(query.Get("q")),
+		Role:   query.Get("role"),
+		Sort:   "createdAt",
+		Limit:  20,
+	}
+
+	// deactivated and graduated users are hidden unless asked for
+	switch status := query.Get("status"); status {
+	case "":
+		filter.Statuses = []string{models.UserStatusActive, models.UserStatusPending}
+	case "all":
+	case models.UserStatusPending, models.UserStatusActive, models.UserStatusDeactivated, models.UserStatusGraduated:
+		filter.Statuses = []string{status}
+	default:
+		h.errorResponse(w, r, badRequest("invalid_user_status"))
 		return
 	}
 
-	mailPayload := workers.MailPayload{
-		To:      payload.Email,
-		Subject: "ECNC 假勤系统 - 您的账号信息",
-		Body:    fmt.Sprintf("用户名: %s, 密码: %s", payload.Username, random_password),
+	if sort := query.Get("sort"); sort != "" {
+		filter.Desc = strings.HasPrefix(sort, "-")
+		filter.Sort = strings.TrimPrefix(sort, "-")
+		if !models.IsValidUserSort(filter.Sort) {
+			h.errorResponse(w, r, badRequest("invalid_sort"))
+			return
+		}
+	}
+
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > 100 {
+			h.errorResponse(w, r, badRequest("invalid_limit"))
+			return
+		}
+		filter.Limit = limit
 	}
-	jsonData, err := json.Marshal(mailPayload)
+
+	if cursorParam := query.Get("cursor"); cursorParam != "" {
+		cursor, err := decodeUserCursor(cursorParam)
+		if err != nil {
+			h.errorResponse(w, r, badRequest("invalid_cursor"))
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	// fetch one extra user to know whether there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+	users, err := h.models.SelectUsers(r.Context(), filter)
 	if err != nil {
 		h.internalServerError(w, r, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := h.emailChan.PublishWithContext(
-		ctx,
-		"",
-		"mail_queue",
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        jsonData,
-		},
-	); err != nil {
-		h.internalServerError(w, r, err)
-		return
+	nextCursor := ""
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		nextCursor, err = encodeUserCursor(models.NewUserCursor(users[pageSize-1], filter.Sort))
+		if err != nil {
+			h.internalServerError(w, r, err)
+			return
+		}
 	}
 
-	// return a success message
-	h.successResponse(w, r, "创建用户成功", user)
+	h.successResponse(w, r, "users_listed", map[string]any{
+		"users":      users,
+		"nextCursor": nextCursor,
+	})
 }
 
-func (h *Handlers) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.models.SelectAllUsers()
+func encodeUserCursor(cursor *models.UserCursor) (string, error) {
+	data, err := json.Marshal(cursor)
 	if err != nil {
-		h.internalServerError(w, r, err)
-		return
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
 
-	h.successResponse(w, r, "获取所有用户信息成功", users)
+func decodeUserCursor(s string) (*models.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &models.UserCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
 }
 
 func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -149,10 +229,14 @@ func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.successResponse(w, r, "获取用户信息成功", user)
+	h.successResponse(w, r, "user_retrieved", user)
 }
 
 func (h *Handlers) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("UpdateUserRole should be used after GetRequesterMiddleware")
+	}
 	user, ok := r.Context().Value(userCtxKey).(*models.User)
 	if !ok {
 		h.internalServerError(w, r, errors.New("UpdateUserRoleHandler must be used after GetUserMiddleware"))
@@ -166,21 +250,48 @@ func (h *Handlers) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
 		h.errorResponse(w, r, err)
 		return
 	}
-	if !utils.IsValidRole(payload.Role) {
-		h.errorResponse(w, r, errors.New("角色非法"))
+	role, err := h.models.SelectRoleByName(r.Context(), payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, invalidField("role", "role_invalid"))
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	// UserLevelGuardMiddleware has kept the user below the requester, and
+	// the role has to be below the requester too
+	switch {
+	case user.ID == requester.ID:
+		h.errorResponse(w, r, forbidden("cannot_change_own_role"))
+		return
+	case role.Level >= requester.Level:
+		h.errorResponse(w, r, forbidden("role_level_too_high").withField("role"))
 		return
 	}
 
 	if user.Username == h.config.InitialAdmin.Username {
-		h.errorResponse(w, r, errors.New("禁止修改初始管理员角色"))
+		h.errorResponse(w, r, forbidden("initial_admin_role_change_forbidden"))
 		return
 	}
 
+	before := *user
 	user.Role = payload.Role
-	if err := h.models.UpdateUser(user); err != nil {
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateUser(r.Context(), user); err != nil {
+			return err
+		}
+		if _, err := m.RevokeUserSessions(r.Context(), user.ID, uuid.Nil); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersUpdateRole, models.AuditTargetUser, user.ID.String(), before, user)
+	}); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			h.errorResponse(w, r, errors.New("发生了数据冲突，请重试"))
+			h.errorResponse(w, r, errEditConflict)
 			return
 		default:
 			h.internalServerError(w, r, err)
@@ -188,25 +299,174 @@ func (h *Handlers) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.successResponse(w, r, "更新用户身份成功", user)
+	h.successResponse(w, r, "user_role_updated", user)
 }
 
-func (h *Handlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(userCtxKey).(*models.User)
 	if !ok {
-		h.internalServerError(w, r, errors.New("DeleteUser must be used after GetUserMiddleware"))
+		h.internalServerError(w, r, errors.New("UpdateUserProfile must be used after GetUserMiddleware"))
 		return
 	}
 
-	if user.Username == h.config.InitialAdmin.Username {
-		h.errorResponse(w, r, errors.New("禁止删除初始管理员"))
+	var payload struct {
+		FullName  string `json:"fullName"`
+		Email     string `json:"email"`
+		StudentID string `json:"studentId"`
+		profilePayload
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
 		return
 	}
 
-	if err := h.models.DeleteUser(user.ID); err != nil {
-		h.internalServerError(w, r, err)
+	switch {
+	case payload.FullName == "":
+		h.errorResponse(w, r, invalidField("fullName", "full_name_required"))
+		return
+	case payload.Email == "":
+		h.errorResponse(w, r, invalidField("email", "email_required"))
+		return
+	case !utils.IsValidEmail(payload.Email):
+		h.errorResponse(w, r, invalidField("email", "email_invalid"))
 		return
+	case payload.StudentID != "" && !utils.IsValidStudentID(payload.StudentID):
+		h.errorResponse(w, r, invalidField("studentId", "student_id_invalid"))
+		return
+	}
+
+	before := *user
+	user.FullName = payload.FullName
+	user.Email = payload.Email
+	user.StudentID = payload.StudentID
+	if err := payload.profilePayload.apply(user); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateUser(r.Context(), user); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersUpdateProfile, models.AuditTargetUser, user.ID.String(), before, user)
+	}); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "users_email_key":
+			h.errorResponse(w, r, conflict("email_taken").withField("email"))
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "users_student_id_key":
+			h.errorResponse(w, r, conflict("student_id_taken").withField("studentId"))
+		default:
+			h.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	h.successResponse(w, r, "user_profile_updated", user)
+}
+
+func (h *Handlers) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("DeactivateUser must be used after GetUserMiddleware"))
+		return
+	}
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("DeactivateUser must be used after GetRequesterMiddleware"))
+		return
+	}
+
+	var payload struct {
+		Status        string     `json:"status"`
+		DeactivatedAt *time.Time `json:"deactivatedAt"`
+	}
+	if err := h.readJSON(r, &payload); err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	switch {
+	case payload.Status != models.UserStatusDeactivated && payload.Status != models.UserStatusGraduated:
+		h.errorResponse(w, r, badRequest("invalid_user_status"))
+		return
+	case user.Username == h.config.InitialAdmin.Username:
+		h.errorResponse(w, r, forbidden("initial_admin_deactivate_forbidden"))
+		return
+	case user.ID == requester.ID:
+		h.errorResponse(w, r, forbidden("self_deactivate_forbidden"))
+		return
+	case user.Status != models.UserStatusActive && user.Status != models.UserStatusPending:
+		h.errorResponse(w, r, conflict("user_already_deactivated"))
+		return
+	}
+
+	deactivatedAt := time.Now()
+	if payload.DeactivatedAt != nil {
+		deactivatedAt = *payload.DeactivatedAt
+	}
+
+	before := *user
+	user.Status = payload.Status
+	user.DeactivatedAt = &deactivatedAt
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateUser(r.Context(), user); err != nil {
+			return err
+		}
+		if _, err := m.RevokeUserSessions(r.Context(), user.ID, uuid.Nil); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersDeactivate, models.AuditTargetUser, user.ID.String(), before, user)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	h.successResponse(w, r, "user_deactivated", user)
+}
+
+func (h *Handlers) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtxKey).(*models.User)
+	if !ok {
+		h.internalServerError(w, r, errors.New("RestoreUser must be used after GetUserMiddleware"))
+		return
+	}
+
+	if user.Status == models.UserStatusActive || user.Status == models.UserStatusPending {
+		h.errorResponse(w, r, conflict("user_not_deactivated"))
+		return
+	}
+
+	// users who never set a password go back to waiting for activation
+	before := *user
+	user.Status = models.UserStatusActive
+	if user.PasswordHash == "" {
+		user.Status = models.UserStatusPending
+	}
+	user.DeactivatedAt = nil
+	if err := h.models.WithTx(r.Context(), func(m models.Store) error {
+		if err := m.UpdateUser(r.Context(), user); err != nil {
+			return err
+		}
+		return h.recordAuditEvent(m, r, models.AuditActionUsersRestore, models.AuditTargetUser, user.ID.String(), before, user)
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorResponse(w, r, errEditConflict)
+			return
+		default:
+			h.internalServerError(w, r, err)
+			return
+		}
 	}
 
-	h.successResponse(w, r, "删除用户成功", nil)
+	h.successResponse(w, r, "user_restored", user)
 }
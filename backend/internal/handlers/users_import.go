@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
+)
+
+const (
+	maxImportFileSize = 1 << 20
+	maxImportRows     = 200
+)
+
+var errImportRollback = errors.New("import rolled back")
+
+type importRow struct {
+	Line        int      `json:"line"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	FullName    string   `json:"fullName"`
+	Role        string   `json:"role"`
+	Errors      []string `json:"errors"`
+	EmailQueued bool     `json:"emailQueued"`
+
+	user  *models.User
+	token string
+}
+
+// fail records a problem of the row, in the locale of the response.
+func (row *importRow) fail(locale, code string, args ...any) {
+	row.Errors = append(row.Errors, i18n.T(locale, "error."+code, args...))
+}
+
+type importResult struct {
+	DryRun  bool         `json:"dryRun"`
+	Created int          `json:"created"`
+	Failed  int          `json:"failed"`
+	Rows    []*importRow `json:"rows"`
+}
+
+func (h *Handlers) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	requester, ok := r.Context().Value(requesterCtxKey).(*models.User)
+	if !ok {
+		panic("ImportUsers should be used after GetRequesterMiddleware")
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.errorResponse(w, r, newAppError(http.StatusRequestEntityTooLarge, "csv_file_too_large", maxImportFileSize>>20))
+			return
+		}
+		h.errorResponse(w, r, badRequest("csv_file_missing"))
+		return
+	}
+	defer file.Close()
+
+	rows, err := readImportRows(file)
+	if err != nil {
+		h.errorResponse(w, r, err)
+		return
+	}
+
+	// validate the rows before touching the database
+	roles, err := h.models.SelectAllRoles(r.Context())
+	if err != nil {
+		h.internalServerError(w, r, err)
+		return
+	}
+	validateImportRows(rows, roles, requester.Level, h.locale(r))
+
+	result := &importResult{DryRun: dryRun, Rows: rows}
+
+	// insert every row in one transaction, using a savepoint per row so that
+	// constraint violations can be reported for all rows at once
+	err = h.models.WithTx(r.Context(), func(m models.Store) error {
+		for _, row := range rows {
+			if len(row.Errors) > 0 {
+				continue
+			}
+
+			row.user = &models.User{
+				Username: row.Username,
+				Email:    row.Email,
+				FullName: row.FullName,
+				Role:     row.Role,
+				Status:   models.UserStatusPending,
+			}
+			err := m.Savepoint(r.Context(), "import_row", func(m models.Store) error {
+				user := row.user
+				if err := m.InsertUser(r.Context(), user); err != nil {
+					return err
+				}
+
+				if !dryRun {
+					var err error
+					row.token, err = h.createInvitation(r.Context(), m, user)
+					if err != nil {
+						return err
+					}
+				}
+
+				return h.recordAuditEvent(m, r, models.AuditActionUsersImport, models.AuditTargetUser, user.ID.String(), nil, user)
+			})
+			if err != nil {
+				var pgErr *pgconn.PgError
+				switch {
+				case errors.As(err, &pgErr) && pgErr.ConstraintName == "users_username_key":
+					row.fail(h.locale(r), "username_taken")
+				case errors.As(err, &pgErr) && pgErr.ConstraintName == "users_email_key":
+					row.fail(h.locale(r), "email_taken")
+				default:
+					return err
+				}
+			}
+		}
+
+		for _, row := range rows {
+			if len(row.Errors) > 0 {
+				result.Failed++
+			}
+		}
+		if dryRun || result.Failed > 0 {
+			return errImportRollback
+		}
+
+		result.Created = len(rows)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRollback) {
+		h.internalServerError(w, r, err)
+		return
+	}
+
+	if result.Failed > 0 {
+		h.errorResponse(w, r, unprocessable("users_import_failed", result.Failed).withData(result))
+		return
+	}
+	if dryRun {
+		h.successResponse(w, r, "users_import_validated", result)
+		return
+	}
+
+	// queue one activation email per created user
+	for _, row := range rows {
+		if err := h.publishInvitationMail(row.user, row.token); err != nil {
+			h.logInternalServerError(r, err)
+			continue
+		}
+		row.EmailQueued = true
+	}
+
+	h.successResponse(w, r, "users_imported", result, result.Created)
+}
+
+// readImportRows parses a CSV whose header names the username, email,
+// fullName and role columns in any order.
+func readImportRows(file io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, badRequest("csv_invalid")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\uFEFF")
+		switch strings.ToLower(name) {
+		case "username":
+			columns["username"] = i
+		case "email":
+			columns["email"] = i
+		case "fullname", "full_name":
+			columns["fullName"] = i
+		case "role":
+			columns["role"] = i
+		}
+	}
+	for _, name := range []string{"username", "email", "fullName", "role"} {
+		if _, ok := columns[name]; !ok {
+			return nil, badRequest("csv_column_missing", name)
+		}
+	}
+
+	rows := make([]*importRow, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, badRequest("csv_row_invalid", parseErr.Line)
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			return strings.TrimSpace(record[columns[name]])
+		}
+		rows = append(rows, &importRow{
+			Line:     line,
+			Username: field("username"),
+			Email:    field("email"),
+			FullName: field("fullName"),
+			Role:     field("role"),
+			Errors:   make([]string, 0),
+		})
+
+		if len(rows) > maxImportRows {
+			return nil, badRequest("csv_too_many_rows", maxImportRows)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, badRequest("csv_empty")
+	}
+
+	return rows, nil
+}
+
+// validateImportRows checks the rows on their own and against each other.
+// Roles must be below maxLevel, the level of the requester.
+func validateImportRows(rows []*importRow, roles []*models.Role, maxLevel int32, locale string) {
+	roleLevels := make(map[string]int32, len(roles))
+	for _, role := range roles {
+		roleLevels[role.Name] = role.Level
+	}
+
+	seenUsernames := make(map[string]int)
+	seenEmails := make(map[string]int)
+	for _, row := range rows {
+		switch {
+		case row.Username == "":
+			row.fail(locale, "username_required")
+		case seenUsernames[row.Username] != 0:
+			row.fail(locale, "import_username_duplicate", seenUsernames[row.Username])
+		default:
+			seenUsernames[row.Username] = row.Line
+		}
+
+		switch {
+		case row.Email == "":
+			row.fail(locale, "email_required")
+		case !utils.IsValidEmail(row.Email):
+			row.fail(locale, "email_invalid")
+		case seenEmails[row.Email] != 0:
+			row.fail(locale, "import_email_duplicate", seenEmails[row.Email])
+		default:
+			seenEmails[row.Email] = row.Line
+		}
+
+		if row.FullName == "" {
+			row.fail(locale, "full_name_required")
+		}
+
+		switch {
+		case row.Role == "":
+			row.fail(locale, "role_required")
+		default:
+			level, ok := roleLevels[row.Role]
+			switch {
+			case !ok:
+				row.fail(locale, "role_invalid")
+			case level >= maxLevel:
+				row.fail(locale, "role_level_too_high")
+			}
+		}
+	}
+}
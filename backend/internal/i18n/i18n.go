@@ -0,0 +1,142 @@
+// Package i18n holds the message catalogs and mail templates shown to users,
+// in Chinese and English.
+//
+// Catalogs live in locales/<locale>.json as flat maps. Keys are prefixed by
+// what they are for: "error.<code>" for the codes of API errors, "success.*"
+// for messages of successful responses, and so on. Values are fmt formats,
+// so a translation must keep the verbs of the Chinese original in order.
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	Chinese = "zh-CN"
+	English = "en"
+
+	// Default is used when neither the user nor the browser asks for a
+	// supported locale, and whenever a translation is missing.
+	Default = Chinese
+)
+
+// Locales lists the supported locales, in the order the matcher prefers
+// them.
+var Locales = []string{Chinese, English}
+
+var (
+	//go:embed locales/*.json
+	localesFS embed.FS
+
+	//go:embed mail
+	mailFS embed.FS
+
+	catalogs  = make(map[string]map[string]string, len(Locales))
+	templates = make(map[string]map[string]*template.Template, len(Locales))
+	matcher   language.Matcher
+)
+
+func init() {
+	tags := make([]language.Tag, 0, len(Locales))
+	for _, locale := range Locales {
+		tags = append(tags, language.MustParse(locale))
+
+		data, err := localesFS.ReadFile(path.Join("locales", locale+".json"))
+		if err != nil {
+			panic(err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog %s: %v", locale, err))
+		}
+		catalogs[locale] = catalog
+
+		// each mail gets a template set of its own, as they all define
+		// "subject" and "body"
+		names, err := fs.Glob(mailFS, path.Join("mail", locale, "*.tmpl"))
+		if err != nil {
+			panic(err)
+		}
+		templates[locale] = make(map[string]*template.Template, len(names))
+		for _, name := range names {
+			templates[locale][strings.TrimSuffix(path.Base(name), ".tmpl")] = template.Must(template.ParseFS(mailFS, name))
+		}
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+// IsSupported reports whether locale is one of Locales.
+func IsSupported(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
+
+// Negotiate picks the locale of a request: the preference saved by the user
+// if they have one, otherwise the best match of the Accept-Language header.
+func Negotiate(preference, acceptLanguage string) string {
+	if IsSupported(preference) {
+		return preference
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Locales[index]
+}
+
+// T returns the message for key in the locale, formatted with args. Keys
+// missing from the locale fall back to Default, then to the key itself.
+func T(locale, key string, args ...any) string {
+	format, ok := catalogs[locale][key]
+	if !ok {
+		if format, ok = catalogs[Default][key]; !ok {
+			format = key
+		}
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Mail renders the subject and body of mail/<locale>/<name>.tmpl, which
+// defines a "subject" and a "body" template. Mails to users without a
+// supported locale are in Default.
+func Mail(locale, name string, data any) (subject, body string, err error) {
+	if !IsSupported(locale) {
+		locale = Default
+	}
+	tmpl, ok := templates[locale][name]
+	if !ok {
+		return "", "", fmt.Errorf("i18n: unknown mail template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, "body", data); err != nil {
+		return "", "", err
+	}
+	body = strings.TrimSpace(buf.String())
+
+	return subject, body, nil
+}
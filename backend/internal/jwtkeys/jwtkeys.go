@@ -0,0 +1,495 @@
+// Package jwtkeys keeps the keyset signing our JWTs. Keys live in the
+// database, so that every api instance shares them, and rotate on a
+// schedule: a new key is published in the JWKS a day before it starts
+// signing, and a retired key still verifies until the tokens it signed have
+// expired.
+package jwtkeys
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// prepublish is how long a key is listed in the JWKS before it signs,
+	// so that verifiers caching the JWKS know it by then
+	prepublish = 24 * time.Hour
+
+	// verifyGrace keeps a retired key verifying for the lifetime of the
+	// longest-lived token we sign, the 24 hour session
+	verifyGrace = 24 * time.Hour
+
+	rotateInterval = time.Hour
+
+	// an unknown kid reloads the keys at most this often, so that forged
+	// tokens cannot hammer the database
+	reloadThrottle = 10 * time.Second
+
+	rsaKeyBits = 2048
+
+	// sealInfo binds the keys derived from JWT_SECRET to sealing signing
+	// keys, so that they are of no use for anything else
+	sealInfo = "ecnc-shift-manager jwt signing keys"
+)
+
+var (
+	ErrUnknownKey   = errors.New("jwtkeys: unknown signing key")
+	errNoSigningKey = errors.New("jwtkeys: no signing key")
+	errSealedKey    = errors.New("jwtkeys: sealed key opens under neither JWT_SECRET nor JWT_SECRET_PREVIOUS")
+)
+
+var validMethods = []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}
+
+type key struct {
+	id          string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	public      crypto.PublicKey
+	activatesAt time.Time
+	retiresAt   time.Time
+	expiresAt   time.Time
+}
+
+type Keyset struct {
+	config *config.Config
+	logger *slog.Logger
+	models models.Store
+
+	mu       sync.RWMutex
+	keys     []*key
+	loadedAt time.Time
+}
+
+func New(config *config.Config, logger *slog.Logger, models models.Store) *Keyset {
+	return &Keyset{
+		config: config,
+		logger: logger,
+		models: models,
+	}
+}
+
+// Run makes sure a signing key exists, then checks every hour whether the
+// next key is due.
+func (ks *Keyset) Run(ctx context.Context) error {
+	if err := ks.rotate(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(rotateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ks.rotate(ctx); err != nil {
+					ks.logger.Error("failed to rotate jwt signing keys", slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Sign signs the claims with the current key, naming it in the kid header.
+func (ks *Keyset) Sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	k := ks.signingKey()
+	if k == nil {
+		// the current key retired since the last rotation check
+		if err := ks.rotate(ctx); err != nil {
+			return "", err
+		}
+		if k = ks.signingKey(); k == nil {
+			return "", errNoSigningKey
+		}
+	}
+
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.id
+	return token.SignedString(k.private)
+}
+
+// Parse verifies a token signed by Sign and fills in its claims.
+func (ks *Keyset) Parse(ctx context.Context, s string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	opts = append(opts, jwt.WithValidMethods(validMethods))
+	_, err := jwt.ParseWithClaims(s, claims, ks.keyfunc(ctx), opts...)
+	return err
+}
+
+// JWKS returns the public keys for other services to verify our tokens,
+// including the next key before it starts signing.
+func (ks *Keyset) JWKS() jose.JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	for _, k := range ks.keys {
+		if now.After(k.expiresAt) {
+			continue
+		}
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       k.public,
+			KeyID:     k.id,
+			Algorithm: k.method.Alg(),
+			Use:       "sig",
+		})
+	}
+
+	return set
+}
+
+func (ks *Keyset) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrUnknownKey
+		}
+
+		k := ks.lookup(kid)
+		if k == nil && ks.reloadDue() {
+			if err := ks.reload(ctx); err != nil {
+				return nil, err
+			}
+			k = ks.lookup(kid)
+		}
+		if k == nil || time.Now().After(k.expiresAt) || t.Method.Alg() != k.method.Alg() {
+			return nil, ErrUnknownKey
+		}
+
+		return k.public, nil
+	}
+}
+
+func (ks *Keyset) lookup(kid string) *key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, k := range ks.keys {
+		if k.id == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+func (ks *Keyset) reloadDue() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return time.Since(ks.loadedAt) > reloadThrottle
+}
+
+// signingKey returns the active key, preferring the configured algorithm
+// while the keyset switches from another one.
+func (ks *Keyset) signingKey() *key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	var current *key
+	for _, k := range ks.keys {
+		if now.Before(k.activatesAt) || !now.Before(k.retiresAt) {
+			continue
+		}
+		preferred := k.method.Alg() == ks.config.JWT.Algorithm
+		switch {
+		case current == nil:
+			current = k
+		case preferred != (current.method.Alg() == ks.config.JWT.Algorithm):
+			if preferred {
+				current = k
+			}
+		case k.activatesAt.After(current.activatesAt):
+			current = k
+		}
+	}
+
+	return current
+}
+
+// rotate deletes expired keys and creates a key when none of the configured
+// algorithm is active, or the next one when the current key retires within
+// a day. Instances take turns through an advisory lock.
+func (ks *Keyset) rotate(ctx context.Context) error {
+	if err := ks.models.WithTx(ctx, func(m models.Store) error {
+		if err := m.LockJWTSigningKeys(ctx); err != nil {
+			return err
+		}
+		if err := m.DeleteExpiredJWTSigningKeys(ctx); err != nil {
+			return err
+		}
+		rows, err := m.SelectJWTSigningKeys(ctx)
+		if err != nil {
+			return err
+		}
+		if err := ks.reseal(ctx, m, rows); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var latest *models.JWTSigningKey
+		for _, row := range rows {
+			if row.Algorithm != ks.config.JWT.Algorithm || !row.RetiresAt.After(now) {
+				continue
+			}
+			// keys that can no longer be decrypted do not count
+			if _, err := ks.decode(row); err != nil {
+				continue
+			}
+			if latest == nil || row.RetiresAt.After(latest.RetiresAt) {
+				latest = row
+			}
+		}
+
+		switch {
+		case latest == nil:
+			return ks.insertKey(ctx, m, now)
+		case latest.RetiresAt.Sub(now) < prepublish:
+			return ks.insertKey(ctx, m, latest.RetiresAt)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return ks.reload(ctx)
+}
+
+// reseal seals the keys that only open under JWT_SECRET_PREVIOUS or a legacy
+// key again under JWT_SECRET, so that the previous secret can be dropped.
+func (ks *Keyset) reseal(ctx context.Context, m models.Store, rows []*models.JWTSigningKey) error {
+	for _, row := range rows {
+		privateDER, stale, err := ks.decrypt(row.PrivateKey)
+		if err != nil || !stale {
+			continue
+		}
+
+		sealed, err := ks.encrypt(privateDER)
+		if err != nil {
+			return err
+		}
+		if err := m.UpdateJWTSigningKeyPrivateKey(ctx, row.ID, sealed); err != nil {
+			return err
+		}
+		row.PrivateKey = sealed
+
+		ks.logger.Info("resealed jwt signing key", slog.String("kid", row.ID.String()))
+	}
+	return nil
+}
+
+func (ks *Keyset) insertKey(ctx context.Context, m models.Store, activatesAt time.Time) error {
+	row, err := ks.generateKey(activatesAt)
+	if err != nil {
+		return err
+	}
+	if err := m.InsertJWTSigningKey(ctx, row); err != nil {
+		return err
+	}
+
+	ks.logger.Info("created jwt signing key", slog.String("kid", row.ID.String()), slog.String("algorithm", row.Algorithm), slog.Time("activatesAt", activatesAt))
+	return nil
+}
+
+// generateKey creates a key of the configured algorithm with its private
+// half sealed for storage.
+func (ks *Keyset) generateKey(activatesAt time.Time) (*models.JWTSigningKey, error) {
+	var private crypto.Signer
+	switch ks.config.JWT.Algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := ks.encrypt(privateDER)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	retiresAt := activatesAt.Add(ks.config.JWT.RotationInterval)
+	return &models.JWTSigningKey{
+		Algorithm:   ks.config.JWT.Algorithm,
+		PrivateKey:  encrypted,
+		PublicKey:   publicDER,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(verifyGrace),
+	}, nil
+}
+
+func (ks *Keyset) reload(ctx context.Context) error {
+	rows, err := ks.models.SelectJWTSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*key, 0, len(rows))
+	for _, row := range rows {
+		k, err := ks.decode(row)
+		if err != nil {
+			ks.logger.Warn("skipping unreadable jwt signing key", slog.String("kid", row.ID.String()), slog.String("error", err.Error()))
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = keys
+	ks.loadedAt = time.Now()
+	return nil
+}
+
+func (ks *Keyset) decode(row *models.JWTSigningKey) (*key, error) {
+	var method jwt.SigningMethod
+	switch row.Algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		method = jwt.SigningMethodEdDSA
+	case jwt.SigningMethodRS256.Alg():
+		method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("jwtkeys: unsupported algorithm %q", row.Algorithm)
+	}
+
+	privateDER, _, err := ks.decrypt(row.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	private, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("jwtkeys: unsupported private key %T", private)
+	}
+
+	return &key{
+		id:          row.ID.String(),
+		method:      method,
+		private:     signer,
+		public:      signer.Public(),
+		activatesAt: row.ActivatesAt,
+		retiresAt:   row.RetiresAt,
+		expiresAt:   row.ExpiresAt,
+	}, nil
+}
+
+// private keys are stored sealed with AES-GCM under a key derived from
+// JWT_SECRET, as nonce followed by ciphertext
+func sealingKey(secret string) ([]byte, error) {
+	k := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(sealInfo)), k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// legacySealingKey is the bare hash of the secret that keys were sealed
+// under before HKDF, only used to open them for sealing again
+func legacySealingKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+func newAEAD(k []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (ks *Keyset) encrypt(plaintext []byte) ([]byte, error) {
+	k, err := sealingKey(ks.config.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt opens a key sealed under JWT_SECRET, or under JWT_SECRET_PREVIOUS
+// or a legacy key, in which case stale reports that it is to be sealed
+// again.
+func (ks *Keyset) decrypt(sealed []byte) (plaintext []byte, stale bool, err error) {
+	var secrets []string
+	for _, secret := range []string{ks.config.JWTSecret, ks.config.JWTSecretPrevious} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	var keys [][]byte
+	for _, secret := range secrets {
+		k, err := sealingKey(secret)
+		if err != nil {
+			return nil, false, err
+		}
+		keys = append(keys, k, legacySealingKey(secret))
+	}
+
+	for i, k := range keys {
+		aead, err := newAEAD(k)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, false, errors.New("jwtkeys: sealed key too short")
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, nil); err == nil {
+			return plaintext, i > 0, nil
+		}
+	}
+	return nil, false, errSealedKey
+}
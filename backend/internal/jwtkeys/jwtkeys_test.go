@@ -0,0 +1,123 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models/memory"
+)
+
+const (
+	testSecret         = "test-secret-that-is-at-least-32-bytes-long"
+	testSecretPrevious = "previous-secret-at-least-32-bytes-long"
+)
+
+func newTestKeyset(t *testing.T, store models.Store, secret, previous string) *Keyset {
+	t.Helper()
+
+	cfg := &config.Config{JWTSecret: secret, JWTSecretPrevious: previous}
+	cfg.JWT.Algorithm = jwt.SigningMethodEdDSA.Alg()
+	cfg.JWT.RotationInterval = 30 * 24 * time.Hour
+
+	ks := New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	if err := ks.rotate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func sign(t *testing.T, ks *Keyset) string {
+	t.Helper()
+
+	token, err := ks.Sign(context.Background(), jwt.RegisteredClaims{
+		Subject:   "subject",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func verify(t *testing.T, ks *Keyset, token string) {
+	t.Helper()
+
+	if err := ks.Parse(context.Background(), token, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("token of the old key does not verify: %v", err)
+	}
+}
+
+func TestSecretRotation(t *testing.T) {
+	store := memory.New()
+	token := sign(t, newTestKeyset(t, store, testSecretPrevious, ""))
+
+	// the previous secret opens the key, which is sealed again on start
+	verify(t, newTestKeyset(t, store, testSecret, testSecretPrevious), token)
+
+	// so the previous secret can be dropped afterwards
+	verify(t, newTestKeyset(t, store, testSecret, ""), token)
+}
+
+func TestLegacySealingKey(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ks := newTestKeyset(t, store, testSecret, "")
+	token := sign(t, ks)
+
+	// seal the key the way it was before HKDF
+	rows, err := store.SelectJWTSigningKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := rows[0]
+	privateDER, _, err := ks.decrypt(row.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(legacySealingKey(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateJWTSigningKeyPrivateKey(ctx, row.ID, aead.Seal(nonce, nonce, privateDER, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	verify(t, newTestKeyset(t, store, testSecret, ""), token)
+
+	rows, err = store.SelectJWTSigningKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, stale, err := ks.decrypt(rows[0].PrivateKey); err != nil || stale {
+		t.Fatalf("got stale %v, error %v, want the key sealed under the derived key", stale, err)
+	}
+}
+
+func TestUnknownSecret(t *testing.T) {
+	store := memory.New()
+	token := sign(t, newTestKeyset(t, store, testSecretPrevious, ""))
+
+	// without the previous secret a new key is created, and old tokens fail
+	ks := newTestKeyset(t, store, testSecret, "")
+	if err := ks.Parse(context.Background(), token, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("token of an unreadable key verified")
+	}
+	sign(t, ks)
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIToken is a personal token that scripts send as a Bearer token. Its
+// scopes are permission names, and a request made with it gets only the
+// scopes that the role of its user still grants.
+type APIToken struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"-"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"tokenPrefix"`
+	TokenHash   string     `json:"-"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func (m *Models) InsertAPIToken(ctx context.Context, t *APIToken) error {
+	query := `
+		INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, t.UserID, t.Name, t.TokenPrefix, t.TokenHash, t.Scopes, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+}
+
+// TouchAPIToken records the use of an unrevoked and unexpired token and
+// returns it, or sql.ErrNoRows if no such token exists.
+func (m *Models) TouchAPIToken(ctx context.Context, tokenHash string) (*APIToken, error) {
+	query := `
+		UPDATE api_tokens
+		SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	t := &APIToken{TokenHash: tokenHash}
+	if err := m.db.QueryRow(ctx, query, tokenHash).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.Name,
+		&t.TokenPrefix,
+		&t.Scopes,
+		&t.ExpiresAt,
+		&t.LastUsedAt,
+		&t.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// SelectActiveAPITokensByUserID returns the unrevoked and unexpired tokens of
+// a user, newest first.
+func (m *Models) SelectActiveAPITokensByUserID(ctx context.Context, userID uuid.UUID) ([]*APIToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*APIToken, 0)
+	for rows.Next() {
+		t := &APIToken{}
+		if err := rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.Name,
+			&t.TokenPrefix,
+			&t.Scopes,
+			&t.ExpiresAt,
+			&t.LastUsedAt,
+			&t.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RevokeAPIToken revokes an active token of a user, returning sql.ErrNoRows
+// if the user has no such token.
+func (m *Models) RevokeAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	query := `
+		UPDATE api_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, id, userID).Scan(&id)
+}
+
+// RevokeUserAPITokens revokes every active token of a user and returns how
+// many were revoked.
+func (m *Models) RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `
+		UPDATE api_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
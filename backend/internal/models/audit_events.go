@@ -0,0 +1,248 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditActionUsersCreate                        = "users.create"
+	AuditActionUsersImport                        = "users.import"
+	AuditActionUsersDeactivate                    = "users.deactivate"
+	AuditActionUsersRestore                       = "users.restore"
+	AuditActionUsersResendInvitation              = "users.resend_invitation"
+	AuditActionUsersRevokeInvitation              = "users.revoke_invitation"
+	AuditActionUsersUpdateRole                    = "users.update_role"
+	AuditActionUsersUnlock                        = "users.unlock"
+	AuditActionUsersReset2FA                      = "users.reset_2fa"
+	AuditActionUsersUpdateProfile                 = "users.update_profile"
+	AuditActionUsersImpersonate                   = "users.impersonate"
+	AuditActionAuthStopImpersonation              = "auth.stop_impersonation"
+	AuditActionMeUpdateProfile                    = "me.update_profile"
+	AuditActionAuthActivate                       = "auth.activate"
+	AuditActionAuthResetPassword                  = "auth.reset_password"
+	AuditActionMeUpdatePassword                   = "me.update_password"
+	AuditActionMeEnable2FA                        = "me.enable_2fa"
+	AuditActionMeDisable2FA                       = "me.disable_2fa"
+	AuditActionMeRegenerateRecoveryCodes          = "me.regenerate_recovery_codes"
+	AuditActionAuthUseRecoveryCode                = "auth.use_recovery_code"
+	AuditActionMeCreateAPIToken                   = "me.create_api_token"
+	AuditActionMeRevokeAPIToken                   = "me.revoke_api_token"
+	AuditActionMeRevokeSession                    = "me.revoke_session"
+	AuditActionMeRevokeAllSessions                = "me.revoke_all_sessions"
+	AuditActionLoginLockEventsUnlock              = "login_lock_events.unlock"
+	AuditActionRolesCreate                        = "roles.create"
+	AuditActionRolesUpdate                        = "roles.update"
+	AuditActionRolesDelete                        = "roles.delete"
+	AuditActionRolesUpdatePermissions             = "roles.update_permissions"
+	AuditActionScheduleTemplatesCreate            = "schedule_templates.create"
+	AuditActionScheduleTemplatesDelete            = "schedule_templates.delete"
+	AuditActionScheduleTemplatesUpdateDescription = "schedule_templates.update_description"
+	AuditActionSchedulePlansCreate                = "schedule_plans.create"
+	AuditActionSchedulePlansUpdateAssignments     = "schedule_plans.update_assignments"
+	AuditActionSchedulePlansPublish               = "schedule_plans.publish"
+)
+
+const (
+	AuditTargetUser             = "user"
+	AuditTargetSession          = "session"
+	AuditTargetAPIToken         = "api_token"
+	AuditTargetLoginLockEvent   = "login_lock_event"
+	AuditTargetRole             = "role"
+	AuditTargetScheduleTemplate = "schedule_template"
+	AuditTargetSchedulePlan     = "schedule_plan"
+)
+
+type AuditEvent struct {
+	ID            uuid.UUID `json:"id"`
+	ActorID       uuid.UUID `json:"actorId"`
+	ActorUsername string    `json:"actorUsername"`
+	// the admin acting through an impersonation session, if any
+	ImpersonatorID       *uuid.UUID      `json:"impersonatorId"`
+	ImpersonatorUsername *string         `json:"impersonatorUsername"`
+	Action               string          `json:"action"`
+	TargetType           string          `json:"targetType"`
+	TargetID             string          `json:"targetId"`
+	Before               json.RawMessage `json:"before"`
+	After                json.RawMessage `json:"after"`
+	IPAddress            string          `json:"ipAddress"`
+	UserAgent            string          `json:"userAgent"`
+	RequestMethod        string          `json:"requestMethod"`
+	RequestPath          string          `json:"requestPath"`
+	CreatedAt            time.Time       `json:"createdAt"`
+}
+
+type AuditEventFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+func (m *Models) InsertAuditEvent(ctx context.Context, event *AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (
+			actor_id,
+			actor_username,
+			impersonator_id,
+			impersonator_username,
+			action,
+			target_type,
+			target_id,
+			before,
+			after,
+			ip_address,
+			user_agent,
+			request_method,
+			request_path
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at
+	`
+	args := []any{
+		event.ActorID,
+		event.ActorUsername,
+		event.ImpersonatorID,
+		event.ImpersonatorUsername,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		nullableJSON(event.Before),
+		nullableJSON(event.After),
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestMethod,
+		event.RequestPath,
+	}
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := m.db.QueryRow(ctx, query, args...).Scan(&event.ID, &event.CreatedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SelectAuditEvents returns the events matching the filter, newest first,
+// together with the total number of matches ignoring limit and offset.
+func (m *Models) SelectAuditEvents(ctx context.Context, filter *AuditEventFilter) ([]*AuditEvent, int, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		addCondition("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT
+			COUNT(*) OVER(),
+			id,
+			actor_id,
+			actor_username,
+			impersonator_id,
+			impersonator_username,
+			action,
+			target_type,
+			target_id,
+			before,
+			after,
+			ip_address,
+			user_agent,
+			request_method,
+			request_path,
+			created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+
+	ctx, cancel := m.withBatchTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	events := make([]*AuditEvent, 0)
+	for rows.Next() {
+		event := &AuditEvent{}
+		var actorID uuid.NullUUID
+		var before, after []byte
+		if err := rows.Scan(
+			&total,
+			&event.ID,
+			&actorID,
+			&event.ActorUsername,
+			&event.ImpersonatorID,
+			&event.ImpersonatorUsername,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&before,
+			&after,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.RequestMethod,
+			&event.RequestPath,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		event.ActorID = actorID.UUID
+		event.Before = before
+		event.After = after
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+func nullableJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const JobGraduationReminder = "graduation_reminder"
+
+// JobRun records when a periodic job last ran, so that every api instance
+// agrees on whether it is due.
+type JobRun struct {
+	Name      string
+	LastRunAt *time.Time
+}
+
+// LockJobRun selects the run of a job for update, creating it the first time,
+// so that the instance holding it is the only one running the job until the
+// current transaction ends.
+func (m *Models) LockJobRun(ctx context.Context, name string) (*JobRun, error) {
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.db.Exec(ctx, `
+		INSERT INTO job_runs (name)
+		VALUES ($1)
+		ON CONFLICT (name) DO NOTHING
+	`, name); err != nil {
+		return nil, err
+	}
+
+	run := &JobRun{}
+	if err := m.db.QueryRow(ctx, `
+		SELECT name, last_run_at
+		FROM job_runs
+		WHERE name = $1
+		FOR UPDATE
+	`, name).Scan(&run.Name, &run.LastRunAt); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+func (m *Models) UpdateJobRun(ctx context.Context, name string, ranAt time.Time) error {
+	query := `
+		UPDATE job_runs
+		SET last_run_at = $2
+		WHERE name = $1
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, name, ranAt)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
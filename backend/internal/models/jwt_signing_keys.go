@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JWTSigningKey is a key of the keyset signing our tokens. It signs between
+// ActivatesAt and RetiresAt and still verifies until ExpiresAt, so tokens
+// signed just before its retirement stay valid. PrivateKey is encrypted.
+type JWTSigningKey struct {
+	ID          uuid.UUID
+	Algorithm   string
+	PrivateKey  []byte
+	PublicKey   []byte
+	ActivatesAt time.Time
+	RetiresAt   time.Time
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
+// SelectJWTSigningKeys returns the keys that have not expired, oldest first.
+func (m *Models) SelectJWTSigningKeys(ctx context.Context) ([]*JWTSigningKey, error) {
+	query := `
+		SELECT id, algorithm, private_key, public_key, activates_at, retires_at, expires_at, created_at
+		FROM jwt_signing_keys
+		WHERE expires_at > NOW()
+		ORDER BY activates_at, created_at
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*JWTSigningKey
+	for rows.Next() {
+		k := &JWTSigningKey{}
+		if err := rows.Scan(&k.ID, &k.Algorithm, &k.PrivateKey, &k.PublicKey, &k.ActivatesAt, &k.RetiresAt, &k.ExpiresAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (m *Models) InsertJWTSigningKey(ctx context.Context, k *JWTSigningKey) error {
+	query := `
+		INSERT INTO jwt_signing_keys (algorithm, private_key, public_key, activates_at, retires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, k.Algorithm, k.PrivateKey, k.PublicKey, k.ActivatesAt, k.RetiresAt, k.ExpiresAt).Scan(&k.ID, &k.CreatedAt)
+}
+
+// UpdateJWTSigningKeyPrivateKey replaces the encrypted private key of the
+// key, when it is sealed again under another secret.
+func (m *Models) UpdateJWTSigningKeyPrivateKey(ctx context.Context, id uuid.UUID, privateKey []byte) error {
+	query := `
+		UPDATE jwt_signing_keys
+		SET private_key = $2
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, id, privateKey)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *Models) DeleteExpiredJWTSigningKeys(ctx context.Context) error {
+	query := `
+		DELETE FROM jwt_signing_keys
+		WHERE expires_at <= NOW()
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := m.db.Exec(ctx, query)
+	return err
+}
+
+// LockJWTSigningKeys serializes key rotation between api instances until the
+// current transaction ends.
+func (m *Models) LockJWTSigningKeys(ctx context.Context) error {
+	query := `SELECT pg_advisory_xact_lock(hashtext('jwt_signing_keys'))`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := m.db.Exec(ctx, query)
+	return err
+}
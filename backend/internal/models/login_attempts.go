@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LoginScopeUsername = "username"
+	LoginScopeIP       = "ip"
+
+	// password reset requests are counted like failed logins
+	LoginScopeResetEmail = "reset_email"
+	LoginScopeResetIP    = "reset_ip"
+)
+
+// LoginAttempt counts the recent failed logins for a username or an IP
+// address. Now is the database clock, so that every backend instance
+// computes delays against the same time.
+type LoginAttempt struct {
+	Scope         string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+	Now           time.Time
+}
+
+type LoginLockEvent struct {
+	ID          uuid.UUID  `json:"id"`
+	Scope       string     `json:"scope"`
+	Key         string     `json:"key"`
+	Failures    int        `json:"failures"`
+	LockedUntil time.Time  `json:"lockedUntil"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UnlockedAt  *time.Time `json:"unlockedAt"`
+	UnlockedBy  *uuid.UUID `json:"unlockedBy"`
+	Active      bool       `json:"active"`
+}
+
+type LoginLockEventFilter struct {
+	Scope      string
+	Key        string
+	ActiveOnly bool
+	Limit      int
+	Offset     int
+}
+
+func (m *Models) SelectLoginAttempt(ctx context.Context, scope, key string) (*LoginAttempt, error) {
+	query := `
+		SELECT failures, last_failure_at, locked_until, NOW()
+		FROM login_attempts
+		WHERE scope = $1 AND key = $2
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	a := &LoginAttempt{Scope: scope, Key: key}
+	if err := m.db.QueryRow(ctx, query, scope, key).Scan(&a.Failures, &a.LastFailureAt, &a.LockedUntil, &a.Now); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// RecordLoginFailure increments the failure counter of a username or IP
+// address, starting over once the window has passed or a previous lock has
+// expired, and locks it for lockout once maxFailures is reached.
+func (m *Models) RecordLoginFailure(ctx context.Context, scope, key string, window, lockout time.Duration, maxFailures int) (*LoginAttempt, error) {
+	a := &LoginAttempt{Scope: scope, Key: key}
+
+	err := m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		query := `
+			INSERT INTO login_attempts (scope, key, failures, last_failure_at)
+			VALUES ($1, $2, 1, NOW())
+			ON CONFLICT (scope, key) DO UPDATE SET
+				failures = CASE
+					WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $3)
+						OR login_attempts.locked_until <= NOW()
+					THEN 1
+					ELSE login_attempts.failures + 1
+				END,
+				locked_until = CASE
+					WHEN login_attempts.locked_until <= NOW() THEN NULL
+					ELSE login_attempts.locked_until
+				END,
+				last_failure_at = NOW()
+			RETURNING failures, last_failure_at, locked_until, NOW()
+		`
+		if err := m.db.QueryRow(ctx, query, scope, key, window.Seconds()).Scan(&a.Failures, &a.LastFailureAt, &a.LockedUntil, &a.Now); err != nil {
+			return err
+		}
+
+		if a.Failures < maxFailures || a.LockedUntil != nil {
+			return nil
+		}
+
+		query = `
+			UPDATE login_attempts
+			SET locked_until = NOW() + make_interval(secs => $3)
+			WHERE scope = $1 AND key = $2
+			RETURNING locked_until
+		`
+		if err := m.db.QueryRow(ctx, query, scope, key, lockout.Seconds()).Scan(&a.LockedUntil); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO login_lock_events (scope, key, failures, locked_until)
+			VALUES ($1, $2, $3, $4)
+		`
+		_, err := m.db.Exec(ctx, query, scope, key, a.Failures, *a.LockedUntil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (m *Models) DeleteLoginAttempt(ctx context.Context, scope, key string) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE scope = $1 AND key = $2
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := m.db.Exec(ctx, query, scope, key)
+	return err
+}
+
+// UnlockLogin clears the failure counter of a username or IP address and
+// marks its active lock events as unlocked by the given user.
+func (m *Models) UnlockLogin(ctx context.Context, scope, key string, unlockedBy uuid.UUID) (int64, error) {
+	var unlocked int64
+
+	err := m.withTx(ctx, func(m *Models) error {
+		if err := m.DeleteLoginAttempt(ctx, scope, key); err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE login_lock_events
+			SET unlocked_at = NOW(), unlocked_by = $3
+			WHERE scope = $1 AND key = $2 AND unlocked_at IS NULL AND locked_until > NOW()
+		`
+
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		res, err := m.db.Exec(ctx, query, scope, key, unlockedBy)
+		if err != nil {
+			return err
+		}
+		unlocked = res.RowsAffected()
+		return nil
+	})
+
+	return unlocked, err
+}
+
+const selectLoginLockEventQuery = `
+	SELECT
+		COUNT(*) OVER(),
+		id,
+		scope,
+		key,
+		failures,
+		locked_until,
+		created_at,
+		unlocked_at,
+		unlocked_by,
+		unlocked_at IS NULL AND locked_until > NOW()
+	FROM login_lock_events
+`
+
+func scanLoginLockEvent(row rowScanner, total *int) (*LoginLockEvent, error) {
+	e := &LoginLockEvent{}
+	if err := row.Scan(
+		total,
+		&e.ID,
+		&e.Scope,
+		&e.Key,
+		&e.Failures,
+		&e.LockedUntil,
+		&e.CreatedAt,
+		&e.UnlockedAt,
+		&e.UnlockedBy,
+		&e.Active,
+	); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (m *Models) SelectLoginLockEventByID(ctx context.Context, id uuid.UUID) (*LoginLockEvent, error) {
+	query := selectLoginLockEventQuery + `WHERE id = $1`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var total int
+	return scanLoginLockEvent(m.db.QueryRow(ctx, query, id), &total)
+}
+
+func (m *Models) SelectLoginLockEvents(ctx context.Context, filter *LoginLockEventFilter) ([]*LoginLockEvent, int, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Scope != "" {
+		addCondition("scope = $%d", filter.Scope)
+	}
+	if filter.Key != "" {
+		addCondition("key = $%d", filter.Key)
+	}
+	if filter.ActiveOnly {
+		conditions = append(conditions, "unlocked_at IS NULL AND locked_until > NOW()")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := selectLoginLockEventQuery + fmt.Sprintf(`
+		%s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+
+	ctx, cancel := m.withBatchTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	events := make([]*LoginLockEvent, 0)
+	for rows.Next() {
+		event, err := scanLoginLockEvent(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+type apiToken struct {
+	models.APIToken
+	revokedAt *time.Time
+}
+
+func (t *apiToken) active(now time.Time) bool {
+	return t.revokedAt == nil && t.ExpiresAt.After(now)
+}
+
+func (t *apiToken) copy() *models.APIToken {
+	c := t.APIToken
+	c.TokenHash = ""
+	c.Scopes = slices.Clone(t.Scopes)
+	c.LastUsedAt = clonePtr(t.LastUsedAt)
+	return &c
+}
+
+func (s *Store) InsertAPIToken(ctx context.Context, t *models.APIToken) error {
+	return s.do(ctx, func(d *data) error {
+		if find(d.apiTokens, func(row *apiToken) bool { return row.TokenHash == t.TokenHash }) != nil {
+			return uniqueViolation("api_tokens_token_hash_key")
+		}
+
+		t.ID = uuid.New()
+		t.CreatedAt = now()
+		d.apiTokens = append(d.apiTokens, &apiToken{
+			APIToken: models.APIToken{
+				ID:          t.ID,
+				UserID:      t.UserID,
+				Name:        t.Name,
+				TokenPrefix: t.TokenPrefix,
+				TokenHash:   t.TokenHash,
+				Scopes:      append(make([]string, 0, len(t.Scopes)), t.Scopes...),
+				ExpiresAt:   t.ExpiresAt.Truncate(time.Microsecond),
+				CreatedAt:   t.CreatedAt,
+			},
+		})
+		return nil
+	})
+}
+
+// TouchAPIToken records the use of an unrevoked and unexpired token and
+// returns it, or sql.ErrNoRows if no such token exists.
+func (s *Store) TouchAPIToken(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var touched *models.APIToken
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		row := find(d.apiTokens, func(row *apiToken) bool { return row.TokenHash == tokenHash && row.active(t) })
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.LastUsedAt = &t
+		touched = row.copy()
+		touched.TokenHash = tokenHash
+		return nil
+	})
+	return touched, err
+}
+
+func (s *Store) SelectActiveAPITokensByUserID(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	tokens := make([]*models.APIToken, 0)
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		for _, row := range d.apiTokens {
+			if row.UserID == userID && row.active(t) {
+				tokens = append(tokens, row.copy())
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(tokens, func(a, b *models.APIToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return tokens, err
+}
+
+func (s *Store) RevokeAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	return s.do(ctx, func(d *data) error {
+		t := now()
+		row := find(d.apiTokens, func(row *apiToken) bool { return row.ID == id && row.UserID == userID && row.active(t) })
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.revokedAt = &t
+		return nil
+	})
+}
+
+func (s *Store) RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var revoked int64
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		for _, row := range d.apiTokens {
+			if row.UserID == userID && row.active(t) {
+				row.revokedAt = &t
+				revoked++
+			}
+		}
+		return nil
+	})
+	return revoked, err
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+func (s *Store) InsertAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return s.do(ctx, func(d *data) error {
+		event.ID = uuid.New()
+		event.CreatedAt = now()
+
+		row := *event
+		row.ImpersonatorID = clonePtr(event.ImpersonatorID)
+		row.ImpersonatorUsername = clonePtr(event.ImpersonatorUsername)
+		row.Before = nullableJSON(event.Before)
+		row.After = nullableJSON(event.After)
+		d.auditEvents = append(d.auditEvents, &row)
+		return nil
+	})
+}
+
+// SelectAuditEvents returns the events matching the filter, newest first,
+// together with the total number of matches ignoring limit and offset.
+func (s *Store) SelectAuditEvents(ctx context.Context, filter *models.AuditEventFilter) ([]*models.AuditEvent, int, error) {
+	events := make([]*models.AuditEvent, 0)
+	err := s.do(ctx, func(d *data) error {
+		for _, e := range d.auditEvents {
+			switch {
+			case filter.ActorID != nil && e.ActorID != *filter.ActorID,
+				filter.Action != "" && e.Action != filter.Action,
+				filter.TargetType != "" && e.TargetType != filter.TargetType,
+				filter.TargetID != "" && e.TargetID != filter.TargetID,
+				filter.From != nil && e.CreatedAt.Before(*filter.From),
+				filter.To != nil && !e.CreatedAt.Before(*filter.To):
+				continue
+			}
+
+			c := *e
+			c.ImpersonatorID = clonePtr(e.ImpersonatorID)
+			c.ImpersonatorUsername = clonePtr(e.ImpersonatorUsername)
+			events = append(events, &c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slices.SortFunc(events, func(a, b *models.AuditEvent) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), compareUUID(a.ID, b.ID))
+	})
+	return page(events, filter.Limit, filter.Offset), len(events), nil
+}
+
+func nullableJSON(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return slices.Clone(data)
+}
+
+// page applies LIMIT and OFFSET to rows.
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return rows[:0]
+	}
+	rows = rows[offset:]
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// LockJobRun needs no lock of its own, as a transaction already holds the
+// whole store.
+func (s *Store) LockJobRun(ctx context.Context, name string) (*models.JobRun, error) {
+	var run *models.JobRun
+	err := s.do(ctx, func(d *data) error {
+		r := find(d.jobRuns, func(r *models.JobRun) bool { return r.Name == name })
+		if r == nil {
+			r = &models.JobRun{Name: name}
+			d.jobRuns = append(d.jobRuns, r)
+		}
+
+		run = ptr(*r)
+		return nil
+	})
+	return run, err
+}
+
+func (s *Store) UpdateJobRun(ctx context.Context, name string, ranAt time.Time) error {
+	return s.do(ctx, func(d *data) error {
+		r := find(d.jobRuns, func(r *models.JobRun) bool { return r.Name == name })
+		if r == nil {
+			return sql.ErrNoRows
+		}
+
+		ranAt = ranAt.Truncate(time.Microsecond)
+		r.LastRunAt = &ranAt
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// SelectJWTSigningKeys returns the keys that have not expired, oldest first.
+func (s *Store) SelectJWTSigningKeys(ctx context.Context) ([]*models.JWTSigningKey, error) {
+	var keys []*models.JWTSigningKey
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		for _, k := range d.jwtSigningKeys {
+			if k.ExpiresAt.After(t) {
+				keys = append(keys, ptr(*k))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(keys, func(a, b *models.JWTSigningKey) int {
+		return cmp.Or(a.ActivatesAt.Compare(b.ActivatesAt), a.CreatedAt.Compare(b.CreatedAt))
+	})
+	return keys, err
+}
+
+func (s *Store) InsertJWTSigningKey(ctx context.Context, k *models.JWTSigningKey) error {
+	return s.do(ctx, func(d *data) error {
+		k.ID = uuid.New()
+		k.CreatedAt = now()
+
+		row := *k
+		row.PrivateKey = slices.Clone(k.PrivateKey)
+		row.PublicKey = slices.Clone(k.PublicKey)
+		d.jwtSigningKeys = append(d.jwtSigningKeys, &row)
+		return nil
+	})
+}
+
+func (s *Store) UpdateJWTSigningKeyPrivateKey(ctx context.Context, id uuid.UUID, privateKey []byte) error {
+	return s.do(ctx, func(d *data) error {
+		k := find(d.jwtSigningKeys, func(k *models.JWTSigningKey) bool { return k.ID == id })
+		if k == nil {
+			return sql.ErrNoRows
+		}
+
+		k.PrivateKey = slices.Clone(privateKey)
+		return nil
+	})
+}
+
+func (s *Store) DeleteExpiredJWTSigningKeys(ctx context.Context) error {
+	return s.do(ctx, func(d *data) error {
+		t := now()
+		d.jwtSigningKeys = slices.DeleteFunc(d.jwtSigningKeys, func(k *models.JWTSigningKey) bool {
+			return !k.ExpiresAt.After(t)
+		})
+		return nil
+	})
+}
+
+// LockJWTSigningKeys has nothing to lock, as a single process owns the store
+// and its transactions already run one at a time.
+func (s *Store) LockJWTSigningKeys(ctx context.Context) error {
+	return s.do(ctx, func(d *data) error { return nil })
+}
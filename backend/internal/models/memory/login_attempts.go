@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+func (d *data) loginAttempt(scope, key string) *models.LoginAttempt {
+	return find(d.loginAttempts, func(a *models.LoginAttempt) bool { return a.Scope == scope && a.Key == key })
+}
+
+func (s *Store) SelectLoginAttempt(ctx context.Context, scope, key string) (*models.LoginAttempt, error) {
+	var attempt *models.LoginAttempt
+	err := s.do(ctx, func(d *data) error {
+		a := d.loginAttempt(scope, key)
+		if a == nil {
+			return sql.ErrNoRows
+		}
+
+		attempt = ptr(*a)
+		attempt.LockedUntil = clonePtr(a.LockedUntil)
+		attempt.Now = now()
+		return nil
+	})
+	return attempt, err
+}
+
+// RecordLoginFailure increments the failure counter of a username or IP
+// address, starting over once the window has passed or a previous lock has
+// expired, and locks it for lockout once maxFailures is reached.
+func (s *Store) RecordLoginFailure(ctx context.Context, scope, key string, window, lockout time.Duration, maxFailures int) (*models.LoginAttempt, error) {
+	var attempt *models.LoginAttempt
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+
+		a := d.loginAttempt(scope, key)
+		switch {
+		case a == nil:
+			a = &models.LoginAttempt{Scope: scope, Key: key, Failures: 1}
+			d.loginAttempts = append(d.loginAttempts, a)
+		case a.LastFailureAt.Before(t.Add(-window)) || (a.LockedUntil != nil && !a.LockedUntil.After(t)):
+			a.Failures = 1
+		default:
+			a.Failures++
+		}
+		if a.LockedUntil != nil && !a.LockedUntil.After(t) {
+			a.LockedUntil = nil
+		}
+		a.LastFailureAt = t
+
+		if a.Failures >= maxFailures && a.LockedUntil == nil {
+			lockedUntil := t.Add(lockout)
+			a.LockedUntil = &lockedUntil
+			d.loginLockEvents = append(d.loginLockEvents, &models.LoginLockEvent{
+				ID:          uuid.New(),
+				Scope:       scope,
+				Key:         key,
+				Failures:    a.Failures,
+				LockedUntil: lockedUntil,
+				CreatedAt:   t,
+			})
+		}
+
+		attempt = ptr(*a)
+		attempt.LockedUntil = clonePtr(a.LockedUntil)
+		attempt.Now = t
+		return nil
+	})
+	return attempt, err
+}
+
+func (s *Store) DeleteLoginAttempt(ctx context.Context, scope, key string) error {
+	return s.do(ctx, func(d *data) error {
+		d.loginAttempts = slices.DeleteFunc(d.loginAttempts, func(a *models.LoginAttempt) bool {
+			return a.Scope == scope && a.Key == key
+		})
+		return nil
+	})
+}
+
+// UnlockLogin clears the failure counter of a username or IP address and
+// marks its active lock events as unlocked by the given user.
+func (s *Store) UnlockLogin(ctx context.Context, scope, key string, unlockedBy uuid.UUID) (int64, error) {
+	var unlocked int64
+	err := s.do(ctx, func(d *data) error {
+		d.loginAttempts = slices.DeleteFunc(d.loginAttempts, func(a *models.LoginAttempt) bool {
+			return a.Scope == scope && a.Key == key
+		})
+
+		t := now()
+		for _, e := range d.loginLockEvents {
+			if e.Scope == scope && e.Key == key && lockActive(e, t) {
+				e.UnlockedAt = &t
+				e.UnlockedBy = &unlockedBy
+				unlocked++
+			}
+		}
+		return nil
+	})
+	return unlocked, err
+}
+
+func lockActive(e *models.LoginLockEvent, now time.Time) bool {
+	return e.UnlockedAt == nil && e.LockedUntil.After(now)
+}
+
+func copyLoginLockEvent(e *models.LoginLockEvent, now time.Time) *models.LoginLockEvent {
+	c := *e
+	c.UnlockedAt = clonePtr(e.UnlockedAt)
+	c.UnlockedBy = clonePtr(e.UnlockedBy)
+	c.Active = lockActive(e, now)
+	return &c
+}
+
+func (s *Store) SelectLoginLockEventByID(ctx context.Context, id uuid.UUID) (*models.LoginLockEvent, error) {
+	var event *models.LoginLockEvent
+	err := s.do(ctx, func(d *data) error {
+		e := find(d.loginLockEvents, func(e *models.LoginLockEvent) bool { return e.ID == id })
+		if e == nil {
+			return sql.ErrNoRows
+		}
+
+		event = copyLoginLockEvent(e, now())
+		return nil
+	})
+	return event, err
+}
+
+func (s *Store) SelectLoginLockEvents(ctx context.Context, filter *models.LoginLockEventFilter) ([]*models.LoginLockEvent, int, error) {
+	events := make([]*models.LoginLockEvent, 0)
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		for _, e := range d.loginLockEvents {
+			switch {
+			case filter.Scope != "" && e.Scope != filter.Scope,
+				filter.Key != "" && e.Key != filter.Key,
+				filter.ActiveOnly && !lockActive(e, t):
+				continue
+			}
+			events = append(events, copyLoginLockEvent(e, t))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slices.SortFunc(events, func(a, b *models.LoginLockEvent) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), compareUUID(a.ID, b.ID))
+	})
+	return page(events, filter.Limit, filter.Offset), len(events), nil
+}
@@ -0,0 +1,252 @@
+// Package memory is a models.Store that keeps everything in memory, so that
+// the api runs in development and handlers can be tested without Postgres.
+// Its data starts out with the rows inserted by the migrations and is lost
+// on restart.
+//
+// Methods behave like their Postgres counterparts in package models,
+// including the constraints they violate. A transaction holds the store
+// exclusively until it ends, so calls made inside WithTx must go through
+// the store passed to fn.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+var _ models.Store = (*Store)(nil)
+
+type state struct {
+	mu   sync.Mutex
+	data *data
+
+	// listeners are kept apart from the data, so that listening does not
+	// wait for transactions
+	listenersMu sync.Mutex
+	listeners   map[string][]*listener
+}
+
+type Store struct {
+	state *state
+
+	// tx is the working copy of a transaction, or nil outside of one
+	tx   *data
+	done bool
+}
+
+func New() *Store {
+	return &Store{
+		state: &state{
+			data:      seed(),
+			listeners: make(map[string][]*listener),
+		},
+	}
+}
+
+// do runs fn against the data of the transaction, or otherwise against the
+// committed data while holding the store.
+func (s *Store) do(ctx context.Context, fn func(d *data) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.done {
+		return sql.ErrTxDone
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	return fn(s.state.data)
+}
+
+// WithTx runs fn against a copy of the data, which replaces the data only if
+// fn succeeds. Calls nested inside an existing transaction join it.
+func (s *Store) WithTx(ctx context.Context, fn func(m models.Store) error) error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	tx := &Store{state: s.state, tx: s.state.data.clone()}
+	defer func() {
+		tx.tx, tx.done = nil, true
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	// like Postgres, a transaction whose context is done rolls back
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// notifications go out once their transaction has committed
+	committed := tx.tx
+	pending := committed.notifications
+	committed.notifications = nil
+	s.state.data = committed
+	for _, n := range pending {
+		s.state.notify(n.channel, n.payload)
+	}
+
+	return nil
+}
+
+func (s *Store) Savepoint(ctx context.Context, name string, fn func(m models.Store) error) error {
+	if s.tx == nil {
+		return errors.New("savepoint must be used within a transaction")
+	}
+
+	sp := &Store{state: s.state, tx: s.tx.clone()}
+	defer func() {
+		sp.tx, sp.done = nil, true
+	}()
+
+	if err := fn(sp); err != nil {
+		return err
+	}
+
+	s.tx = sp.tx
+	return nil
+}
+
+type data struct {
+	roles               []*models.Role
+	permissions         []*models.Permission
+	rolePermissions     []rolePermission
+	users               []*user
+	userTOTP            []*models.UserTOTP
+	recoveryCodes       []*recoveryCode
+	sessions            []*session
+	apiTokens           []*apiToken
+	auditEvents         []*models.AuditEvent
+	loginAttempts       []*models.LoginAttempt
+	loginLockEvents     []*models.LoginLockEvent
+	passwordResetTokens []*models.PasswordResetToken
+	userInvitations     []*models.UserInvitation
+	jwtSigningKeys      []*models.JWTSigningKey
+	scheduleTemplates   []*models.ScheduleTemplate
+	schedulePlans       []*models.SchedulePlan
+	assignments         []*assignment
+	jobRuns             []*models.JobRun
+
+	// notifications are sent by a transaction but not yet delivered
+	notifications []notification
+}
+
+// clone copies every row, so that a transaction may change rows in place.
+// Slices and pointers inside rows are shared, and are replaced rather than
+// modified.
+func (d *data) clone() *data {
+	return &data{
+		roles:               cloneRows(d.roles),
+		permissions:         cloneRows(d.permissions),
+		rolePermissions:     append([]rolePermission(nil), d.rolePermissions...),
+		users:               cloneRows(d.users),
+		userTOTP:            cloneRows(d.userTOTP),
+		recoveryCodes:       cloneRows(d.recoveryCodes),
+		sessions:            cloneRows(d.sessions),
+		apiTokens:           cloneRows(d.apiTokens),
+		auditEvents:         cloneRows(d.auditEvents),
+		loginAttempts:       cloneRows(d.loginAttempts),
+		loginLockEvents:     cloneRows(d.loginLockEvents),
+		passwordResetTokens: cloneRows(d.passwordResetTokens),
+		userInvitations:     cloneRows(d.userInvitations),
+		jwtSigningKeys:      cloneRows(d.jwtSigningKeys),
+		scheduleTemplates:   cloneRows(d.scheduleTemplates),
+		schedulePlans:       cloneRows(d.schedulePlans),
+		assignments:         cloneRows(d.assignments),
+		jobRuns:             cloneRows(d.jobRuns),
+		notifications:       append([]notification(nil), d.notifications...),
+	}
+}
+
+func cloneRows[T any](rows []*T) []*T {
+	c := make([]*T, len(rows))
+	for i, row := range rows {
+		copied := *row
+		c[i] = &copied
+	}
+	return c
+}
+
+func find[T any](rows []*T, match func(row *T) bool) *T {
+	for _, row := range rows {
+		if match(row) {
+			return row
+		}
+	}
+	return nil
+}
+
+// now truncates to the precision of TIMESTAMPTZ, which also drops the
+// monotonic clock, so that times compare like the ones read from Postgres.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func compareUUID(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// the errors of Postgres for the constraints the handlers look for
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23503",
+		Message:        fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+func notNullViolation(table, column string) error {
+	return &pgconn.PgError{
+		Severity:   "ERROR",
+		Code:       "23502",
+		Message:    fmt.Sprintf("null value in column %q of relation %q violates not-null constraint", column, table),
+		TableName:  table,
+		ColumnName: column,
+	}
+}
+
+func checkViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23514",
+		Message:        fmt.Sprintf("new row for relation %q violates check constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// notification is sent by a transaction, and delivered once it commits.
+type notification struct {
+	channel string
+	payload string
+}
+
+type listener struct {
+	mu       sync.Mutex
+	payloads []string
+	ready    chan struct{}
+}
+
+func (l *listener) push(payload string) {
+	l.mu.Lock()
+	l.payloads = append(l.payloads, payload)
+	l.mu.Unlock()
+
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (l *listener) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	payloads := l.payloads
+	l.payloads = nil
+	return payloads
+}
+
+func (st *state) notify(channel, payload string) {
+	st.listenersMu.Lock()
+	defer st.listenersMu.Unlock()
+
+	for _, l := range st.listeners[channel] {
+		l.push(payload)
+	}
+}
+
+func (s *Store) Notify(ctx context.Context, channel, payload string) error {
+	return s.do(ctx, func(d *data) error {
+		if s.tx != nil {
+			d.notifications = append(d.notifications, notification{channel: channel, payload: payload})
+			return nil
+		}
+		s.state.notify(channel, payload)
+		return nil
+	})
+}
+
+func (s *Store) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	l := &listener{ready: make(chan struct{}, 1)}
+
+	s.state.listenersMu.Lock()
+	s.state.listeners[channel] = append(s.state.listeners[channel], l)
+	s.state.listenersMu.Unlock()
+
+	defer func() {
+		s.state.listenersMu.Lock()
+		s.state.listeners[channel] = slices.DeleteFunc(s.state.listeners[channel], func(other *listener) bool { return other == l })
+		s.state.listenersMu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.ready:
+			for _, payload := range l.take() {
+				fn(payload)
+			}
+		}
+	}
+}
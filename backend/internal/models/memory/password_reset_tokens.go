@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// InsertPasswordResetToken stores a new token and invalidates the unused ones
+// previously issued to the same user, so only the latest link works.
+func (s *Store) InsertPasswordResetToken(ctx context.Context, t *models.PasswordResetToken) error {
+	return s.do(ctx, func(d *data) error {
+		if find(d.passwordResetTokens, func(row *models.PasswordResetToken) bool { return row.TokenHash == t.TokenHash }) != nil {
+			return uniqueViolation("password_reset_tokens_token_hash_key")
+		}
+
+		current := now()
+		for _, row := range d.passwordResetTokens {
+			if row.UserID == t.UserID && row.UsedAt == nil {
+				row.UsedAt = &current
+			}
+		}
+
+		t.ID = uuid.New()
+		t.CreatedAt = current
+		d.passwordResetTokens = append(d.passwordResetTokens, &models.PasswordResetToken{
+			ID:        t.ID,
+			UserID:    t.UserID,
+			TokenHash: t.TokenHash,
+			ExpiresAt: t.ExpiresAt.Truncate(time.Microsecond),
+			CreatedAt: t.CreatedAt,
+		})
+		return nil
+	})
+}
+
+// ConsumePasswordResetToken marks an unused and unexpired token as used and
+// returns the ID of its user, or sql.ErrNoRows if no such token exists.
+func (s *Store) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.do(ctx, func(d *data) error {
+		current := now()
+		row := find(d.passwordResetTokens, func(row *models.PasswordResetToken) bool {
+			return row.TokenHash == tokenHash && row.UsedAt == nil && row.ExpiresAt.After(current)
+		})
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.UsedAt = &current
+		userID = row.UserID
+		return nil
+	})
+	return userID, err
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+type rolePermission struct {
+	roleID       uuid.UUID
+	permissionID uuid.UUID
+}
+
+func (s *Store) SelectAllPermissions(ctx context.Context) ([]*models.Permission, error) {
+	var permissions []*models.Permission
+	err := s.do(ctx, func(d *data) error {
+		permissions = cloneRows(d.permissions)
+		return nil
+	})
+	slices.SortFunc(permissions, func(a, b *models.Permission) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return permissions, err
+}
+
+func (s *Store) SelectPermissionsByRoleName(ctx context.Context, roleName string) ([]string, error) {
+	permissions := make([]string, 0)
+	err := s.do(ctx, func(d *data) error {
+		role := d.roleByName(roleName)
+		if role == nil {
+			return nil
+		}
+		for _, p := range d.permissions {
+			if d.roleHasPermission(role.ID, p.Name) {
+				permissions = append(permissions, p.Name)
+			}
+		}
+		return nil
+	})
+	slices.Sort(permissions)
+	return permissions, err
+}
+
+func (s *Store) UpdateRolePermissions(ctx context.Context, roleID uuid.UUID, permissions []string) error {
+	return s.do(ctx, func(d *data) error {
+		if d.roleByID(roleID) == nil && len(permissions) > 0 {
+			return foreignKeyViolation("role_permissions", "role_permissions_role_id_fkey")
+		}
+
+		d.rolePermissions = slices.DeleteFunc(d.rolePermissions, func(rp rolePermission) bool { return rp.roleID == roleID })
+		for _, p := range d.permissions {
+			if slices.Contains(permissions, p.Name) {
+				d.rolePermissions = append(d.rolePermissions, rolePermission{roleID: roleID, permissionID: p.ID})
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) GrantAllPermissions(ctx context.Context, roleID uuid.UUID) error {
+	return s.do(ctx, func(d *data) error {
+		if d.roleByID(roleID) == nil {
+			return foreignKeyViolation("role_permissions", "role_permissions_role_id_fkey")
+		}
+
+		for _, p := range d.permissions {
+			if !d.roleHasPermission(roleID, p.Name) {
+				d.rolePermissions = append(d.rolePermissions, rolePermission{roleID: roleID, permissionID: p.ID})
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+func (s *Store) InsertRole(ctx context.Context, role *models.Role) error {
+	return s.do(ctx, func(d *data) error {
+		if d.roleByName(role.Name) != nil {
+			return uniqueViolation("roles_name_key")
+		}
+
+		role.ID = uuid.New()
+		role.CreatedAt = now()
+		role.Version = 1
+		d.roles = append(d.roles, &models.Role{
+			ID:          role.ID,
+			Name:        role.Name,
+			Level:       role.Level,
+			Description: role.Description,
+			CreatedAt:   role.CreatedAt,
+			Version:     role.Version,
+		})
+		return nil
+	})
+}
+
+func (s *Store) SelectRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error) {
+	var role *models.Role
+	err := s.do(ctx, func(d *data) error {
+		r := d.roleByID(id)
+		if r == nil {
+			return sql.ErrNoRows
+		}
+		role = ptr(*r)
+		return nil
+	})
+	return role, err
+}
+
+func (s *Store) SelectRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	var role *models.Role
+	err := s.do(ctx, func(d *data) error {
+		r := d.roleByName(name)
+		if r == nil {
+			return sql.ErrNoRows
+		}
+		role = ptr(*r)
+		return nil
+	})
+	return role, err
+}
+
+func (s *Store) SelectAllRoles(ctx context.Context) ([]*models.Role, error) {
+	var roles []*models.Role
+	err := s.do(ctx, func(d *data) error {
+		roles = cloneRows(d.roles)
+		return nil
+	})
+	slices.SortFunc(roles, func(a, b *models.Role) int {
+		return cmp.Or(cmp.Compare(a.Level, b.Level), cmp.Compare(a.Name, b.Name))
+	})
+	return roles, err
+}
+
+func (s *Store) UpdateRole(ctx context.Context, role *models.Role) error {
+	return s.do(ctx, func(d *data) error {
+		r := find(d.roles, func(r *models.Role) bool { return r.ID == role.ID && r.Version == role.Version })
+		if r == nil {
+			return sql.ErrNoRows
+		}
+		if other := d.roleByName(role.Name); other != nil && other.ID != r.ID {
+			return uniqueViolation("roles_name_key")
+		}
+
+		r.Name = role.Name
+		r.Level = role.Level
+		r.Description = role.Description
+		r.Version++
+		role.Version = r.Version
+		return nil
+	})
+}
+
+func (s *Store) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	return s.do(ctx, func(d *data) error {
+		if d.roleByID(id) == nil {
+			return sql.ErrNoRows
+		}
+		if find(d.users, func(u *user) bool { return u.roleID == id }) != nil {
+			return foreignKeyViolation("users", "users_role_id_fkey")
+		}
+
+		d.roles = slices.DeleteFunc(d.roles, func(r *models.Role) bool { return r.ID == id })
+		d.rolePermissions = slices.DeleteFunc(d.rolePermissions, func(rp rolePermission) bool { return rp.roleID == id })
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+type assignment struct {
+	planID    uuid.UUID
+	shiftID   uuid.UUID
+	dayOfWeek int32
+	userID    uuid.UUID
+}
+
+func (s *Store) InsertSchedulePlan(ctx context.Context, sp *models.SchedulePlan) error {
+	return s.do(ctx, func(d *data) error {
+		if find(d.schedulePlans, func(other *models.SchedulePlan) bool { return other.Name == sp.Name }) != nil {
+			return uniqueViolation("schedule_plans_name_key")
+		}
+		if find(d.scheduleTemplates, func(st *models.ScheduleTemplate) bool { return st.Name == sp.ScheduleTemplateName }) == nil {
+			return foreignKeyViolation("schedule_plans", "schedule_plans_schedule_template_name_fkey")
+		}
+
+		sp.ID = uuid.New()
+		sp.CreatedAt = now()
+		sp.Version = 1
+
+		row := *sp
+		row.SubmissionStartTime = sp.SubmissionStartTime.Truncate(time.Microsecond)
+		row.SubmissionEndTime = sp.SubmissionEndTime.Truncate(time.Microsecond)
+		row.ActiveStartTime = sp.ActiveStartTime.Truncate(time.Microsecond)
+		row.ActiveEndTime = sp.ActiveEndTime.Truncate(time.Microsecond)
+		d.schedulePlans = append(d.schedulePlans, &row)
+		return nil
+	})
+}
+
+func (s *Store) SelectSchedulePlanByID(ctx context.Context, id uuid.UUID) (*models.SchedulePlan, error) {
+	var plan *models.SchedulePlan
+	err := s.do(ctx, func(d *data) error {
+		sp := find(d.schedulePlans, func(sp *models.SchedulePlan) bool { return sp.ID == id })
+		if sp == nil {
+			return sql.ErrNoRows
+		}
+
+		plan = ptr(*sp)
+		plan.PublishedAt = clonePtr(sp.PublishedAt)
+		return nil
+	})
+	return plan, err
+}
+
+func (s *Store) PublishSchedulePlan(ctx context.Context, sp *models.SchedulePlan) error {
+	return s.do(ctx, func(d *data) error {
+		row := find(d.schedulePlans, func(row *models.SchedulePlan) bool {
+			return row.ID == sp.ID && row.Version == sp.Version && row.PublishedAt == nil
+		})
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.PublishedAt = ptr(now())
+		row.Version++
+		sp.PublishedAt = clonePtr(row.PublishedAt)
+		sp.Version = row.Version
+		return nil
+	})
+}
+
+func (s *Store) SelectSchedulePlanAssignments(ctx context.Context, schedulePlanID uuid.UUID) ([]*models.SchedulePlanAssignment, error) {
+	assignments := make([]*models.SchedulePlanAssignment, 0)
+	err := s.do(ctx, func(d *data) error {
+		for _, a := range d.assignments {
+			if a.planID != schedulePlanID {
+				continue
+			}
+			u := find(d.users, func(u *user) bool { return u.ID == a.userID })
+			assignments = append(assignments, &models.SchedulePlanAssignment{
+				ScheduleTemplateShiftID: a.shiftID,
+				DayOfWeek:               a.dayOfWeek,
+				UserID:                  a.userID,
+				FullName:                u.FullName,
+			})
+		}
+		return nil
+	})
+	slices.SortFunc(assignments, func(a, b *models.SchedulePlanAssignment) int {
+		return cmp.Compare(a.FullName, b.FullName)
+	})
+	return assignments, err
+}
+
+func (s *Store) ReplaceSchedulePlanAssignments(ctx context.Context, schedulePlanID uuid.UUID, assignments []*models.SchedulePlanAssignment) error {
+	return s.do(ctx, func(d *data) error {
+		if find(d.schedulePlans, func(sp *models.SchedulePlan) bool { return sp.ID == schedulePlanID }) == nil {
+			return foreignKeyViolation("schedule_plan_assignments", "schedule_plan_assignments_schedule_plan_id_fkey")
+		}
+
+		rows := make([]*assignment, 0, len(assignments))
+		for _, a := range assignments {
+			if !slices.ContainsFunc(d.scheduleTemplates, func(st *models.ScheduleTemplate) bool {
+				return slices.ContainsFunc(st.Shifts, func(shift *models.ScheduleTemplateShift) bool { return shift.ID == a.ScheduleTemplateShiftID })
+			}) {
+				return foreignKeyViolation("schedule_plan_assignments", "schedule_plan_assignments_schedule_template_shift_id_fkey")
+			}
+			if find(d.users, func(u *user) bool { return u.ID == a.UserID }) == nil {
+				return foreignKeyViolation("schedule_plan_assignments", "schedule_plan_assignments_user_id_fkey")
+			}
+
+			row := &assignment{planID: schedulePlanID, shiftID: a.ScheduleTemplateShiftID, dayOfWeek: a.DayOfWeek, userID: a.UserID}
+			if find(rows, func(other *assignment) bool { return *other == *row }) != nil {
+				// Postgres truncates the generated name to 63 bytes
+				return uniqueViolation("schedule_plan_assignments_schedule_plan_id_schedule_templat_key")
+			}
+			rows = append(rows, row)
+		}
+
+		d.assignments = append(
+			slices.DeleteFunc(d.assignments, func(a *assignment) bool { return a.planID == schedulePlanID }),
+			rows...,
+		)
+		return nil
+	})
+}
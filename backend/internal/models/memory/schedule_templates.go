@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+func copyShifts(shifts []*models.ScheduleTemplateShift) []*models.ScheduleTemplateShift {
+	c := make([]*models.ScheduleTemplateShift, 0, len(shifts))
+	for _, shift := range shifts {
+		copied := *shift
+		copied.ApplicableDays = append(make([]int32, 0, len(shift.ApplicableDays)), shift.ApplicableDays...)
+		c = append(c, &copied)
+	}
+	return c
+}
+
+func (d *data) scheduleTemplate(match func(st *models.ScheduleTemplate) bool) (*models.ScheduleTemplate, error) {
+	st := find(d.scheduleTemplates, match)
+	if st == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	c := *st
+	c.Shifts = copyShifts(st.Shifts)
+	return &c, nil
+}
+
+func (s *Store) InsertScheduleTemplate(ctx context.Context, st *models.ScheduleTemplate) error {
+	return s.do(ctx, func(d *data) error {
+		if find(d.scheduleTemplates, func(other *models.ScheduleTemplate) bool { return other.Name == st.Name }) != nil {
+			return uniqueViolation("schedule_templates_name_key")
+		}
+
+		st.ID = uuid.New()
+		st.CreatedAt = now()
+		st.Version = 1
+		for _, shift := range st.Shifts {
+			shift.ID = uuid.New()
+		}
+
+		// kept in the order that Postgres reads them back
+		shifts := copyShifts(st.Shifts)
+		for _, shift := range shifts {
+			slices.Sort(shift.ApplicableDays)
+		}
+		slices.SortFunc(shifts, func(a, b *models.ScheduleTemplateShift) int {
+			return cmp.Or(
+				cmp.Compare(a.StartTime, b.StartTime),
+				cmp.Compare(a.EndTime, b.EndTime),
+				compareUUID(a.ID, b.ID),
+			)
+		})
+
+		d.scheduleTemplates = append(d.scheduleTemplates, &models.ScheduleTemplate{
+			ID:          st.ID,
+			Name:        st.Name,
+			Description: st.Description,
+			Shifts:      shifts,
+			CreatedAt:   st.CreatedAt,
+			Version:     st.Version,
+		})
+		return nil
+	})
+}
+
+func (s *Store) SelectScheduleTemplate(ctx context.Context, id uuid.UUID) (*models.ScheduleTemplate, error) {
+	var st *models.ScheduleTemplate
+	err := s.do(ctx, func(d *data) (err error) {
+		st, err = d.scheduleTemplate(func(st *models.ScheduleTemplate) bool { return st.ID == id })
+		return err
+	})
+	return st, err
+}
+
+func (s *Store) SelectScheduleTemplateByName(ctx context.Context, name string) (*models.ScheduleTemplate, error) {
+	var st *models.ScheduleTemplate
+	err := s.do(ctx, func(d *data) (err error) {
+		st, err = d.scheduleTemplate(func(st *models.ScheduleTemplate) bool { return st.Name == name })
+		return err
+	})
+	return st, err
+}
+
+func (s *Store) SelectAllScheduleTemplateMeta(ctx context.Context) ([]*models.ScheduleTemplate, error) {
+	sts := make([]*models.ScheduleTemplate, 0)
+	err := s.do(ctx, func(d *data) error {
+		for _, st := range d.scheduleTemplates {
+			c := *st
+			c.Shifts = make([]*models.ScheduleTemplateShift, 0)
+			sts = append(sts, &c)
+		}
+		return nil
+	})
+	return sts, err
+}
+
+func (s *Store) DeleteScheduleTemplate(ctx context.Context, id uuid.UUID) error {
+	return s.do(ctx, func(d *data) error {
+		st := find(d.scheduleTemplates, func(st *models.ScheduleTemplate) bool { return st.ID == id })
+		if st == nil {
+			return sql.ErrNoRows
+		}
+		if find(d.schedulePlans, func(sp *models.SchedulePlan) bool { return sp.ScheduleTemplateName == st.Name }) != nil {
+			return foreignKeyViolation("schedule_plans", "schedule_plans_schedule_template_name_fkey")
+		}
+
+		// the shifts go with the template, and the assignments with them
+		d.assignments = slices.DeleteFunc(d.assignments, func(a *assignment) bool {
+			return slices.ContainsFunc(st.Shifts, func(shift *models.ScheduleTemplateShift) bool { return shift.ID == a.shiftID })
+		})
+		d.scheduleTemplates = slices.DeleteFunc(d.scheduleTemplates, func(st *models.ScheduleTemplate) bool { return st.ID == id })
+		return nil
+	})
+}
+
+func (s *Store) UpdateScheduleTemplateDescription(ctx context.Context, id uuid.UUID, description string) (*models.ScheduleTemplate, error) {
+	var updated *models.ScheduleTemplate
+	err := s.do(ctx, func(d *data) error {
+		st := find(d.scheduleTemplates, func(st *models.ScheduleTemplate) bool { return st.ID == id })
+		if st == nil {
+			return sql.ErrNoRows
+		}
+
+		st.Description = description
+		updated = &models.ScheduleTemplate{
+			ID:          st.ID,
+			Name:        st.Name,
+			Description: st.Description,
+			CreatedAt:   st.CreatedAt,
+			Version:     st.Version,
+		}
+		return nil
+	})
+	return updated, err
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// seed returns the roles and permissions that the migrations insert.
+func seed() *data {
+	d := &data{}
+
+	createdAt := now()
+	for _, r := range []struct {
+		name  string
+		level int32
+	}{
+		{"普通助理", 1},
+		{"资深助理", 2},
+		{"黑心", 3},
+	} {
+		d.roles = append(d.roles, &models.Role{
+			ID:        uuid.New(),
+			Name:      r.name,
+			Level:     r.level,
+			CreatedAt: createdAt,
+			Version:   1,
+		})
+	}
+
+	for _, p := range []struct {
+		name        string
+		description string
+	}{
+		{models.PermissionUsersManage, "管理用户"},
+		{models.PermissionRolesManage, "管理角色与权限"},
+		{models.PermissionTemplatesEdit, "编辑班表模板"},
+		{models.PermissionPlansEdit, "编辑排班计划"},
+		{models.PermissionAttendanceReview, "审核考勤"},
+		{models.PermissionAuditView, "查看审计日志"},
+		{models.PermissionUsersImpersonate, "以其他用户身份查看系统"},
+	} {
+		d.permissions = append(d.permissions, &models.Permission{
+			ID:          uuid.New(),
+			Name:        p.name,
+			Description: p.description,
+		})
+	}
+
+	// the top level holds every permission, the level below only reviews
+	// attendance
+	for _, r := range d.roles {
+		for _, p := range d.permissions {
+			if r.Level >= 3 || (r.Level == 2 && p.Name == models.PermissionAttendanceReview) {
+				d.rolePermissions = append(d.rolePermissions, rolePermission{roleID: r.ID, permissionID: p.ID})
+			}
+		}
+	}
+
+	return d
+}
+
+func (d *data) roleByName(name string) *models.Role {
+	return find(d.roles, func(r *models.Role) bool { return r.Name == name })
+}
+
+func (d *data) roleByID(id uuid.UUID) *models.Role {
+	return find(d.roles, func(r *models.Role) bool { return r.ID == id })
+}
+
+// roleHasPermission reports whether the role grants the permission name.
+func (d *data) roleHasPermission(roleID uuid.UUID, name string) bool {
+	p := find(d.permissions, func(p *models.Permission) bool { return p.Name == name })
+	return p != nil && slices.Contains(d.rolePermissions, rolePermission{roleID: roleID, permissionID: p.ID})
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+type session struct {
+	models.Session
+	revokedAt *time.Time
+}
+
+func (s *session) active(now time.Time) bool {
+	return s.revokedAt == nil && s.ExpiresAt.After(now)
+}
+
+func (s *Store) InsertSession(ctx context.Context, sess *models.Session) error {
+	return s.do(ctx, func(d *data) error {
+		sess.ID = uuid.New()
+		sess.CreatedAt = now()
+		sess.LastSeenAt = sess.CreatedAt
+
+		d.sessions = append(d.sessions, &session{
+			Session: models.Session{
+				ID:                    sess.ID,
+				UserID:                sess.UserID,
+				ImpersonatorID:        clonePtr(sess.ImpersonatorID),
+				ImpersonatorSessionID: clonePtr(sess.ImpersonatorSessionID),
+				IPAddress:             sess.IPAddress,
+				UserAgent:             sess.UserAgent,
+				CreatedAt:             sess.CreatedAt,
+				LastSeenAt:            sess.LastSeenAt,
+				ExpiresAt:             sess.ExpiresAt.Truncate(time.Microsecond),
+			},
+		})
+		return nil
+	})
+}
+
+// TouchSession refreshes the last seen time of an unrevoked and unexpired
+// session and returns it, or sql.ErrNoRows if no such session exists.
+func (s *Store) TouchSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var touched *models.Session
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		row := find(d.sessions, func(row *session) bool { return row.ID == id && row.active(t) })
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.LastSeenAt = t
+		touched = row.copy()
+		return nil
+	})
+	return touched, err
+}
+
+func (s *Store) SelectActiveSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	sessions := make([]*models.Session, 0)
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		for _, row := range d.sessions {
+			if row.UserID == userID && row.ImpersonatorID == nil && row.active(t) {
+				sessions = append(sessions, row.copy())
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(sessions, func(a, b *models.Session) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+	return sessions, err
+}
+
+func (s *Store) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	return s.do(ctx, func(d *data) error {
+		t := now()
+		row := find(d.sessions, func(row *session) bool { return row.ID == id && row.UserID == userID && row.active(t) })
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.revokedAt = &t
+		return nil
+	})
+}
+
+func (s *Store) RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID) (int64, error) {
+	var revoked int64
+	err := s.do(ctx, func(d *data) error {
+		t := now()
+		for _, row := range d.sessions {
+			if row.UserID == userID && row.ID != keep && row.active(t) {
+				row.revokedAt = &t
+				revoked++
+			}
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+func (s *session) copy() *models.Session {
+	c := s.Session
+	c.ImpersonatorID = clonePtr(s.ImpersonatorID)
+	c.ImpersonatorSessionID = clonePtr(s.ImpersonatorSessionID)
+	return &c
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// InsertUserInvitation stores a new invitation and revokes the outstanding
+// ones of the same user, so only the latest link works.
+func (s *Store) InsertUserInvitation(ctx context.Context, inv *models.UserInvitation) error {
+	return s.do(ctx, func(d *data) error {
+		if find(d.userInvitations, func(row *models.UserInvitation) bool { return row.TokenHash == inv.TokenHash }) != nil {
+			return uniqueViolation("user_invitations_token_hash_key")
+		}
+
+		current := now()
+		d.revokeUserInvitations(inv.UserID, current)
+
+		inv.ID = uuid.New()
+		inv.CreatedAt = current
+		d.userInvitations = append(d.userInvitations, &models.UserInvitation{
+			ID:        inv.ID,
+			UserID:    inv.UserID,
+			TokenHash: inv.TokenHash,
+			ExpiresAt: inv.ExpiresAt.Truncate(time.Microsecond),
+			CreatedAt: inv.CreatedAt,
+		})
+		return nil
+	})
+}
+
+// RevokeUserInvitations revokes the unused invitations of a user and returns
+// how many were revoked.
+func (s *Store) RevokeUserInvitations(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var revoked int64
+	err := s.do(ctx, func(d *data) error {
+		revoked = d.revokeUserInvitations(userID, now())
+		return nil
+	})
+	return revoked, err
+}
+
+func (d *data) revokeUserInvitations(userID uuid.UUID, at time.Time) int64 {
+	var revoked int64
+	for _, row := range d.userInvitations {
+		if row.UserID == userID && row.UsedAt == nil && row.RevokedAt == nil {
+			row.RevokedAt = &at
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// ConsumeUserInvitation marks a valid invitation as used and returns the ID
+// of the invited user, or sql.ErrNoRows if no such invitation exists.
+func (s *Store) ConsumeUserInvitation(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.do(ctx, func(d *data) error {
+		current := now()
+		row := find(d.userInvitations, func(row *models.UserInvitation) bool {
+			return row.TokenHash == tokenHash && row.UsedAt == nil && row.RevokedAt == nil && row.ExpiresAt.After(current)
+		})
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.UsedAt = &current
+		userID = row.UserID
+		return nil
+	})
+	return userID, err
+}
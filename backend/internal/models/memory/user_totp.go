@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+type recoveryCode struct {
+	userID   uuid.UUID
+	codeHash string
+	usedAt   *time.Time
+}
+
+func (d *data) userTOTPByUserID(userID uuid.UUID) *models.UserTOTP {
+	return find(d.userTOTP, func(t *models.UserTOTP) bool { return t.UserID == userID })
+}
+
+func (s *Store) SelectUserTOTP(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	var totp *models.UserTOTP
+	err := s.do(ctx, func(d *data) error {
+		t := d.userTOTPByUserID(userID)
+		if t == nil {
+			return sql.ErrNoRows
+		}
+
+		totp = ptr(*t)
+		totp.EnabledAt = clonePtr(t.EnabledAt)
+		return nil
+	})
+	return totp, err
+}
+
+// UpsertPendingUserTOTP starts a new enrollment, replacing any pending secret.
+// It returns sql.ErrNoRows if the user already has TOTP enabled.
+func (s *Store) UpsertPendingUserTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	return s.do(ctx, func(d *data) error {
+		t := d.userTOTPByUserID(userID)
+		switch {
+		case t == nil:
+			if find(d.users, func(u *user) bool { return u.ID == userID }) == nil {
+				return foreignKeyViolation("user_totp", "user_totp_user_id_fkey")
+			}
+			d.userTOTP = append(d.userTOTP, &models.UserTOTP{UserID: userID, Secret: secret})
+		case t.EnabledAt != nil:
+			return sql.ErrNoRows
+		default:
+			t.Secret = secret
+			t.LastUsedStep = 0
+		}
+		return nil
+	})
+}
+
+// EnableUserTOTP enables a pending secret once a code at step has been
+// verified against it.
+func (s *Store) EnableUserTOTP(ctx context.Context, userID uuid.UUID, step int64) error {
+	return s.do(ctx, func(d *data) error {
+		t := d.userTOTPByUserID(userID)
+		if t == nil || t.EnabledAt != nil {
+			return sql.ErrNoRows
+		}
+
+		t.EnabledAt = ptr(now())
+		t.LastUsedStep = step
+		return nil
+	})
+}
+
+// UseUserTOTPStep records that the code at step has been used, returning
+// sql.ErrNoRows if it or a later one was already used.
+func (s *Store) UseUserTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	return s.do(ctx, func(d *data) error {
+		t := d.userTOTPByUserID(userID)
+		if t == nil || t.EnabledAt == nil || t.LastUsedStep >= step {
+			return sql.ErrNoRows
+		}
+
+		t.LastUsedStep = step
+		return nil
+	})
+}
+
+// DeleteUserTOTP turns two-factor authentication off for a user, removing
+// the secret and the recovery codes.
+func (s *Store) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	return s.do(ctx, func(d *data) error {
+		d.recoveryCodes = slices.DeleteFunc(d.recoveryCodes, func(c *recoveryCode) bool { return c.userID == userID })
+		d.userTOTP = slices.DeleteFunc(d.userTOTP, func(t *models.UserTOTP) bool { return t.UserID == userID })
+		return nil
+	})
+}
+
+// ReplaceRecoveryCodes discards every recovery code of a user and stores the
+// given hashes instead.
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return s.do(ctx, func(d *data) error {
+		d.recoveryCodes = slices.DeleteFunc(d.recoveryCodes, func(c *recoveryCode) bool { return c.userID == userID })
+		for _, codeHash := range codeHashes {
+			d.recoveryCodes = append(d.recoveryCodes, &recoveryCode{
+				userID:   userID,
+				codeHash: codeHash,
+			})
+		}
+		return nil
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used, returning
+// sql.ErrNoRows if the user has no such code.
+func (s *Store) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	return s.do(ctx, func(d *data) error {
+		c := find(d.recoveryCodes, func(c *recoveryCode) bool {
+			return c.userID == userID && c.codeHash == codeHash && c.usedAt == nil
+		})
+		if c == nil {
+			return sql.ErrNoRows
+		}
+
+		c.usedAt = ptr(now())
+		return nil
+	})
+}
+
+func (s *Store) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := s.do(ctx, func(d *data) error {
+		for _, c := range d.recoveryCodes {
+			if c.userID == userID && c.usedAt == nil {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
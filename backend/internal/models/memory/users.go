@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// user is a row of the users table. The role and whether two-factor
+// authentication is enabled are joined in by toUser.
+type user struct {
+	models.User
+	roleID           uuid.UUID
+	fullNameInitials string
+}
+
+var userStatuses = []string{
+	models.UserStatusPending,
+	models.UserStatusActive,
+	models.UserStatusDeactivated,
+	models.UserStatusGraduated,
+}
+
+func (d *data) toUser(u *user) *models.User {
+	c := u.User
+	c.DeactivatedAt = clonePtr(u.DeactivatedAt)
+	c.ExpectedGraduation = clonePtr(u.ExpectedGraduation)
+
+	role := d.roleByID(u.roleID)
+	c.Role = role.Name
+	c.Level = role.Level
+
+	totp := d.userTOTPByUserID(u.ID)
+	c.TwoFactorEnabled = totp != nil && totp.EnabledAt != nil
+
+	return &c
+}
+
+func (d *data) selectUser(match func(u *user) bool) (*models.User, error) {
+	u := find(d.users, match)
+	if u == nil {
+		return nil, sql.ErrNoRows
+	}
+	return d.toUser(u), nil
+}
+
+func (s *Store) InsertUser(ctx context.Context, u *models.User) error {
+	return s.do(ctx, func(d *data) error {
+		if u.Status == "" {
+			u.Status = models.UserStatusActive
+		}
+
+		role := d.roleByName(u.Role)
+		switch {
+		case role == nil:
+			return notNullViolation("users", "role_id")
+		case !slices.Contains(userStatuses, u.Status):
+			return checkViolation("users", "users_status_check")
+		case find(d.users, func(other *user) bool { return other.Username == u.Username }) != nil:
+			return uniqueViolation("users_username_key")
+		case find(d.users, func(other *user) bool { return other.Email == u.Email }) != nil:
+			return uniqueViolation("users_email_key")
+		}
+
+		fullNamePinyin, fullNameInitials := models.FullNameToPinyin(u.FullName)
+		u.FullNamePinyin = fullNamePinyin
+		u.ID = uuid.New()
+		u.Level = role.Level
+		u.CreatedAt = now()
+		u.Version = 1
+
+		d.users = append(d.users, &user{
+			User: models.User{
+				ID:             u.ID,
+				Username:       u.Username,
+				PasswordHash:   u.PasswordHash,
+				Email:          u.Email,
+				FullName:       u.FullName,
+				FullNamePinyin: fullNamePinyin,
+				Status:         u.Status,
+				Locale:         u.Locale,
+				CreatedAt:      u.CreatedAt,
+				Version:        u.Version,
+			},
+			roleID:           role.ID,
+			fullNameInitials: fullNameInitials,
+		})
+		return nil
+	})
+}
+
+func (s *Store) SelectUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	var found *models.User
+	err := s.do(ctx, func(d *data) (err error) {
+		found, err = d.selectUser(func(u *user) bool { return u.Username == username })
+		return err
+	})
+	return found, err
+}
+
+func (s *Store) SelectUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	var found *models.User
+	err := s.do(ctx, func(d *data) (err error) {
+		found, err = d.selectUser(func(u *user) bool { return u.ID == userID })
+		return err
+	})
+	return found, err
+}
+
+func (s *Store) SelectUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var found *models.User
+	err := s.do(ctx, func(d *data) (err error) {
+		found, err = d.selectUser(func(u *user) bool { return u.Email == email })
+		return err
+	})
+	return found, err
+}
+
+func (s *Store) SelectUserByStudentID(ctx context.Context, studentID string) (*models.User, error) {
+	var found *models.User
+	err := s.do(ctx, func(d *data) (err error) {
+		// an empty student ID is stored as NULL, which matches nothing
+		found, err = d.selectUser(func(u *user) bool { return u.StudentID != "" && u.StudentID == studentID })
+		return err
+	})
+	return found, err
+}
+
+func (s *Store) UpdateUser(ctx context.Context, u *models.User) error {
+	return s.do(ctx, func(d *data) error {
+		row := find(d.users, func(row *user) bool { return row.ID == u.ID && row.Version == u.Version })
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		role := d.roleByName(u.Role)
+		switch {
+		case role == nil:
+			return notNullViolation("users", "role_id")
+		case !slices.Contains(userStatuses, u.Status):
+			return checkViolation("users", "users_status_check")
+		case find(d.users, func(other *user) bool { return other.ID != u.ID && other.Email == u.Email }) != nil:
+			return uniqueViolation("users_email_key")
+		case u.StudentID != "" && find(d.users, func(other *user) bool { return other.ID != u.ID && other.StudentID == u.StudentID }) != nil:
+			return uniqueViolation("users_student_id_key")
+		}
+
+		fullNamePinyin, fullNameInitials := models.FullNameToPinyin(u.FullName)
+		u.FullNamePinyin = fullNamePinyin
+
+		row.PasswordHash = u.PasswordHash
+		row.Email = u.Email
+		row.roleID = role.ID
+		row.Status = u.Status
+		row.DeactivatedAt = truncatePtr(u.DeactivatedAt)
+		row.FullName = u.FullName
+		row.StudentID = u.StudentID
+		row.Phone = u.Phone
+		row.College = u.College
+		row.Grade = u.Grade
+		row.ExpectedGraduation = datePtr(u.ExpectedGraduation)
+		row.FullNamePinyin = fullNamePinyin
+		row.fullNameInitials = fullNameInitials
+		row.Locale = u.Locale
+		row.Version++
+		u.Version = row.Version
+		return nil
+	})
+}
+
+// UpdateUserPasswordHash swaps the password hash of a user only if it is
+// still oldHash, without bumping the version.
+func (s *Store) UpdateUserPasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	return s.do(ctx, func(d *data) error {
+		row := find(d.users, func(row *user) bool { return row.ID == id && row.PasswordHash == oldHash })
+		if row == nil {
+			return sql.ErrNoRows
+		}
+
+		row.PasswordHash = newHash
+		return nil
+	})
+}
+
+func (s *Store) SelectUsersGraduatingBefore(ctx context.Context, deadline time.Time) ([]*models.User, error) {
+	today := date(time.Now())
+
+	users := make([]*models.User, 0)
+	err := s.do(ctx, func(d *data) error {
+		for _, u := range d.users {
+			if u.Status == models.UserStatusActive &&
+				u.ExpectedGraduation != nil &&
+				!u.ExpectedGraduation.Before(today) &&
+				!u.ExpectedGraduation.After(deadline) {
+				users = append(users, d.toUser(u))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(users, func(a, b *models.User) int {
+		return cmp.Or(a.ExpectedGraduation.Compare(*b.ExpectedGraduation), cmp.Compare(a.FullName, b.FullName))
+	})
+	return users, err
+}
+
+func (s *Store) SelectUsersWithPermission(ctx context.Context, permission string) ([]*models.User, error) {
+	users := make([]*models.User, 0)
+	err := s.do(ctx, func(d *data) error {
+		for _, u := range d.users {
+			if u.Status == models.UserStatusActive && d.roleHasPermission(u.roleID, permission) {
+				users = append(users, d.toUser(u))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(users, func(a, b *models.User) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return users, err
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	return ptr(*p)
+}
+
+func truncatePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return ptr(t.Truncate(time.Microsecond))
+}
+
+// date is what a DATE column keeps of a time: its day, read back as
+// midnight UTC.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return ptr(date(*t))
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// userSortKey holds the value a listing of users is sorted by, in the field
+// of its type.
+type userSortKey struct {
+	time   time.Time
+	text   string
+	number int64
+}
+
+func (k userSortKey) compare(other userSortKey) int {
+	return cmp.Or(k.time.Compare(other.time), cmp.Compare(k.text, other.text), cmp.Compare(k.number, other.number))
+}
+
+func newUserSortKey(u *models.User, sort string) userSortKey {
+	switch sort {
+	case "createdAt":
+		return userSortKey{time: u.CreatedAt}
+	case "username":
+		return userSortKey{text: u.Username}
+	case "fullName":
+		return userSortKey{text: u.FullNamePinyin}
+	default:
+		return userSortKey{number: int64(u.Level)}
+	}
+}
+
+// parseUserSortKey reads the value of a cursor, like the casts of the
+// Postgres query do.
+func parseUserSortKey(value, sort string) (userSortKey, error) {
+	switch sort {
+	case "createdAt":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		return userSortKey{time: t}, err
+	case "username", "fullName":
+		return userSortKey{text: value}, nil
+	default:
+		n, err := strconv.ParseInt(value, 10, 32)
+		return userSortKey{number: n}, err
+	}
+}
+
+func (s *Store) SelectUsers(ctx context.Context, filter *models.UserFilter) ([]*models.User, error) {
+	if !models.IsValidUserSort(filter.Sort) {
+		return nil, fmt.Errorf("invalid sort %q", filter.Sort)
+	}
+
+	var cursor userSortKey
+	if filter.Cursor != nil {
+		var err error
+		if cursor, err = parseUserSortKey(filter.Cursor.Value, filter.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	search := strings.ToLower(filter.Search)
+	compact := strings.ToLower(strings.Join(strings.Fields(filter.Search), ""))
+	matches := func(u *user) bool {
+		return strings.Contains(strings.ToLower(u.FullName), search) ||
+			strings.Contains(strings.ToLower(u.Username), search) ||
+			strings.Contains(strings.ToLower(u.Email), search) ||
+			strings.Contains(u.StudentID, search) ||
+			strings.Contains(u.FullNamePinyin, compact) ||
+			strings.Contains(u.fullNameInitials, compact)
+	}
+
+	// compare orders users by the sort key with the ID as tie breaker,
+	// reversed for descending listings
+	compare := func(a *models.User, key userSortKey, id uuid.UUID) int {
+		c := cmp.Or(newUserSortKey(a, filter.Sort).compare(key), compareUUID(a.ID, id))
+		if filter.Desc {
+			return -c
+		}
+		return c
+	}
+
+	users := make([]*models.User, 0)
+	err := s.do(ctx, func(d *data) error {
+		for _, row := range d.users {
+			if filter.Search != "" && !matches(row) {
+				continue
+			}
+			if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, row.Status) {
+				continue
+			}
+
+			u := d.toUser(row)
+			if filter.Role != "" && u.Role != filter.Role {
+				continue
+			}
+			if filter.Cursor != nil && compare(u, cursor, filter.Cursor.ID) <= 0 {
+				continue
+			}
+			users = append(users, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(users, func(a, b *models.User) int {
+		return compare(a, newUserSortKey(b, filter.Sort), b.ID)
+	})
+	if filter.Limit >= 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+
+	return users, nil
+}
+
+// BackfillUserPinyin has nothing to do, as every user here got the pinyin
+// search columns when it was inserted.
+func (s *Store) BackfillUserPinyin(ctx context.Context) (int, error) {
+	return 0, s.do(ctx, func(d *data) error { return nil })
+}
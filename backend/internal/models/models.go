@@ -1,13 +1,124 @@
 package models
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
 )
 
+// dbtx is satisfied by both *pgxpool.Pool and pgx.Tx, so the same model
+// methods can run standalone or as part of a larger transaction.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// timeouts bound the work of model methods, on top of any deadline of the
+// context passed in by the caller.
+type timeouts struct {
+	// query bounds methods running a single statement.
+	query time.Duration
+	// batch bounds methods running many statements or reading many rows.
+	batch time.Duration
+	// tx bounds a whole transaction of WithTx.
+	tx time.Duration
+}
+
 type Models struct {
-	db *sql.DB
+	db dbtx
+	// pool stays at hand inside transactions for what needs a connection of
+	// its own, such as listening for notifications.
+	pool     *pgxpool.Pool
+	timeouts timeouts
+}
+
+func New(pool *pgxpool.Pool, cfg *config.Config) *Models {
+	return &Models{
+		db:   pool,
+		pool: pool,
+		timeouts: timeouts{
+			query: cfg.Postgres.QueryTimeout,
+			batch: cfg.Postgres.BatchTimeout,
+			tx:    cfg.Postgres.TxTimeout,
+		},
+	}
+}
+
+func (m *Models) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, m.timeouts.query)
+}
+
+func (m *Models) withBatchTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, m.timeouts.batch)
+}
+
+// WithTx runs fn with models bound to a single transaction, committing only if
+// fn succeeds. The transaction rolls back once ctx is done, so the calls of fn
+// should use ctx as well. Calls nested inside an existing transaction join it.
+func (m *Models) WithTx(ctx context.Context, fn func(m Store) error) error {
+	return m.withTx(ctx, func(m *Models) error {
+		return fn(m)
+	})
 }
 
-func New(db *sql.DB) *Models {
-	return &Models{db: db}
+func (m *Models) withTx(ctx context.Context, fn func(m *Models) error) error {
+	db, ok := m.db.(*pgxpool.Pool)
+	if !ok {
+		return fn(m)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeouts.tx)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := fn(&Models{db: tx, pool: m.pool, timeouts: m.timeouts}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Savepoint runs fn inside a savepoint of the current transaction, rolling
+// back only fn's changes when it fails so the transaction can carry on. Each
+// statement of the savepoint gets a query timeout of its own, while fn is
+// bounded by the transaction only.
+func (m *Models) Savepoint(ctx context.Context, name string, fn func(m Store) error) error {
+	if _, ok := m.db.(pgx.Tx); !ok {
+		return errors.New("savepoint must be used within a transaction")
+	}
+
+	if err := m.execWithQueryTimeout(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	if err := fn(m); err != nil {
+		if rbErr := m.execWithQueryTimeout(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return m.execWithQueryTimeout(ctx, "RELEASE SAVEPOINT "+name)
+}
+
+func (m *Models) execWithQueryTimeout(ctx context.Context, sql string) error {
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := m.db.Exec(ctx, sql)
+	return err
 }
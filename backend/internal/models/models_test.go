@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testModels connects to the migrated database named by the POSTGRES_*
+// environment variables, and skips the test when there is none.
+func testModels(tb testing.TB) *Models {
+	tb.Helper()
+
+	if os.Getenv("POSTGRES_HOST") == "" {
+		tb.Skip("POSTGRES_HOST is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
+		"postgres://%s:%s@%s:5432/%s?sslmode=disable",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_PASSWORD"),
+		os.Getenv("POSTGRES_HOST"),
+		os.Getenv("POSTGRES_DB"),
+	))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close)
+
+	return &Models{
+		db:   pool,
+		pool: pool,
+		timeouts: timeouts{
+			query: 5 * time.Second,
+			batch: 10 * time.Second,
+			tx:    10 * time.Second,
+		},
+	}
+}
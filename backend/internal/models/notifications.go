@@ -0,0 +1,51 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notify sends payload to the listeners of channel. Inside a transaction it
+// is delivered only once the transaction commits.
+func (m *Models) Notify(ctx context.Context, channel, payload string) error {
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := m.db.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+// Listen calls fn with the payload of every notification sent to channel
+// until ctx is done, on a connection held for as long as it listens.
+func (m *Models) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// the wait may have left the connection broken, so UNLISTEN is
+		// bounded, and a connection it fails on is closed rather than
+		// returned to the pool still listening
+		ctx, cancel := m.withQueryTimeout(context.Background())
+		defer cancel()
+
+		if _, err := conn.Exec(ctx, "UNLISTEN *"); err != nil {
+			_ = conn.Hijack().Close(ctx)
+			return
+		}
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestListenNotify(t *testing.T) {
+	m := testModels(t)
+	channel := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	payloads := make(chan string, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Listen(ctx, channel, func(payload string) { payloads <- payload })
+	}()
+
+	// Listen cannot tell when it is listening, so notify until it hears
+	timeout := time.After(5 * time.Second)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for {
+		select {
+		case payload := <-payloads:
+			if payload != "ping" {
+				t.Fatalf("got %q, want %q", payload, "ping")
+			}
+			break wait
+		case <-ticker.C:
+			if err := m.Notify(context.Background(), channel, "ping"); err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			t.Fatal("no notification arrived")
+		}
+	}
+
+	// a notification of a rolled back transaction is never sent, one of a
+	// committed transaction is
+	rollback := errors.New("rollback")
+	if err := m.WithTx(context.Background(), func(m Store) error {
+		if err := m.Notify(context.Background(), channel, "rolled back"); err != nil {
+			return err
+		}
+		return rollback
+	}); !errors.Is(err, rollback) {
+		t.Fatalf("got %v, want the rollback", err)
+	}
+	if err := m.WithTx(context.Background(), func(m Store) error {
+		return m.Notify(context.Background(), channel, "committed")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case payload := <-payloads:
+			switch payload {
+			case "ping":
+				continue
+			case "committed":
+			default:
+				t.Fatalf("got %q, want %q", payload, "committed")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the notification of the committed transaction did not arrive")
+		}
+		break
+	}
+
+	// stopping gives the connection back
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen did not return after its context was done")
+	}
+	if acquired := m.pool.Stat().AcquiredConns(); acquired != 0 {
+		t.Fatalf("%d connections are still acquired", acquired)
+	}
+}
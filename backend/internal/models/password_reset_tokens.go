@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// InsertPasswordResetToken stores a new token and invalidates the unused ones
+// previously issued to the same user, so only the latest link works.
+func (m *Models) InsertPasswordResetToken(ctx context.Context, t *PasswordResetToken) error {
+	return m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		query := `
+			UPDATE password_reset_tokens
+			SET used_at = NOW()
+			WHERE user_id = $1 AND used_at IS NULL
+		`
+		if _, err := m.db.Exec(ctx, query, t.UserID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at
+		`
+		return m.db.QueryRow(ctx, query, t.UserID, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+	})
+}
+
+// ConsumePasswordResetToken marks an unused and unexpired token as used and
+// returns the ID of its user, or sql.ErrNoRows if no such token exists.
+func (m *Models) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var userID uuid.UUID
+	if err := m.db.QueryRow(ctx, query, tokenHash).Scan(&userID); err != nil {
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
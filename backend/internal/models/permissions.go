@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PermissionUsersManage      = "users.manage"
+	PermissionRolesManage      = "roles.manage"
+	PermissionTemplatesEdit    = "templates.edit"
+	PermissionPlansEdit        = "plans.edit"
+	PermissionAttendanceReview = "attendance.review"
+	PermissionAuditView        = "audit.view"
+	PermissionUsersImpersonate = "users.impersonate"
+)
+
+type Permission struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+}
+
+func (m *Models) SelectAllPermissions(ctx context.Context) ([]*Permission, error) {
+	query := `
+		SELECT id, name, description
+		FROM permissions
+		ORDER BY name
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make([]*Permission, 0)
+	for rows.Next() {
+		p := &Permission{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (m *Models) SelectPermissionsByRoleName(ctx context.Context, roleName string) ([]string, error) {
+	query := `
+		SELECT p.name
+		FROM role_permissions AS rp
+		INNER JOIN roles AS r ON rp.role_id = r.id
+		INNER JOIN permissions AS p ON rp.permission_id = p.id
+		WHERE r.name = $1
+		ORDER BY p.name
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, roleName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (m *Models) UpdateRolePermissions(ctx context.Context, roleID uuid.UUID, permissions []string) error {
+	return m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		if _, err := m.db.Exec(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT $1, id
+			FROM permissions
+			WHERE name = ANY($2)
+		`
+		if _, err := m.db.Exec(ctx, query, roleID, permissions); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (m *Models) GrantAllPermissions(ctx context.Context, roleID uuid.UUID) error {
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id
+		FROM permissions
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := m.db.Exec(ctx, query, roleID)
+	return err
+}
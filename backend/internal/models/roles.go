@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Level       int32     `json:"level"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Version     int32     `json:"version"`
+}
+
+func (m *Models) InsertRole(ctx context.Context, role *Role) error {
+	query := `
+		INSERT INTO roles (name, level, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := m.db.QueryRow(ctx, query, role.Name, role.Level, role.Description).Scan(&role.ID, &role.CreatedAt, &role.Version); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Models) SelectRoleByID(ctx context.Context, id uuid.UUID) (*Role, error) {
+	role := &Role{ID: id}
+
+	query := `
+		SELECT name, level, description, created_at, version
+		FROM roles
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := m.db.QueryRow(ctx, query, id).Scan(&role.Name, &role.Level, &role.Description, &role.CreatedAt, &role.Version); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (m *Models) SelectRoleByName(ctx context.Context, name string) (*Role, error) {
+	role := &Role{Name: name}
+
+	query := `
+		SELECT id, level, description, created_at, version
+		FROM roles
+		WHERE name = $1
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := m.db.QueryRow(ctx, query, name).Scan(&role.ID, &role.Level, &role.Description, &role.CreatedAt, &role.Version); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (m *Models) SelectAllRoles(ctx context.Context) ([]*Role, error) {
+	query := `
+		SELECT id, name, level, description, created_at, version
+		FROM roles
+		ORDER BY level, name
+	`
+
+	ctx, cancel := m.withBatchTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]*Role, 0)
+	for rows.Next() {
+		role := &Role{}
+		if err := rows.Scan(&role.ID, &role.Name, &role.Level, &role.Description, &role.CreatedAt, &role.Version); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (m *Models) UpdateRole(ctx context.Context, role *Role) error {
+	query := `
+		UPDATE roles
+		SET
+			name = $1,
+			level = $2,
+			description = $3,
+			version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+	`
+	args := []any{role.Name, role.Level, role.Description, role.ID, role.Version}
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := m.db.QueryRow(ctx, query, args...).Scan(&role.Version); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Models) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM roles WHERE id = $1`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
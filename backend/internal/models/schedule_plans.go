@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type SchedulePlan struct {
@@ -16,11 +17,13 @@ type SchedulePlan struct {
 	ActiveStartTime      time.Time `json:"activeStartTime"`
 	ActiveEndTime        time.Time `json:"activeEndTime"`
 	ScheduleTemplateName string    `json:"scheduleTemplateName"`
-	CreatedAt            time.Time `json:"created_at"`
-	Version              int32     `json:"version"`
+	// PublishedAt is nil while the plan is a draft, which is not exported
+	PublishedAt *time.Time `json:"publishedAt"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Version     int32      `json:"version"`
 }
 
-func (m *Models) InsertSchedulePlan(sp *SchedulePlan) error {
+func (m *Models) InsertSchedulePlan(ctx context.Context, sp *SchedulePlan) error {
 	query := `
 		INSERT INTO schedule_plans (
 			name,
@@ -34,18 +37,18 @@ func (m *Models) InsertSchedulePlan(sp *SchedulePlan) error {
 		RETURNING id, created_at, version
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withQueryTimeout(ctx)
 	defer cancel()
 
 	args := []any{sp.Name, sp.Description, sp.SubmissionStartTime, sp.SubmissionEndTime, sp.ActiveStartTime, sp.ActiveEndTime, sp.ScheduleTemplateName}
-	if err := m.db.QueryRowContext(ctx, query, args...).Scan(&sp.ID, &sp.CreatedAt, &sp.Version); err != nil {
+	if err := m.db.QueryRow(ctx, query, args...).Scan(&sp.ID, &sp.CreatedAt, &sp.Version); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (m *Models) SelectSchedulePlanByID(id uuid.UUID) (*SchedulePlan, error) {
+func (m *Models) SelectSchedulePlanByID(ctx context.Context, id uuid.UUID) (*SchedulePlan, error) {
 	query := `
 		SELECT 
 			name, 
@@ -55,13 +58,14 @@ func (m *Models) SelectSchedulePlanByID(id uuid.UUID) (*SchedulePlan, error) {
 			active_start_time,
 			active_end_time,
 			schedule_template_name,
+			published_at,
 			created_at,
 			version
 		FROM schedule_plans
 		WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withQueryTimeout(ctx)
 	defer cancel()
 
 	sp := &SchedulePlan{
@@ -75,13 +79,116 @@ func (m *Models) SelectSchedulePlanByID(id uuid.UUID) (*SchedulePlan, error) {
 		&sp.ActiveStartTime,
 		&sp.ActiveEndTime,
 		&sp.ScheduleTemplateName,
+		&sp.PublishedAt,
 		&sp.CreatedAt,
 		&sp.Version,
 	}
 
-	if err := m.db.QueryRowContext(ctx, query, id).Scan(dest...); err != nil {
+	if err := m.db.QueryRow(ctx, query, id).Scan(dest...); err != nil {
 		return nil, err
 	}
 
 	return sp, nil
 }
+
+// PublishSchedulePlan marks the draft plan as published.
+func (m *Models) PublishSchedulePlan(ctx context.Context, sp *SchedulePlan) error {
+	query := `
+		UPDATE schedule_plans
+		SET
+			published_at = NOW(),
+			version = version + 1
+		WHERE id = $1 AND version = $2 AND published_at IS NULL
+		RETURNING published_at, version
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, sp.ID, sp.Version).Scan(&sp.PublishedAt, &sp.Version)
+}
+
+type SchedulePlanAssignment struct {
+	ScheduleTemplateShiftID uuid.UUID `json:"scheduleTemplateShiftId"`
+	DayOfWeek               int32     `json:"dayOfWeek"`
+	UserID                  uuid.UUID `json:"userId"`
+	FullName                string    `json:"fullName"`
+}
+
+func (m *Models) SelectSchedulePlanAssignments(ctx context.Context, schedulePlanID uuid.UUID) ([]*SchedulePlanAssignment, error) {
+	query := `
+		SELECT
+			a.schedule_template_shift_id,
+			a.day_of_week,
+			a.user_id,
+			u.full_name
+		FROM schedule_plan_assignments AS a
+		INNER JOIN users AS u ON a.user_id = u.id
+		WHERE a.schedule_plan_id = $1
+		ORDER BY u.full_name
+	`
+
+	ctx, cancel := m.withBatchTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, schedulePlanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := make([]*SchedulePlanAssignment, 0)
+	for rows.Next() {
+		a := &SchedulePlanAssignment{}
+		if err := rows.Scan(&a.ScheduleTemplateShiftID, &a.DayOfWeek, &a.UserID, &a.FullName); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+// ReplaceSchedulePlanAssignments replaces every assignment of the plan with
+// assignments, whose FullName is ignored.
+func (m *Models) ReplaceSchedulePlanAssignments(ctx context.Context, schedulePlanID uuid.UUID, assignments []*SchedulePlanAssignment) error {
+	var (
+		shiftIDs = make([]uuid.UUID, 0, len(assignments))
+		days     = make([]int32, 0, len(assignments))
+		userIDs  = make([]uuid.UUID, 0, len(assignments))
+	)
+	for _, a := range assignments {
+		shiftIDs = append(shiftIDs, a.ScheduleTemplateShiftID)
+		days = append(days, a.DayOfWeek)
+		userIDs = append(userIDs, a.UserID)
+	}
+
+	return m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withBatchTimeout(ctx)
+		defer cancel()
+
+		b := &pgx.Batch{}
+		b.Queue(`DELETE FROM schedule_plan_assignments WHERE schedule_plan_id = $1`, schedulePlanID)
+		b.Queue(`
+			INSERT INTO schedule_plan_assignments (schedule_plan_id, schedule_template_shift_id, day_of_week, user_id)
+			SELECT $1, shift_id, day_of_week, user_id
+			FROM unnest($2::uuid[], $3::integer[], $4::uuid[]) AS a (shift_id, day_of_week, user_id)
+		`, schedulePlanID, shiftIDs, days, userIDs)
+
+		br := m.db.SendBatch(ctx, b)
+		defer br.Close()
+
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+
+		return br.Close()
+	})
+}
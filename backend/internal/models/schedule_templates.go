@@ -6,12 +6,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type ScheduleTemplateShift struct {
 	ID                 uuid.UUID `json:"id"`
-	StartTime          string    `json:"startTime"`
-	EndTime            string    `json:"endTime"`
+	StartTime          TimeOfDay `json:"startTime"`
+	EndTime            TimeOfDay `json:"endTime"`
 	RequiredAssistants int32     `json:"requiredAssistants"`
 	ApplicableDays     []int32   `json:"applicableDays"`
 }
@@ -25,87 +26,131 @@ type ScheduleTemplate struct {
 	Version     int32                    `json:"version"`
 }
 
-func (m *Models) InsertScheduleTemplate(st *ScheduleTemplate) error {
-	// begin transaction
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (m *Models) InsertScheduleTemplate(ctx context.Context, st *ScheduleTemplate) error {
+	return m.withTx(ctx, func(m *Models) error {
+		return m.insertScheduleTemplate(ctx, st)
+	})
+}
+
+func (m *Models) insertScheduleTemplate(ctx context.Context, st *ScheduleTemplate) error {
+	ctx, cancel := m.withBatchTimeout(ctx)
 	defer cancel()
 
-	tx, err := m.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+	// the template, its shifts and their applicable days go in one batch,
+	// the shifts and days as arrays however many there are. The IDs are
+	// made here so that the rows can refer to each other.
+	st.ID = uuid.New()
+	var (
+		shiftIDs           = make([]uuid.UUID, 0, len(st.Shifts))
+		startTimes         = make([]TimeOfDay, 0, len(st.Shifts))
+		endTimes           = make([]TimeOfDay, 0, len(st.Shifts))
+		requiredAssistants = make([]int32, 0, len(st.Shifts))
+		dayShiftIDs        []uuid.UUID
+		days               []int32
+	)
+	for _, shift := range st.Shifts {
+		shift.ID = uuid.New()
+		shiftIDs = append(shiftIDs, shift.ID)
+		startTimes = append(startTimes, shift.StartTime)
+		endTimes = append(endTimes, shift.EndTime)
+		requiredAssistants = append(requiredAssistants, shift.RequiredAssistants)
+		for _, day := range shift.ApplicableDays {
+			dayShiftIDs = append(dayShiftIDs, shift.ID)
+			days = append(days, day)
+		}
 	}
-	defer func() {
-		_ = tx.Rollback()
-	}()
+
+	b := &pgx.Batch{}
 
 	// insert the meta
-	query := `
-		INSERT INTO schedule_templates (name, description)
-		VALUES ($1, $2)
-		RETURNING id, created_at, version
-	`
-	if err := tx.QueryRowContext(ctx, query, st.Name, st.Description).Scan(&st.ID, &st.CreatedAt, &st.Version); err != nil {
-		return err
-	}
+	b.Queue(`
+		INSERT INTO schedule_templates (id, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING created_at, version
+	`, st.ID, st.Name, st.Description)
 
 	// insert the shifts
-	for _, shift := range st.Shifts {
-		query := `
-			INSERT INTO 
-				schedule_template_shifts (
-					schedule_template_id, 
-					start_time,
-					end_time,
-					required_assistants
-				)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id
-		`
-		if err := tx.QueryRowContext(ctx, query, st.ID, shift.StartTime, shift.EndTime, shift.RequiredAssistants).Scan(&shift.ID); err != nil {
-			return err
-		}
-
-		// insert the applicable days
-		for _, day := range shift.ApplicableDays {
-			query := `
-				INSERT INTO schedule_template_shifts_availability (schedule_template_shift_id, day_of_week)
-				VALUES ($1, $2)
-			`
-			if _, err := tx.ExecContext(ctx, query, shift.ID, day); err != nil {
-				return err
-			}
-		}
+	b.Queue(`
+		INSERT INTO 
+			schedule_template_shifts (
+				id,
+				schedule_template_id, 
+				start_time,
+				end_time,
+				required_assistants
+			)
+		SELECT id, $1, start_time, end_time, required_assistants
+		FROM unnest($2::uuid[], $3::time[], $4::time[], $5::integer[])
+			AS s (id, start_time, end_time, required_assistants)
+	`, st.ID, shiftIDs, startTimes, endTimes, requiredAssistants)
+
+	// insert the applicable days
+	b.Queue(`
+		INSERT INTO schedule_template_shifts_availability (schedule_template_shift_id, day_of_week)
+		SELECT shift_id, day_of_week
+		FROM unnest($1::uuid[], $2::integer[]) AS a (shift_id, day_of_week)
+	`, dayShiftIDs, days)
+
+	br := m.db.SendBatch(ctx, b)
+	defer br.Close()
+
+	if err := br.QueryRow().Scan(&st.CreatedAt, &st.Version); err != nil {
+		return err
+	}
+	if _, err := br.Exec(); err != nil {
+		return err
+	}
+	if _, err := br.Exec(); err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	return br.Close()
 }
 
-func (m *Models) SelectScheduleTemplate(id uuid.UUID) (*ScheduleTemplate, error) {
+func (m *Models) SelectScheduleTemplate(ctx context.Context, id uuid.UUID) (*ScheduleTemplate, error) {
 	st := &ScheduleTemplate{
 		ID:     id,
 		Shifts: make([]*ScheduleTemplateShift, 0),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := m.withBatchTimeout(ctx)
 	defer cancel()
 
+	b := &pgx.Batch{}
+
 	// query the meta
-	query := `
+	b.Queue(`
 		SELECT name, description, created_at, version
 		FROM schedule_templates
 		WHERE id = $1
-	`
-	if err := m.db.QueryRowContext(ctx, query, id).Scan(&st.Name, &st.Description, &st.CreatedAt, &st.Version); err != nil {
+	`, id)
+
+	// query the shifts together with their applicable days
+	b.Queue(`
+		SELECT
+			s.id,
+			s.start_time,
+			s.end_time,
+			s.required_assistants,
+			COALESCE(
+				array_agg(a.day_of_week ORDER BY a.day_of_week) FILTER (WHERE a.day_of_week IS NOT NULL),
+				'{}'
+			)
+		FROM schedule_template_shifts s
+		LEFT JOIN schedule_template_shifts_availability a ON a.schedule_template_shift_id = s.id
+		WHERE s.schedule_template_id = $1
+		GROUP BY s.id
+		ORDER BY s.start_time, s.end_time, s.id
+	`, id)
+
+	br := m.db.SendBatch(ctx, b)
+	defer br.Close()
+
+	if err := br.QueryRow().Scan(&st.Name, &st.Description, &st.CreatedAt, &st.Version); err != nil {
 		return nil, err
 	}
 
-	// query the shifts
-	query = `
-		SELECT id, start_time, end_time, required_assistants
-		FROM schedule_template_shifts
-		WHERE schedule_template_id = $1
-	`
-	rows, err := m.db.QueryContext(ctx, query, st.ID)
+	rows, err := br.Query()
 	if err != nil {
 		return nil, err
 	}
@@ -115,31 +160,7 @@ func (m *Models) SelectScheduleTemplate(id uuid.UUID) (*ScheduleTemplate, error)
 		sts := &ScheduleTemplateShift{
 			ApplicableDays: make([]int32, 0),
 		}
-		if err := rows.Scan(&sts.ID, &sts.StartTime, &sts.EndTime, &sts.RequiredAssistants); err != nil {
-			return nil, err
-		}
-
-		// query the applicable days
-		query := `
-			SELECT day_of_week
-			FROM schedule_template_shifts_availability
-			WHERE schedule_template_shift_id = $1
-		`
-
-		rows, err := m.db.QueryContext(ctx, query, sts.ID)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var day_of_week int32
-			if err := rows.Scan(&day_of_week); err != nil {
-				return nil, err
-			}
-			sts.ApplicableDays = append(sts.ApplicableDays, day_of_week)
-		}
-		if err := rows.Err(); err != nil {
+		if err := rows.Scan(&sts.ID, &sts.StartTime, &sts.EndTime, &sts.RequiredAssistants, &sts.ApplicableDays); err != nil {
 			return nil, err
 		}
 
@@ -153,17 +174,35 @@ func (m *Models) SelectScheduleTemplate(id uuid.UUID) (*ScheduleTemplate, error)
 	return st, nil
 }
 
-func (m *Models) SelectAllScheduleTemplateMeta() ([]*ScheduleTemplate, error) {
+func (m *Models) SelectScheduleTemplateByName(ctx context.Context, name string) (*ScheduleTemplate, error) {
+	query := `
+		SELECT id
+		FROM schedule_templates
+		WHERE name = $1
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var id uuid.UUID
+	if err := m.db.QueryRow(ctx, query, name).Scan(&id); err != nil {
+		return nil, err
+	}
+
+	return m.SelectScheduleTemplate(ctx, id)
+}
+
+func (m *Models) SelectAllScheduleTemplateMeta(ctx context.Context) ([]*ScheduleTemplate, error) {
 	sts := make([]*ScheduleTemplate, 0)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := m.withBatchTimeout(ctx)
 	defer cancel()
 
 	query := `
 		SELECT id, name, description, created_at, version
 		FROM schedule_templates
 	`
-	rows, err := m.db.QueryContext(ctx, query)
+	rows, err := m.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -187,30 +226,26 @@ func (m *Models) SelectAllScheduleTemplateMeta() ([]*ScheduleTemplate, error) {
 	return sts, nil
 }
 
-func (m *Models) DeleteScheduleTemplate(id int64) error {
+func (m *Models) DeleteScheduleTemplate(ctx context.Context, id uuid.UUID) error {
 	query := `
 		DELETE FROM schedule_templates WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withQueryTimeout(ctx)
 	defer cancel()
 
-	res, err := m.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
-	rows, err := res.RowsAffected()
+	res, err := m.db.Exec(ctx, query, id)
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
+	if res.RowsAffected() == 0 {
 		return sql.ErrNoRows
 	}
 
 	return nil
 }
 
-func (m *Models) UpdateScheduleTemplateDescription(id uuid.UUID, description string) (*ScheduleTemplate, error) {
+func (m *Models) UpdateScheduleTemplateDescription(ctx context.Context, id uuid.UUID, description string) (*ScheduleTemplate, error) {
 	query := `
 		UPDATE schedule_templates
 		SET description = $1
@@ -218,14 +253,14 @@ func (m *Models) UpdateScheduleTemplateDescription(id uuid.UUID, description str
 		RETURNING name, created_at, version
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withQueryTimeout(ctx)
 	defer cancel()
 
 	st := &ScheduleTemplate{
 		ID:          id,
 		Description: description,
 	}
-	if err := m.db.QueryRowContext(ctx, query, description, id).Scan(&st.Name, &st.CreatedAt, &st.Version); err != nil {
+	if err := m.db.QueryRow(ctx, query, description, id).Scan(&st.Name, &st.CreatedAt, &st.Version); err != nil {
 		return nil, err
 	}
 
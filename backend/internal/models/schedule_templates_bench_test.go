@@ -0,0 +1,178 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// benchShifts is the size of the templates benchmarked, about a week of
+// shifts at a busy site.
+const benchShifts = 50
+
+// benchModels connects to the database like testModels, and skips the
+// benchmark when there is none.
+func benchModels(b *testing.B) *Models {
+	b.Helper()
+
+	m := testModels(b)
+	b.Cleanup(func() {
+		// the shifts and their days go with the templates
+		_, _ = m.pool.Exec(context.Background(), `DELETE FROM schedule_templates WHERE name LIKE 'bench-%'`)
+	})
+	return m
+}
+
+func benchScheduleTemplate() *ScheduleTemplate {
+	st := &ScheduleTemplate{
+		Name:        "bench-" + uuid.NewString(),
+		Description: "benchmark",
+	}
+	for i := range benchShifts {
+		start := TimeOfDay(time.Duration(i) * 20 * time.Minute)
+		st.Shifts = append(st.Shifts, &ScheduleTemplateShift{
+			StartTime:          start,
+			EndTime:            start + TimeOfDay(20*time.Minute),
+			RequiredAssistants: 2,
+			ApplicableDays:     []int32{1, 2, 3, 4, 5},
+		})
+	}
+	return st
+}
+
+func BenchmarkInsertScheduleTemplate(b *testing.B) {
+	m := benchModels(b)
+	ctx := context.Background()
+
+	b.Run("batch", func(b *testing.B) {
+		for range b.N {
+			if err := m.InsertScheduleTemplate(ctx, benchScheduleTemplate()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per-row", func(b *testing.B) {
+		for range b.N {
+			if err := insertScheduleTemplatePerRow(ctx, m, benchScheduleTemplate()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSelectScheduleTemplate(b *testing.B) {
+	m := benchModels(b)
+	ctx := context.Background()
+
+	st := benchScheduleTemplate()
+	if err := m.InsertScheduleTemplate(ctx, st); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		for range b.N {
+			if _, err := m.SelectScheduleTemplate(ctx, st.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per-row", func(b *testing.B) {
+		for range b.N {
+			if _, err := selectScheduleTemplatePerRow(ctx, m, st.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// insertScheduleTemplatePerRow is the insert as it was before the batch:
+// a statement for the template, then one per shift and one per applicable
+// day, each waiting for the last.
+func insertScheduleTemplatePerRow(ctx context.Context, m *Models, st *ScheduleTemplate) error {
+	return m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withBatchTimeout(ctx)
+		defer cancel()
+
+		if err := m.db.QueryRow(ctx, `
+			INSERT INTO schedule_templates (name, description)
+			VALUES ($1, $2)
+			RETURNING id, created_at, version
+		`, st.Name, st.Description).Scan(&st.ID, &st.CreatedAt, &st.Version); err != nil {
+			return err
+		}
+
+		for _, shift := range st.Shifts {
+			if err := m.db.QueryRow(ctx, `
+				INSERT INTO schedule_template_shifts (schedule_template_id, start_time, end_time, required_assistants)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id
+			`, st.ID, shift.StartTime, shift.EndTime, shift.RequiredAssistants).Scan(&shift.ID); err != nil {
+				return err
+			}
+
+			for _, day := range shift.ApplicableDays {
+				if _, err := m.db.Exec(ctx, `
+					INSERT INTO schedule_template_shifts_availability (schedule_template_shift_id, day_of_week)
+					VALUES ($1, $2)
+				`, shift.ID, day); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// selectScheduleTemplatePerRow is the select as it was before the batch: the
+// template, its shifts, then the applicable days of each shift on its own.
+func selectScheduleTemplatePerRow(ctx context.Context, m *Models, id uuid.UUID) (*ScheduleTemplate, error) {
+	ctx, cancel := m.withBatchTimeout(ctx)
+	defer cancel()
+
+	st := &ScheduleTemplate{ID: id}
+	if err := m.db.QueryRow(ctx, `
+		SELECT name, description, created_at, version
+		FROM schedule_templates
+		WHERE id = $1
+	`, id).Scan(&st.Name, &st.Description, &st.CreatedAt, &st.Version); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(ctx, `
+		SELECT id, start_time, end_time, required_assistants
+		FROM schedule_template_shifts
+		WHERE schedule_template_id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	st.Shifts, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*ScheduleTemplateShift, error) {
+		sts := &ScheduleTemplateShift{}
+		err := row.Scan(&sts.ID, &sts.StartTime, &sts.EndTime, &sts.RequiredAssistants)
+		return sts, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sts := range st.Shifts {
+		rows, err := m.db.Query(ctx, `
+			SELECT day_of_week
+			FROM schedule_template_shifts_availability
+			WHERE schedule_template_shift_id = $1
+		`, sts.ID)
+		if err != nil {
+			return nil, err
+		}
+		sts.ApplicableDays, err = pgx.CollectRows(rows, pgx.RowTo[int32])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return st, nil
+}
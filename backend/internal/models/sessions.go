@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of a user. An impersonation session belongs to the
+// impersonated user and records the admin behind it, together with the
+// session of the admin that it returns to.
+type Session struct {
+	ID                    uuid.UUID  `json:"id"`
+	UserID                uuid.UUID  `json:"-"`
+	ImpersonatorID        *uuid.UUID `json:"-"`
+	ImpersonatorSessionID *uuid.UUID `json:"-"`
+	IPAddress             string     `json:"ipAddress"`
+	UserAgent             string     `json:"userAgent"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	LastSeenAt            time.Time  `json:"lastSeenAt"`
+	ExpiresAt             time.Time  `json:"expiresAt"`
+	Current               bool       `json:"current"`
+}
+
+func (m *Models) InsertSession(ctx context.Context, s *Session) error {
+	query := `
+		INSERT INTO sessions (user_id, impersonator_id, impersonator_session_id, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, last_seen_at
+	`
+	args := []any{
+		s.UserID,
+		s.ImpersonatorID,
+		s.ImpersonatorSessionID,
+		s.IPAddress,
+		s.UserAgent,
+		s.ExpiresAt,
+	}
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, args...).Scan(&s.ID, &s.CreatedAt, &s.LastSeenAt)
+}
+
+// TouchSession refreshes the last seen time of an unrevoked and unexpired
+// session and returns it, or sql.ErrNoRows if no such session exists.
+func (m *Models) TouchSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, impersonator_id, impersonator_session_id, ip_address, user_agent, created_at, last_seen_at, expires_at
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var s Session
+	if err := m.db.QueryRow(ctx, query, id).Scan(
+		&s.ID,
+		&s.UserID,
+		&s.ImpersonatorID,
+		&s.ImpersonatorSessionID,
+		&s.IPAddress,
+		&s.UserAgent,
+		&s.CreatedAt,
+		&s.LastSeenAt,
+		&s.ExpiresAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// SelectActiveSessionsByUserID returns the unrevoked and unexpired sessions
+// of a user, most recently used first, leaving out impersonation sessions
+// which the user did not start.
+func (m *Models) SelectActiveSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, ip_address, user_agent, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND impersonator_id IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.IPAddress,
+			&s.UserAgent,
+			&s.CreatedAt,
+			&s.LastSeenAt,
+			&s.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes one active session of a user, returning
+// sql.ErrNoRows if the user has no such session.
+func (m *Models) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, id, userID).Scan(&id)
+}
+
+// RevokeUserSessions revokes every active session of a user except the one
+// given by keep, which may be uuid.Nil, and returns how many were revoked.
+func (m *Models) RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID) (int64, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
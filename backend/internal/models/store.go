@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store is the storage behind the handlers. Models keeps it in Postgres, and
+// package memory in memory for development and tests.
+//
+// Implementations report errors the way Postgres does: sql.ErrNoRows for
+// missing rows and version conflicts, and *pgconn.PgError naming the
+// constraint for violations, so callers need not know which one they use.
+type Store interface {
+	UserStore
+	RoleStore
+	PermissionStore
+	SessionStore
+	APITokenStore
+	AuditEventStore
+	JWTSigningKeyStore
+	LoginAttemptStore
+	PasswordResetTokenStore
+	UserInvitationStore
+	UserTOTPStore
+	ScheduleTemplateStore
+	SchedulePlanStore
+	NotificationStore
+	JobRunStore
+
+	// WithTx runs fn with a store bound to a single transaction, committing
+	// only if fn succeeds. Calls nested inside an existing transaction join
+	// it.
+	WithTx(ctx context.Context, fn func(m Store) error) error
+	// Savepoint runs fn inside a savepoint of the current transaction,
+	// rolling back only fn's changes when it fails.
+	Savepoint(ctx context.Context, name string, fn func(m Store) error) error
+}
+
+type UserStore interface {
+	InsertUser(ctx context.Context, user *User) error
+	SelectUserByUsername(ctx context.Context, username string) (*User, error)
+	SelectUserByID(ctx context.Context, userID uuid.UUID) (*User, error)
+	SelectUserByEmail(ctx context.Context, email string) (*User, error)
+	SelectUserByStudentID(ctx context.Context, studentID string) (*User, error)
+	UpdateUser(ctx context.Context, user *User) error
+	UpdateUserPasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error
+	SelectUsersGraduatingBefore(ctx context.Context, deadline time.Time) ([]*User, error)
+	SelectUsersWithPermission(ctx context.Context, permission string) ([]*User, error)
+	SelectUsers(ctx context.Context, filter *UserFilter) ([]*User, error)
+	BackfillUserPinyin(ctx context.Context) (int, error)
+}
+
+type RoleStore interface {
+	InsertRole(ctx context.Context, role *Role) error
+	SelectRoleByID(ctx context.Context, id uuid.UUID) (*Role, error)
+	SelectRoleByName(ctx context.Context, name string) (*Role, error)
+	SelectAllRoles(ctx context.Context) ([]*Role, error)
+	UpdateRole(ctx context.Context, role *Role) error
+	DeleteRole(ctx context.Context, id uuid.UUID) error
+}
+
+type PermissionStore interface {
+	SelectAllPermissions(ctx context.Context) ([]*Permission, error)
+	SelectPermissionsByRoleName(ctx context.Context, roleName string) ([]string, error)
+	UpdateRolePermissions(ctx context.Context, roleID uuid.UUID, permissions []string) error
+	GrantAllPermissions(ctx context.Context, roleID uuid.UUID) error
+}
+
+type SessionStore interface {
+	InsertSession(ctx context.Context, s *Session) error
+	TouchSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	SelectActiveSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) error
+	RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID) (int64, error)
+}
+
+type APITokenStore interface {
+	InsertAPIToken(ctx context.Context, t *APIToken) error
+	TouchAPIToken(ctx context.Context, tokenHash string) (*APIToken, error)
+	SelectActiveAPITokensByUserID(ctx context.Context, userID uuid.UUID) ([]*APIToken, error)
+	RevokeAPIToken(ctx context.Context, userID, id uuid.UUID) error
+	RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type AuditEventStore interface {
+	InsertAuditEvent(ctx context.Context, event *AuditEvent) error
+	SelectAuditEvents(ctx context.Context, filter *AuditEventFilter) ([]*AuditEvent, int, error)
+}
+
+type JWTSigningKeyStore interface {
+	SelectJWTSigningKeys(ctx context.Context) ([]*JWTSigningKey, error)
+	InsertJWTSigningKey(ctx context.Context, k *JWTSigningKey) error
+	UpdateJWTSigningKeyPrivateKey(ctx context.Context, id uuid.UUID, privateKey []byte) error
+	DeleteExpiredJWTSigningKeys(ctx context.Context) error
+	LockJWTSigningKeys(ctx context.Context) error
+}
+
+type LoginAttemptStore interface {
+	SelectLoginAttempt(ctx context.Context, scope, key string) (*LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, scope, key string, window, lockout time.Duration, maxFailures int) (*LoginAttempt, error)
+	DeleteLoginAttempt(ctx context.Context, scope, key string) error
+	UnlockLogin(ctx context.Context, scope, key string, unlockedBy uuid.UUID) (int64, error)
+	SelectLoginLockEventByID(ctx context.Context, id uuid.UUID) (*LoginLockEvent, error)
+	SelectLoginLockEvents(ctx context.Context, filter *LoginLockEventFilter) ([]*LoginLockEvent, int, error)
+}
+
+type PasswordResetTokenStore interface {
+	InsertPasswordResetToken(ctx context.Context, t *PasswordResetToken) error
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (uuid.UUID, error)
+}
+
+type UserInvitationStore interface {
+	InsertUserInvitation(ctx context.Context, inv *UserInvitation) error
+	RevokeUserInvitations(ctx context.Context, userID uuid.UUID) (int64, error)
+	ConsumeUserInvitation(ctx context.Context, tokenHash string) (uuid.UUID, error)
+}
+
+type UserTOTPStore interface {
+	SelectUserTOTP(ctx context.Context, userID uuid.UUID) (*UserTOTP, error)
+	UpsertPendingUserTOTP(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableUserTOTP(ctx context.Context, userID uuid.UUID, step int64) error
+	UseUserTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+type ScheduleTemplateStore interface {
+	InsertScheduleTemplate(ctx context.Context, st *ScheduleTemplate) error
+	SelectScheduleTemplate(ctx context.Context, id uuid.UUID) (*ScheduleTemplate, error)
+	SelectScheduleTemplateByName(ctx context.Context, name string) (*ScheduleTemplate, error)
+	SelectAllScheduleTemplateMeta(ctx context.Context) ([]*ScheduleTemplate, error)
+	DeleteScheduleTemplate(ctx context.Context, id uuid.UUID) error
+	UpdateScheduleTemplateDescription(ctx context.Context, id uuid.UUID, description string) (*ScheduleTemplate, error)
+}
+
+type SchedulePlanStore interface {
+	InsertSchedulePlan(ctx context.Context, sp *SchedulePlan) error
+	SelectSchedulePlanByID(ctx context.Context, id uuid.UUID) (*SchedulePlan, error)
+	PublishSchedulePlan(ctx context.Context, sp *SchedulePlan) error
+	SelectSchedulePlanAssignments(ctx context.Context, schedulePlanID uuid.UUID) ([]*SchedulePlanAssignment, error)
+	ReplaceSchedulePlanAssignments(ctx context.Context, schedulePlanID uuid.UUID, assignments []*SchedulePlanAssignment) error
+}
+
+// NotificationStore passes messages between instances of the api, through
+// LISTEN/NOTIFY in Postgres.
+type NotificationStore interface {
+	Notify(ctx context.Context, channel, payload string) error
+	Listen(ctx context.Context, channel string, fn func(payload string)) error
+}
+
+// JobRunStore keeps periodic jobs to one run per period across instances of
+// the api.
+type JobRunStore interface {
+	LockJobRun(ctx context.Context, name string) (*JobRun, error)
+	UpdateJobRun(ctx context.Context, name string, ranAt time.Time) error
+}
+
+var _ Store = (*Models)(nil)
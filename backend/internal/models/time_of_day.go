@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const timeOfDayLayout = "15:04:05"
+
+// TimeOfDay is a Postgres TIME: the time since midnight, down to the
+// microsecond. It reads and writes JSON as "15:04:05".
+type TimeOfDay time.Duration
+
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse(timeOfDayLayout, s)
+	if err != nil {
+		return 0, err
+	}
+	return TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second), nil
+}
+
+// Format formats the time of day like time.Time.Format.
+func (t TimeOfDay) Format(layout string) string {
+	return time.Time{}.Add(time.Duration(t)).Format(layout)
+}
+
+func (t TimeOfDay) String() string {
+	return t.Format(timeOfDayLayout)
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ScanTime and TimeValue let pgx read and write TimeOfDay as TIME.
+
+func (t *TimeOfDay) ScanTime(v pgtype.Time) error {
+	if !v.Valid {
+		return errors.New("cannot scan NULL into TimeOfDay")
+	}
+	*t = TimeOfDay(time.Duration(v.Microseconds) * time.Microsecond)
+	return nil
+}
+
+func (t TimeOfDay) TimeValue() (pgtype.Time, error) {
+	return pgtype.Time{Microseconds: int64(time.Duration(t) / time.Microsecond), Valid: true}, nil
+}
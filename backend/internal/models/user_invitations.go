@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserInvitation struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// InsertUserInvitation stores a new invitation and revokes the outstanding
+// ones of the same user, so only the latest link works.
+func (m *Models) InsertUserInvitation(ctx context.Context, inv *UserInvitation) error {
+	return m.withTx(ctx, func(m *Models) error {
+		if _, err := m.RevokeUserInvitations(ctx, inv.UserID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO user_invitations (user_id, token_hash, expires_at)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at
+		`
+
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		return m.db.QueryRow(ctx, query, inv.UserID, inv.TokenHash, inv.ExpiresAt).Scan(&inv.ID, &inv.CreatedAt)
+	})
+}
+
+// RevokeUserInvitations revokes the unused invitations of a user and returns
+// how many were revoked.
+func (m *Models) RevokeUserInvitations(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `
+		UPDATE user_invitations
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// ConsumeUserInvitation marks a valid invitation as used and returns the ID
+// of the invited user, or sql.ErrNoRows if no such invitation exists.
+func (m *Models) ConsumeUserInvitation(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	query := `
+		UPDATE user_invitations
+		SET used_at = NOW()
+		WHERE token_hash = $1
+			AND used_at IS NULL
+			AND revoked_at IS NULL
+			AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var userID uuid.UUID
+	if err := m.db.QueryRow(ctx, query, tokenHash).Scan(&userID); err != nil {
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserTOTP is the TOTP secret of a user. It stays pending, with a nil
+// EnabledAt, until the user proves the authenticator app works.
+type UserTOTP struct {
+	UserID       uuid.UUID
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+}
+
+func (m *Models) SelectUserTOTP(ctx context.Context, userID uuid.UUID) (*UserTOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	t := &UserTOTP{}
+	if err := m.db.QueryRow(ctx, query, userID).Scan(&t.UserID, &t.Secret, &t.EnabledAt, &t.LastUsedStep); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// UpsertPendingUserTOTP starts a new enrollment, replacing any pending secret.
+// It returns sql.ErrNoRows if the user already has TOTP enabled.
+func (m *Models) UpsertPendingUserTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.enabled_at IS NULL
+		RETURNING user_id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, userID, secret).Scan(&userID)
+}
+
+// EnableUserTOTP enables a pending secret once a code at step has been
+// verified against it.
+func (m *Models) EnableUserTOTP(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET enabled_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL
+		RETURNING user_id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, userID, step).Scan(&userID)
+}
+
+// UseUserTOTPStep records that the code at step has been used, returning
+// sql.ErrNoRows if it or a later one was already used, so that a code
+// cannot be replayed even against another backend instance.
+func (m *Models) UseUserTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+		RETURNING user_id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return m.db.QueryRow(ctx, query, userID, step).Scan(&userID)
+}
+
+// DeleteUserTOTP turns two-factor authentication off for a user, removing
+// the secret and the recovery codes.
+func (m *Models) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	return m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		if _, err := m.db.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := m.db.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+// ReplaceRecoveryCodes discards every recovery code of a user and stores the
+// given hashes instead.
+func (m *Models) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return m.withTx(ctx, func(m *Models) error {
+		ctx, cancel := m.withQueryTimeout(ctx)
+		defer cancel()
+
+		if _, err := m.db.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO user_recovery_codes (user_id, code_hash)
+			SELECT $1, UNNEST($2::TEXT[])
+		`
+		_, err := m.db.Exec(ctx, query, userID, codeHashes)
+		return err
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used, returning
+// sql.ErrNoRows if the user has no such code.
+func (m *Models) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+		RETURNING id
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var id uuid.UUID
+	return m.db.QueryRow(ctx, query, userID, codeHash).Scan(&id)
+}
+
+func (m *Models) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM user_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	var count int
+	if err := m.db.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
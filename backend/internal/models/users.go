@@ -8,64 +8,88 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	UserStatusPending     = "pending"
+	UserStatusActive      = "active"
+	UserStatusDeactivated = "deactivated"
+	UserStatusGraduated   = "graduated"
+)
+
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"`
-	Email        string    `json:"email"`
-	FullName     string    `json:"fullName"`
-	Role         string    `json:"role"`
-	Level        int32     `json:"level"`
-	CreatedAt    time.Time `json:"createdAt"`
-	Version      int32     `json:"version"`
+	ID                 uuid.UUID  `json:"id"`
+	Username           string     `json:"username"`
+	PasswordHash       string     `json:"-"`
+	Email              string     `json:"email"`
+	FullName           string     `json:"fullName"`
+	FullNamePinyin     string     `json:"-"`
+	Role               string     `json:"role"`
+	Level              int32      `json:"level"`
+	Permissions        []string   `json:"permissions"`
+	Status             string     `json:"status"`
+	DeactivatedAt      *time.Time `json:"deactivatedAt"`
+	StudentID          string     `json:"studentId"`
+	Phone              string     `json:"phone"`
+	College            string     `json:"college"`
+	Grade              string     `json:"grade"`
+	ExpectedGraduation *time.Time `json:"expectedGraduation"`
+	Locale             string     `json:"locale"`
+	TwoFactorEnabled   bool       `json:"twoFactorEnabled"`
+	TwoFactorRequired  bool       `json:"twoFactorRequired"`
+	ImpersonatedBy     string     `json:"impersonatedBy,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	Version            int32      `json:"version"`
 }
 
-func (m *Models) InsertUser(user *User) error {
-	query := `
-		INSERT INTO users (username, email, password_hash, full_name, role_id)
-		VALUES ($1, $2, $3, $4, (SELECT id FROM roles WHERE name = $5))
-		RETURNING id, (SELECT level FROM roles WHERE name = $5), created_at, version
-	`
-	args := []any{user.Username, user.Email, user.PasswordHash, user.FullName, user.Role}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := m.db.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Level, &user.CreatedAt, &user.Version); err != nil {
-		return err
-	}
-
-	return nil
+const selectUserQuery = `
+	SELECT
+		u.id,
+		u.username,
+		u.password_hash,
+		u.email,
+		u.full_name,
+		COALESCE(u.full_name_pinyin, ''),
+		r.name,
+		r.level,
+		u.status,
+		u.deactivated_at,
+		COALESCE(u.student_id, ''),
+		u.phone,
+		u.college,
+		u.grade,
+		u.expected_graduation,
+		u.locale,
+		t.enabled_at IS NOT NULL,
+		u.created_at,
+		u.version
+	FROM users AS u
+	INNER JOIN roles AS r ON u.role_id = r.id
+	LEFT JOIN user_totp AS t ON t.user_id = u.id
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
-func (m *Models) SelectUserByUsername(username string) (*User, error) {
-	user := &User{Username: username}
-
-	query := `
-		SELECT 
-			u.id, 
-			u.password_hash, 
-			u.email, 
-			u.full_name, 
-			r.name, 
-			r.level, 
-			u.created_at,
-			u.version
-		FROM users u
-			INNER JOIN roles r ON u.role_id = r.id
-		WHERE u.username = $1
-	`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := m.db.QueryRowContext(ctx, query, username).Scan(
+func scanUser(row rowScanner) (*User, error) {
+	user := &User{}
+	if err := row.Scan(
 		&user.ID,
+		&user.Username,
 		&user.PasswordHash,
 		&user.Email,
 		&user.FullName,
+		&user.FullNamePinyin,
 		&user.Role,
 		&user.Level,
+		&user.Status,
+		&user.DeactivatedAt,
+		&user.StudentID,
+		&user.Phone,
+		&user.College,
+		&user.Grade,
+		&user.ExpectedGraduation,
+		&user.Locale,
+		&user.TwoFactorEnabled,
 		&user.CreatedAt,
 		&user.Version,
 	); err != nil {
@@ -75,44 +99,66 @@ func (m *Models) SelectUserByUsername(username string) (*User, error) {
 	return user, nil
 }
 
-func (m *Models) SelectUserByID(userID uuid.UUID) (*User, error) {
-	user := &User{ID: userID}
-
+func (m *Models) InsertUser(ctx context.Context, user *User) error {
 	query := `
-		SELECT 
-			u.username, 
-			u.password_hash, 
-			u.email, 
-			u.full_name, 
-			r.name, 
-			r.level, 
-			u.created_at,
-			u.version
-		FROM users u
-			INNER JOIN roles r ON u.role_id = r.id
-		WHERE u.id = $1
+		INSERT INTO users (username, email, password_hash, full_name, role_id, full_name_pinyin, full_name_initials, status, locale)
+		VALUES ($1, $2, $3, $4, (SELECT id FROM roles WHERE name = $5), $6, $7, $8, $9)
+		RETURNING id, (SELECT level FROM roles WHERE name = $5), created_at, version
 	`
+	if user.Status == "" {
+		user.Status = UserStatusActive
+	}
+	fullNamePinyin, fullNameInitials := FullNameToPinyin(user.FullName)
+	user.FullNamePinyin = fullNamePinyin
+	args := []any{user.Username, user.Email, user.PasswordHash, user.FullName, user.Role, fullNamePinyin, fullNameInitials, user.Status, user.Locale}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withQueryTimeout(ctx)
 	defer cancel()
 
-	if err := m.db.QueryRowContext(ctx, query, userID).Scan(
-		&user.Username,
-		&user.PasswordHash,
-		&user.Email,
-		&user.FullName,
-		&user.Role,
-		&user.Level,
-		&user.CreatedAt,
-		&user.Version,
-	); err != nil {
-		return nil, err
+	if err := m.db.QueryRow(ctx, query, args...).Scan(&user.ID, &user.Level, &user.CreatedAt, &user.Version); err != nil {
+		return err
 	}
 
-	return user, nil
+	return nil
+}
+
+func (m *Models) SelectUserByUsername(ctx context.Context, username string) (*User, error) {
+	query := selectUserQuery + `WHERE u.username = $1`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return scanUser(m.db.QueryRow(ctx, query, username))
 }
 
-func (m *Models) UpdateUser(user *User) error {
+func (m *Models) SelectUserByID(ctx context.Context, userID uuid.UUID) (*User, error) {
+	query := selectUserQuery + `WHERE u.id = $1`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return scanUser(m.db.QueryRow(ctx, query, userID))
+}
+
+func (m *Models) SelectUserByEmail(ctx context.Context, email string) (*User, error) {
+	query := selectUserQuery + `WHERE u.email = $1`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return scanUser(m.db.QueryRow(ctx, query, email))
+}
+
+func (m *Models) SelectUserByStudentID(ctx context.Context, studentID string) (*User, error) {
+	query := selectUserQuery + `WHERE u.student_id = $1`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	return scanUser(m.db.QueryRow(ctx, query, studentID))
+}
+
+func (m *Models) UpdateUser(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users
 		SET
@@ -123,52 +169,91 @@ func (m *Models) UpdateUser(user *User) error {
 				FROM roles
 				WHERE name = $3
 			),
+			status = $4,
+			deactivated_at = $5,
+			full_name = $6,
+			student_id = NULLIF($7, ''),
+			phone = $8,
+			college = $9,
+			grade = $10,
+			expected_graduation = $11,
+			full_name_pinyin = $12,
+			full_name_initials = $13,
+			locale = $14,
 			version = version + 1
-		WHERE id = $4 AND version = $5
+		WHERE id = $15 AND version = $16
+		RETURNING version
 	`
-	args := []any{user.PasswordHash, user.Email, user.Role, user.ID, user.Version}
+	fullNamePinyin, fullNameInitials := FullNameToPinyin(user.FullName)
+	user.FullNamePinyin = fullNamePinyin
+	args := []any{
+		user.PasswordHash,
+		user.Email,
+		user.Role,
+		user.Status,
+		user.DeactivatedAt,
+		user.FullName,
+		user.StudentID,
+		user.Phone,
+		user.College,
+		user.Grade,
+		user.ExpectedGraduation,
+		fullNamePinyin,
+		fullNameInitials,
+		user.Locale,
+		user.ID,
+		user.Version,
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withQueryTimeout(ctx)
 	defer cancel()
 
-	res, err := m.db.ExecContext(ctx, query, args...)
-	if err != nil {
+	if err := m.db.QueryRow(ctx, query, args...).Scan(&user.Version); err != nil {
 		return err
 	}
 
-	rows, err := res.RowsAffected()
+	return nil
+}
+
+// UpdateUserPasswordHash swaps the password hash of a user only if it is
+// still oldHash, without bumping the version, so that upgrading a hash on
+// login never conflicts with concurrent edits of the user.
+func (m *Models) UpdateUserPasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3
+	`
+
+	ctx, cancel := m.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := m.db.Exec(ctx, query, newHash, id, oldHash)
 	if err != nil {
 		return err
 	}
 
-	if rows == 0 {
+	if res.RowsAffected() == 0 {
 		return sql.ErrNoRows
 	}
 
 	return nil
 }
 
-func (m *Models) SelectAllUsers() ([]*User, error) {
-	query := `
-		SELECT
-			u.id,
-			u.username,
-			u.password_hash,
-			u.email,
-			u.full_name,
-			r.name,
-			r.level,
-			u.created_at,
-			u.version
-		FROM users AS u
-		INNER JOIN roles AS r ON u.role_id = r.id
-		ORDER BY u.created_at
+// SelectUsersGraduatingBefore returns the active users whose expected
+// graduation date falls between today and deadline.
+func (m *Models) SelectUsersGraduatingBefore(ctx context.Context, deadline time.Time) ([]*User, error) {
+	query := selectUserQuery + `
+		WHERE u.status = 'active'
+			AND u.expected_graduation >= CURRENT_DATE
+			AND u.expected_graduation <= $1
+		ORDER BY u.expected_graduation, u.full_name
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := m.withBatchTimeout(ctx)
 	defer cancel()
 
-	rows, err := m.db.QueryContext(ctx, query)
+	rows, err := m.db.Query(ctx, query, deadline)
 	if err != nil {
 		return nil, err
 	}
@@ -176,18 +261,8 @@ func (m *Models) SelectAllUsers() ([]*User, error) {
 
 	users := make([]*User, 0)
 	for rows.Next() {
-		user := &User{}
-		if err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.PasswordHash,
-			&user.Email,
-			&user.FullName,
-			&user.Role,
-			&user.Level,
-			&user.CreatedAt,
-			&user.Version,
-		); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -200,25 +275,41 @@ func (m *Models) SelectAllUsers() ([]*User, error) {
 	return users, nil
 }
 
-func (m *Models) DeleteUser(userID uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
+// SelectUsersWithPermission returns the active users whose role grants the
+// permission.
+func (m *Models) SelectUsersWithPermission(ctx context.Context, permission string) ([]*User, error) {
+	query := selectUserQuery + `
+		WHERE u.status = 'active'
+			AND EXISTS (
+				SELECT 1
+				FROM role_permissions AS rp
+				INNER JOIN permissions AS p ON rp.permission_id = p.id
+				WHERE rp.role_id = u.role_id AND p.name = $1
+			)
+		ORDER BY u.created_at
+	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := m.withBatchTimeout(ctx)
 	defer cancel()
 
-	res, err := m.db.ExecContext(ctx, query, userID)
+	rows, err := m.db.Query(ctx, query, permission)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
+	users := make([]*User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
 	}
 
-	if rows == 0 {
-		return sql.ErrNoRows
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return users, nil
 }
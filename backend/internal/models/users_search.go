@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/mozillazg/go-pinyin"
+)
+
+// userSortColumns maps the sort keys accepted by SelectUsers to the column
+// and the type its cursor value is cast to.
+var userSortColumns = map[string]struct {
+	column string
+	cast   string
+}{
+	"createdAt": {column: "u.created_at", cast: "timestamptz"},
+	"username":  {column: "u.username", cast: "text"},
+	"fullName":  {column: "COALESCE(u.full_name_pinyin, '')", cast: "text"},
+	"level":     {column: "r.level", cast: "integer"},
+}
+
+func IsValidUserSort(sort string) bool {
+	_, ok := userSortColumns[sort]
+	return ok
+}
+
+type UserCursor struct {
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+type UserFilter struct {
+	Search   string
+	Role     string
+	Statuses []string
+	Sort     string
+	Desc     bool
+	Limit    int
+	Cursor   *UserCursor
+}
+
+// NewUserCursor returns the cursor that continues a listing sorted by sort
+// right after user.
+func NewUserCursor(user *User, sort string) *UserCursor {
+	cursor := &UserCursor{ID: user.ID}
+	switch sort {
+	case "createdAt":
+		cursor.Value = user.CreatedAt.Format(time.RFC3339Nano)
+	case "username":
+		cursor.Value = user.Username
+	case "fullName":
+		cursor.Value = user.FullNamePinyin
+	case "level":
+		cursor.Value = fmt.Sprint(user.Level)
+	}
+	return cursor
+}
+
+// SelectUsers returns at most filter.Limit users matching the filter, ordered
+// by the sort column with the user ID as tie breaker.
+func (m *Models) SelectUsers(ctx context.Context, filter *UserFilter) ([]*User, error) {
+	sort, ok := userSortColumns[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q", filter.Sort)
+	}
+
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Search != "" {
+		search := arg("%" + escapeLike(strings.ToLower(filter.Search)) + "%")
+		compact := arg("%" + escapeLike(strings.ToLower(strings.Join(strings.Fields(filter.Search), ""))) + "%")
+		conditions = append(conditions, fmt.Sprintf(`(
+			u.full_name ILIKE %[1]s
+			OR u.username ILIKE %[1]s
+			OR u.email ILIKE %[1]s
+			OR u.student_id LIKE %[1]s
+			OR u.full_name_pinyin LIKE %[2]s
+			OR u.full_name_initials LIKE %[2]s
+		)`, search, compact))
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, "r.name = "+arg(filter.Role))
+	}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "u.status = ANY("+arg(filter.Statuses)+")")
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.Cursor != nil {
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s, u.id) %s (%s::%s, %s)",
+			sort.column, comparison, arg(filter.Cursor.Value), sort.cast, arg(filter.Cursor.ID),
+		))
+	}
+
+	query := selectUserQuery
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, u.id %s LIMIT %s", sort.column, direction, direction, arg(filter.Limit))
+
+	ctx, cancel := m.withBatchTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// BackfillUserPinyin computes the pinyin search columns for users created
+// before they existed. It may touch every user, so the caller picks the
+// deadline.
+func (m *Models) BackfillUserPinyin(ctx context.Context) (int, error) {
+	rows, err := m.db.Query(ctx, `SELECT id, full_name FROM users WHERE full_name_pinyin IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type pending struct {
+		id       uuid.UUID
+		fullName string
+	}
+	users := make([]pending, 0)
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.fullName); err != nil {
+			return 0, err
+		}
+		users = append(users, p)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range users {
+		fullNamePinyin, fullNameInitials := FullNameToPinyin(p.fullName)
+		query := `UPDATE users SET full_name_pinyin = $1, full_name_initials = $2 WHERE id = $3`
+		if _, err := m.db.Exec(ctx, query, fullNamePinyin, fullNameInitials, p.id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(users), nil
+}
+
+// FullNameToPinyin returns the toneless pinyin of a name and its initials,
+// so that 张伟 yields "zhangwei" and "zw". Latin words are kept as they are
+// and contribute their first letter to the initials.
+func FullNameToPinyin(fullName string) (string, string) {
+	args := pinyin.NewArgs()
+
+	var full, initials strings.Builder
+	inWord := false
+	for _, r := range strings.ToLower(fullName) {
+		if py := pinyin.SinglePinyin(r, args); len(py) > 0 && py[0] != "" {
+			full.WriteString(py[0])
+			initials.WriteByte(py[0][0])
+			inWord = false
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			full.WriteRune(r)
+			if !inWord {
+				initials.WriteRune(r)
+			}
+			inWord = true
+			continue
+		}
+		inWord = false
+	}
+
+	return full.String(), initials.String()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
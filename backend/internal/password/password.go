@@ -0,0 +1,203 @@
+// Package password hashes passwords with argon2id, verifies the bcrypt
+// hashes stored before argon2id was introduced, and enforces the password
+// policy.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+
+	// argon2id has no input limit, but hashing megabytes of "password"
+	// would be an easy way to burn server time
+	maxLength = 128
+)
+
+var errMalformedHash = errors.New("password: malformed hash")
+
+// PolicyError is returned by Validate. Code names the rule that failed, and
+// the message for the user in the i18n catalogs.
+type PolicyError struct {
+	Code string
+	Args []any
+}
+
+func (e *PolicyError) Error() string {
+	return i18n.T(i18n.Default, "error."+e.Code, e.Args...)
+}
+
+func policyError(code string, args ...any) error {
+	return &PolicyError{Code: code, Args: args}
+}
+
+type params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+type Hasher struct {
+	params     params
+	minLength  int
+	minClasses int
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func New(cfg *config.Config) *Hasher {
+	return &Hasher{
+		params: params{
+			memory:      uint32(cfg.Password.Argon2Memory),
+			iterations:  uint32(cfg.Password.Argon2Iterations),
+			parallelism: uint8(cfg.Password.Argon2Parallelism),
+		},
+		minLength:  cfg.Password.MinLength,
+		minClasses: cfg.Password.MinClasses,
+	}
+}
+
+// Hash returns the argon2id hash of a password in the PHC string format.
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.iterations, h.params.memory, h.params.parallelism, keyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.memory,
+		h.params.iterations,
+		h.params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether the password matches the hash, and whether the hash
+// should be replaced because it is bcrypt or uses outdated parameters.
+func (h *Hasher) Verify(password, hash string) (match bool, needsRehash bool, err error) {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		switch {
+		case err == nil:
+			return true, true, nil
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, false, nil
+		default:
+			return false, false, err
+		}
+	}
+
+	p, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	return true, p != h.params || len(salt) != saltLength || len(key) != keyLength, nil
+}
+
+// VerifyDummy spends the same time as verifying a real hash, so that logins
+// for unknown users cannot be told apart by how quickly they fail.
+func (h *Hasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummyHash, _ = h.Hash("dummy password")
+	})
+	_, _, _ = h.Verify(password, h.dummyHash)
+}
+
+// Validate checks a new password against the policy. The username may be
+// empty when it is not known yet.
+func (h *Hasher) Validate(password, username string) error {
+	length := utf8.RuneCountInString(password)
+	switch {
+	case length < h.minLength:
+		return policyError("password_too_short", h.minLength)
+	case length > maxLength:
+		return policyError("password_too_long", maxLength)
+	case username != "" && strings.EqualFold(password, username):
+		return policyError("password_same_as_username")
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < h.minClasses {
+		return policyError("password_too_simple", h.minClasses)
+	}
+
+	return nil
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func decodeArgon2id(hash string) (params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params{}, nil, nil, errMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params{}, nil, nil, errMalformedHash
+	}
+
+	var p params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return params{}, nil, nil, errMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params{}, nil, nil, errMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params{}, nil, nil, errMalformedHash
+	}
+
+	return p, salt, key, nil
+}
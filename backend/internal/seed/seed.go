@@ -1,21 +1,27 @@
 package seed
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"log/slog"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/password"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/utils"
 )
 
+// randomUserPassword is shared by every generated user.
+const randomUserPassword = "ecnc@8403"
+
 type Seed struct {
 	logger *slog.Logger
 	config *config.Config
-	models *models.Models
+	models models.Store
 }
 
-func New(logger *slog.Logger) (*Seed, *sql.DB, error) {
+func New(logger *slog.Logger) (*Seed, *pgxpool.Pool, error) {
 	seed := &Seed{
 		logger: logger,
 	}
@@ -30,7 +36,7 @@ func New(logger *slog.Logger) (*Seed, *sql.DB, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	seed.models = models.New(db)
+	seed.models = models.New(db, seed.config)
 
 	return seed, db, nil
 }
@@ -38,13 +44,24 @@ func New(logger *slog.Logger) (*Seed, *sql.DB, error) {
 func (seed *Seed) AddRandomUsers(n int) (int, error) {
 	successCnt := n
 
+	roles, err := seed.models.SelectAllRoles(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if len(roles) == 0 {
+		return 0, errors.New("no roles to assign")
+	}
+
+	// argon2id is slow on purpose, so hash the shared password only once
+	passwordHash, err := password.New(seed.config).Hash(randomUserPassword)
+	if err != nil {
+		return 0, err
+	}
+
 	for i := 0; i < n; i++ {
-		randomUser, err := utils.GenerateRandomUser()
-		if err != nil {
-			return 0, err
-		}
+		randomUser := utils.GenerateRandomUser(roles, passwordHash)
 
-		if err := seed.models.InsertUser(randomUser); err != nil {
+		if err := seed.models.InsertUser(context.Background(), randomUser); err != nil {
 			seed.logger.Error(
 				"failed to insert user",
 				slog.String("error", err.Error()),
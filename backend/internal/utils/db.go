@@ -2,17 +2,15 @@ package utils
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
 )
 
-func OpenDB(cfg *config.Config) (*sql.DB, error) {
-	db, err := sql.Open(
-		"pgx",
+func OpenDB(cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(
 		fmt.Sprintf(
 			"postgres://%s:%s@%s:5432/%s?sslmode=disable",
 			cfg.Postgres.User,
@@ -25,17 +23,22 @@ func OpenDB(cfg *config.Config) (*sql.DB, error) {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxIdleTime(15 * time.Minute)
+	poolConfig.MaxConns = cfg.Postgres.MaxConns
+	poolConfig.MinConns = cfg.Postgres.MinConns
+	poolConfig.MaxConnIdleTime = cfg.Postgres.MaxConnIdleTime
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := db.PingContext(ctx); err != nil {
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(ctx); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	return db, err
+	return db, nil
 }
@@ -5,18 +5,8 @@ import (
 
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
 	"github.com/mozillazg/go-pinyin"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func GenerateRandomPassword(length int) string {
-	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*")
-	random_password := make([]rune, length)
-	for i := range random_password {
-		random_password[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(random_password)
-}
-
 func generateRandomChineseName() string {
 	var commonSurnames = []string{
 		"王", "李", "张", "刘", "陈", "杨", "赵", "黄", "周", "吴",
@@ -45,12 +35,11 @@ func generateRandomDigit() string {
 	return string(digits[rand.Intn(len(digits))])
 }
 
-func generateRandomRole() string {
-	var roles = []string{"普通助理", "资深助理", "黑心"}
-	return roles[rand.Intn(len(roles))]
+func generateRandomRole(roles []*models.Role) string {
+	return roles[rand.Intn(len(roles))].Name
 }
 
-func GenerateRandomUser() (*models.User, error) {
+func GenerateRandomUser(roles []*models.Role, passwordHash string) *models.User {
 	user := &models.User{}
 
 	// generate full name
@@ -76,15 +65,10 @@ func GenerateRandomUser() (*models.User, error) {
 	user.Email = username + "@mail2.sysu.edu.cn"
 
 	// generate role
-	user.Role = generateRandomRole()
+	user.Role = generateRandomRole(roles)
 
 	// assign a password
-	password := "ecnc@8403"
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-	user.PasswordHash = string(passwordHash)
+	user.PasswordHash = passwordHash
 
-	return user, nil
+	return user
 }
@@ -1,49 +1,54 @@
 package utils
 
 import (
-	"fmt"
 	"net/mail"
-	"time"
+	"regexp"
 
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
 	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
 )
 
+var (
+	studentIDRegexp = regexp.MustCompile(`^\d{8}$`)
+	phoneRegexp     = regexp.MustCompile(`^1\d{10}$`)
+)
+
 func IsValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
 }
 
-func IsValidRole(role string) bool {
-	return role == "普通助理" || role == "资深助理" || role == "黑心"
+func IsValidStudentID(studentID string) bool {
+	return studentIDRegexp.MatchString(studentID)
+}
+
+func IsValidPhone(phone string) bool {
+	return phoneRegexp.MatchString(phone)
+}
+
+// ScheduleTemplateError is returned by ValidateScheduleTemplate. Code names
+// the problem, and the message for the user in the i18n catalogs.
+type ScheduleTemplateError struct {
+	Code string
+	Args []any
+}
+
+func (e *ScheduleTemplateError) Error() string {
+	return i18n.T(i18n.Default, "error."+e.Code, e.Args...)
 }
 
 func ValidateScheduleTemplate(st *models.ScheduleTemplate) error {
 	for i := 0; i < len(st.Shifts); i++ {
-		startTime, err := time.Parse("15:04:05", st.Shifts[i].StartTime)
-		if err != nil {
-			return fmt.Errorf("班次 %d 的开始时间格式无效", i)
-		}
-		endTime, err := time.Parse("15:04:05", st.Shifts[i].EndTime)
-		if err != nil {
-			return fmt.Errorf("班次 %d 的结束时间格式无效", i)
-		}
-		if startTime.After(endTime) {
-			return fmt.Errorf("班次 %d 的开始时间晚于结束时间", i)
+		if st.Shifts[i].StartTime > st.Shifts[i].EndTime {
+			return &ScheduleTemplateError{Code: "shift_time_reversed", Args: []any{i}}
 		}
 	}
 
 	for i := 0; i < len(st.Shifts); i++ {
 		for j := i + 1; j < len(st.Shifts); j++ {
-			iEndTime, _ := time.Parse("15:04:05", st.Shifts[i].EndTime)
-			jStartTime, _ := time.Parse("15:04:05", st.Shifts[j].StartTime)
-			iStartTime, _ := time.Parse("15:04:05", st.Shifts[i].StartTime)
-			jEndTime, _ := time.Parse("15:04:05", st.Shifts[j].EndTime)
-
-			if !(iEndTime.Before(jStartTime) ||
-				iEndTime.Equal(jStartTime) ||
-				iStartTime.After(jEndTime) ||
-				iStartTime.Equal(jEndTime)) {
-				return fmt.Errorf("班次 %d 与班次 %d 有时间冲突", i, j)
+			if !(st.Shifts[i].EndTime <= st.Shifts[j].StartTime ||
+				st.Shifts[i].StartTime >= st.Shifts[j].EndTime) {
+				return &ScheduleTemplateError{Code: "shift_overlap", Args: []any{i, j}}
 			}
 		}
 	}
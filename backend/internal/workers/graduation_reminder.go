@@ -0,0 +1,153 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/i18n"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+)
+
+// GraduationReminder emails every user manager a weekly list of the
+// assistants expected to graduate within the configured number of months.
+type GraduationReminder struct {
+	config *config.Config
+	logger *slog.Logger
+	models models.Store
+	mail   MailPublisher
+}
+
+func NewGraduationReminder(config *config.Config, logger *slog.Logger, models models.Store, mail MailPublisher) *GraduationReminder {
+	return &GraduationReminder{
+		config: config,
+		logger: logger,
+		models: models,
+		mail:   mail,
+	}
+}
+
+// the weekly digest is due on Mondays at this hour, local time
+const graduationReminderHour = 9
+
+func (gr *GraduationReminder) Run(ctx context.Context) {
+	if gr.config.GraduationReminder.Months <= 0 {
+		gr.logger.Info("graduation reminder disabled")
+		return
+	}
+
+	go func() {
+		for {
+			// a digest missed while no instance was running goes out on start
+			now := time.Now()
+			wait := time.Until(weeklySlot(now).AddDate(0, 0, 7))
+			if err := gr.runIfDue(ctx, now); err != nil {
+				gr.logger.Error("failed to send graduation reminder", slog.String("error", err.Error()))
+				wait = min(wait, time.Hour)
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+// weeklySlot returns the time the digest of the week of t is due, the last
+// Monday at graduationReminderHour not after t.
+func weeklySlot(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) - int(time.Monday) + 7) % 7
+	slot := time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, graduationReminderHour, 0, 0, 0, t.Location())
+	if slot.After(t) {
+		slot = slot.AddDate(0, 0, -7)
+	}
+	return slot
+}
+
+// runIfDue sends the digest unless it went out since this week's slot. The
+// job run stays locked until the run is recorded, so that instances starting
+// together send it once. The mails are published only once the run is
+// committed: a failure then loses the digest of the week rather than sending
+// it twice.
+func (gr *GraduationReminder) runIfDue(ctx context.Context, now time.Time) error {
+	slot := weeklySlot(now)
+
+	var mails []MailPayload
+	if err := gr.models.WithTx(ctx, func(m models.Store) error {
+		run, err := m.LockJobRun(ctx, models.JobGraduationReminder)
+		if err != nil {
+			return err
+		}
+		if run.LastRunAt != nil && !run.LastRunAt.Before(slot) {
+			return nil
+		}
+
+		if mails, err = gr.digest(ctx, m, now); err != nil {
+			return err
+		}
+		return m.UpdateJobRun(ctx, models.JobGraduationReminder, now)
+	}); err != nil {
+		return err
+	}
+
+	return gr.publish(ctx, mails)
+}
+
+// digest renders the mail of every user manager, or none when nobody
+// graduates soon.
+func (gr *GraduationReminder) digest(ctx context.Context, m models.Store, now time.Time) ([]MailPayload, error) {
+	users, err := m.SelectUsersGraduatingBefore(ctx, now.AddDate(0, gr.config.GraduationReminder.Months, 0))
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	admins, err := m.SelectUsersWithPermission(ctx, models.PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]any{
+		"Months": gr.config.GraduationReminder.Months,
+		"Users":  users,
+	}
+
+	mails := make([]MailPayload, 0, len(admins))
+	for _, admin := range admins {
+		subject, body, err := i18n.Mail(admin.Locale, "graduation_reminder", data)
+		if err != nil {
+			return nil, err
+		}
+		mails = append(mails, MailPayload{
+			To:      admin.Email,
+			Subject: subject,
+			Body:    body,
+		})
+	}
+
+	gr.logger.Info("graduation reminder due", slog.Int("graduating", len(users)), slog.Int("recipients", len(admins)))
+	return mails, nil
+}
+
+// publish queues every mail, carrying on past the ones that fail.
+func (gr *GraduationReminder) publish(ctx context.Context, mails []MailPayload) error {
+	var errs []error
+	for _, mail := range mails {
+		publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := gr.mail.PublishMail(publishCtx, mail); err != nil {
+			errs = append(errs, fmt.Errorf("mail to %s: %w", mail.To, err))
+		}
+		cancel()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package workers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/config"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models"
+	"github.com/jonathanhu237/ecnc-shift-manager/backend/internal/models/memory"
+)
+
+func TestWeeklySlot(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{"monday before the hour", time.Date(2026, 10, 19, 8, 59, 0, 0, shanghai), time.Date(2026, 10, 12, 9, 0, 0, 0, shanghai)},
+		{"monday at the hour", time.Date(2026, 10, 19, 9, 0, 0, 0, shanghai), time.Date(2026, 10, 19, 9, 0, 0, 0, shanghai)},
+		{"wednesday", time.Date(2026, 10, 21, 15, 0, 0, 0, shanghai), time.Date(2026, 10, 19, 9, 0, 0, 0, shanghai)},
+		{"sunday", time.Date(2026, 10, 25, 23, 0, 0, 0, shanghai), time.Date(2026, 10, 19, 9, 0, 0, 0, shanghai)},
+		{"across a month", time.Date(2026, 11, 1, 12, 0, 0, 0, shanghai), time.Date(2026, 10, 26, 9, 0, 0, 0, shanghai)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weeklySlot(tt.t); !got.Equal(tt.want) {
+				t.Errorf("weeklySlot(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraduationReminderRunsOncePerWeek(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	cfg := &config.Config{}
+	cfg.GraduationReminder.Months = 6
+	gr := NewGraduationReminder(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), store, NewLogMailPublisher(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	lastRun := func() *time.Time {
+		t.Helper()
+		var run *models.JobRun
+		if err := store.WithTx(ctx, func(m models.Store) error {
+			var err error
+			run, err = m.LockJobRun(ctx, models.JobGraduationReminder)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return run.LastRunAt
+	}
+
+	wednesday := time.Date(2026, 10, 21, 15, 0, 0, 0, time.Local)
+	if err := gr.runIfDue(ctx, wednesday); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastRun(); got == nil || !got.Equal(wednesday) {
+		t.Fatalf("last run = %v, want %v", got, wednesday)
+	}
+
+	// the digest of the week has gone out already
+	if err := gr.runIfDue(ctx, wednesday.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastRun(); !got.Equal(wednesday) {
+		t.Fatalf("last run = %v, want %v", got, wednesday)
+	}
+
+	nextMonday := time.Date(2026, 10, 26, 9, 0, 0, 0, time.Local)
+	if err := gr.runIfDue(ctx, nextMonday); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastRun(); !got.Equal(nextMonday) {
+		t.Fatalf("last run = %v, want %v", got, nextMonday)
+	}
+}
+
+func TestGraduationReminderDigest(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	cfg := &config.Config{}
+	cfg.GraduationReminder.Months = 6
+	gr := NewGraduationReminder(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), store, NewLogMailPublisher(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	// the store compares graduations with the clock
+	now := time.Now()
+
+	// nobody graduates, so there is nothing to send
+	mails, err := gr.digest(ctx, store, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 0 {
+		t.Fatalf("got %d mails, want none", len(mails))
+	}
+
+	role, err := store.SelectRoleByName(ctx, "黑心")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateRolePermissions(ctx, role.ID, []string{models.PermissionUsersManage}); err != nil {
+		t.Fatal(err)
+	}
+	admin := &models.User{Username: "admin", Email: "admin@example.com", FullName: "Admin", Role: "黑心", Locale: "en"}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", FullName: "Alice", Role: "普通助理"}
+	for _, user := range []*models.User{admin, alice} {
+		if err := store.InsertUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the expected graduation is part of the profile, not of the account
+	graduation := now.AddDate(0, 3, 0)
+	alice.ExpectedGraduation = &graduation
+	if err := store.UpdateUser(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	mails, err = gr.digest(ctx, store, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 1 || mails[0].To != "admin@example.com" || !strings.Contains(mails[0].Body, "Alice") {
+		t.Fatalf("got %+v, want one mail to admin@example.com naming Alice", mails)
+	}
+}
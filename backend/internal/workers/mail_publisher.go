@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// MailPublisher queues mails for delivery.
+type MailPublisher interface {
+	PublishMail(ctx context.Context, mailPayload MailPayload) error
+}
+
+// AMQPMailPublisher queues mails on the mail_queue of RabbitMQ, from which
+// the MailSender sends them.
+type AMQPMailPublisher struct {
+	ch *amqp.Channel
+}
+
+func NewAMQPMailPublisher(ch *amqp.Channel) *AMQPMailPublisher {
+	return &AMQPMailPublisher{
+		ch: ch,
+	}
+}
+
+func (p *AMQPMailPublisher) PublishMail(ctx context.Context, mailPayload MailPayload) error {
+	jsonData, err := json.Marshal(mailPayload)
+	if err != nil {
+		return err
+	}
+
+	return p.ch.PublishWithContext(
+		ctx,
+		"",
+		"mail_queue",
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        jsonData,
+		},
+	)
+}
+
+// LogMailPublisher writes mails to the log instead of sending them, so that
+// the api runs without RabbitMQ in development while the links of its mails
+// can still be followed.
+type LogMailPublisher struct {
+	logger *slog.Logger
+}
+
+func NewLogMailPublisher(logger *slog.Logger) *LogMailPublisher {
+	return &LogMailPublisher{
+		logger: logger,
+	}
+}
+
+func (p *LogMailPublisher) PublishMail(ctx context.Context, mailPayload MailPayload) error {
+	p.logger.Info("mail not sent",
+		slog.String("to", mailPayload.To),
+		slog.String("subject", mailPayload.Subject),
+		slog.String("body", mailPayload.Body),
+	)
+	return nil
+}
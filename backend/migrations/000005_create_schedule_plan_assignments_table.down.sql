@@ -0,0 +1 @@
+DROP TABLE IF EXISTS schedule_plan_assignments;
\ No newline at end of file
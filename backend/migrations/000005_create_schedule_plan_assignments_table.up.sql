@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS schedule_plan_assignments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_plan_id UUID NOT NULL REFERENCES schedule_plans(id) ON DELETE CASCADE,
+    schedule_template_shift_id UUID NOT NULL REFERENCES schedule_template_shifts(id) ON DELETE CASCADE,
+    day_of_week INTEGER NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (schedule_plan_id, schedule_template_shift_id, day_of_week, user_id)
+);
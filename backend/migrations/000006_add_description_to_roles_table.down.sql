@@ -0,0 +1,4 @@
+ALTER TABLE roles
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+ALTER TABLE roles
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
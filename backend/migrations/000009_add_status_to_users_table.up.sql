@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'deactivated', 'graduated')),
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
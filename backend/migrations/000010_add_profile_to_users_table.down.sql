@@ -0,0 +1,6 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS student_id,
+    DROP COLUMN IF EXISTS phone,
+    DROP COLUMN IF EXISTS college,
+    DROP COLUMN IF EXISTS grade,
+    DROP COLUMN IF EXISTS expected_graduation;
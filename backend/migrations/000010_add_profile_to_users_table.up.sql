@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS student_id TEXT UNIQUE,
+    ADD COLUMN IF NOT EXISTS phone TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS college TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS grade TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expected_graduation DATE;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS users_full_name_initials_trgm_idx;
+DROP INDEX IF EXISTS users_full_name_pinyin_trgm_idx;
+DROP INDEX IF EXISTS users_username_trgm_idx;
+DROP INDEX IF EXISTS users_full_name_trgm_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS full_name_pinyin,
+    DROP COLUMN IF EXISTS full_name_initials;
@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS full_name_pinyin TEXT,
+    ADD COLUMN IF NOT EXISTS full_name_initials TEXT;
+
+CREATE INDEX IF NOT EXISTS users_full_name_trgm_idx ON users USING GIN (full_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_username_trgm_idx ON users USING GIN (username gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_full_name_pinyin_trgm_idx ON users USING GIN (full_name_pinyin gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_full_name_initials_trgm_idx ON users USING GIN (full_name_initials gin_trgm_ops);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_invitations;
+
+UPDATE users SET status = 'deactivated', deactivated_at = NOW() WHERE status = 'pending';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users ADD CONSTRAINT users_status_check
+    CHECK (status IN ('active', 'deactivated', 'graduated'));
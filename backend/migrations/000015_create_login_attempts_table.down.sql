@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS login_lock_events;
+DROP TABLE IF EXISTS login_attempts;
\ No newline at end of file
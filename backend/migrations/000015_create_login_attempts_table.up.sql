@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    scope TEXT NOT NULL CHECK (scope IN ('username', 'ip')),
+    key TEXT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE IF NOT EXISTS login_lock_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    scope TEXT NOT NULL CHECK (scope IN ('username', 'ip')),
+    key TEXT NOT NULL,
+    failures INT NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    unlocked_at TIMESTAMPTZ,
+    unlocked_by UUID REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS login_lock_events_scope_key_idx ON login_lock_events (scope, key);
+CREATE INDEX IF NOT EXISTS login_lock_events_created_at_idx ON login_lock_events (created_at);
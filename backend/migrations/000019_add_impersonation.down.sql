@@ -0,0 +1,9 @@
+DELETE FROM permissions WHERE name = 'users.impersonate';
+
+ALTER TABLE audit_events
+    DROP COLUMN IF EXISTS impersonator_username,
+    DROP COLUMN IF EXISTS impersonator_id;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS impersonator_session_id,
+    DROP COLUMN IF EXISTS impersonator_id;
@@ -0,0 +1,15 @@
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS impersonator_id UUID REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS impersonator_session_id UUID REFERENCES sessions(id);
+
+ALTER TABLE audit_events
+    ADD COLUMN IF NOT EXISTS impersonator_id UUID,
+    ADD COLUMN IF NOT EXISTS impersonator_username TEXT;
+
+INSERT INTO permissions (name, description) VALUES
+    ('users.impersonate', '以其他用户身份查看系统');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.level >= 3 AND p.name = 'users.impersonate';
@@ -0,0 +1,10 @@
+DELETE FROM login_attempts WHERE scope IN ('reset_email', 'reset_ip');
+DELETE FROM login_lock_events WHERE scope IN ('reset_email', 'reset_ip');
+
+ALTER TABLE login_attempts
+    DROP CONSTRAINT IF EXISTS login_attempts_scope_check,
+    ADD CONSTRAINT login_attempts_scope_check CHECK (scope IN ('username', 'ip'));
+
+ALTER TABLE login_lock_events
+    DROP CONSTRAINT IF EXISTS login_lock_events_scope_check,
+    ADD CONSTRAINT login_lock_events_scope_check CHECK (scope IN ('username', 'ip'));
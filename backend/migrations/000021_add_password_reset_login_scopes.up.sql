@@ -0,0 +1,7 @@
+ALTER TABLE login_attempts
+    DROP CONSTRAINT IF EXISTS login_attempts_scope_check,
+    ADD CONSTRAINT login_attempts_scope_check CHECK (scope IN ('username', 'ip', 'reset_email', 'reset_ip'));
+
+ALTER TABLE login_lock_events
+    DROP CONSTRAINT IF EXISTS login_lock_events_scope_check,
+    ADD CONSTRAINT login_lock_events_scope_check CHECK (scope IN ('username', 'ip', 'reset_email', 'reset_ip'));
@@ -0,0 +1 @@
+ALTER TABLE schedule_plans DROP COLUMN IF EXISTS published_at;
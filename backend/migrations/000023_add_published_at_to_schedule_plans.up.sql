@@ -0,0 +1 @@
+ALTER TABLE schedule_plans ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
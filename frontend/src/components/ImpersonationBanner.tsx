@@ -0,0 +1,53 @@
+import { api, APIResponse } from "@/lib/api";
+import { UserType } from "@/types/user";
+import { useMutation, useQueryClient } from "@tanstack/react-query";
+import { AxiosResponse } from "axios";
+import { Eye } from "lucide-react";
+import { useNavigate } from "react-router";
+import { toast } from "sonner";
+import { Alert, AlertDescription, AlertTitle } from "./ui/alert";
+import { Button } from "./ui/button";
+
+export default function ImpersonationBanner() {
+  const queryClient = useQueryClient();
+  const navigate = useNavigate();
+  const myInfo: UserType | undefined = queryClient.getQueryData(["me"]);
+
+  const mutation = useMutation({
+    mutationFn: () => api.post("/auth/stop-impersonation"),
+    onSuccess: (res: AxiosResponse<APIResponse<UserType>>) => {
+      toast.success(res.data.message);
+      queryClient.clear();
+      queryClient.setQueryData(["me"], res.data.data);
+      navigate("/");
+    },
+    onError: (err) => {
+      toast.error(err.message);
+    },
+  });
+
+  if (!myInfo?.impersonatedBy) {
+    return null;
+  }
+
+  return (
+    <Alert className="mb-4">
+      <Eye className="h-4 w-4" />
+      <AlertTitle>正在以 {myInfo.fullName} 的身份查看</AlertTitle>
+      <AlertDescription className="flex items-center justify-between">
+        <span>
+          管理员 {myInfo.impersonatedBy}{" "}
+          的操作均会记录在审计日志中，且无法修改该用户的密码等敏感信息。
+        </span>
+        <Button
+          size="sm"
+          variant="outline"
+          disabled={mutation.isPending}
+          onClick={() => mutation.mutate()}
+        >
+          结束模拟
+        </Button>
+      </AlertDescription>
+    </Alert>
+  );
+}
@@ -0,0 +1,150 @@
+import { z } from "zod";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import {
+  Form,
+  FormControl,
+  FormDescription,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from "@/components/ui/form";
+import { useMutation, useQueryClient } from "@tanstack/react-query";
+import { api, APIResponse } from "@/lib/api";
+import { useState } from "react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import { AlertCircle, Loader2 } from "lucide-react";
+import { toast } from "sonner";
+import { useNavigate } from "react-router";
+import { AxiosResponse } from "axios";
+import { UserType } from "@/types/user";
+
+const formSchema = z.object({
+  code: z.string().trim().min(1, {
+    message: "验证码不能为空",
+  }),
+});
+
+interface Props {
+  partialToken: string;
+  onCancel: () => void;
+}
+
+// SecondFactorForm finishes a login that the password or single sign-on
+// has started, with a code of the authenticator app or a recovery code.
+export default function SecondFactorForm({ partialToken, onCancel }: Props) {
+  const form = useForm<z.infer<typeof formSchema>>({
+    resolver: zodResolver(formSchema),
+    defaultValues: {
+      code: "",
+    },
+  });
+
+  const navigate = useNavigate();
+  const queryClient = useQueryClient();
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  const [errorMessage, setErrorMessage] = useState<string>("");
+
+  const mutation = useMutation<
+    AxiosResponse<APIResponse<UserType>>,
+    Error,
+    { partialToken: string; code: string; recoveryCode: string }
+  >({
+    mutationFn: (data) => api.post("/auth/login/2fa", data),
+    onSuccess: (res) => {
+      const { message, data } = res.data;
+
+      toast.success(message);
+      navigate("/");
+      queryClient.setQueryData(["me"], data);
+    },
+    onError: (err) => {
+      setErrorMessage(err.message);
+    },
+  });
+
+  const onSubmit = (formData: z.infer<typeof formSchema>) => {
+    setErrorMessage("");
+    mutation.mutate({
+      partialToken,
+      code: useRecoveryCode ? "" : formData.code,
+      recoveryCode: useRecoveryCode ? formData.code : "",
+    });
+  };
+
+  return (
+    <Form {...form}>
+      <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
+        <FormField
+          control={form.control}
+          name="code"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>{useRecoveryCode ? "恢复码" : "验证码"}</FormLabel>
+              <FormControl>
+                <Input
+                  autoComplete="one-time-code"
+                  inputMode={useRecoveryCode ? "text" : "numeric"}
+                  placeholder={
+                    useRecoveryCode
+                      ? "请输入一个未使用过的恢复码。"
+                      : "请输入验证器应用中的 6 位验证码。"
+                  }
+                  {...field}
+                />
+              </FormControl>
+              <FormDescription>
+                {useRecoveryCode
+                  ? "每个恢复码只能使用一次。"
+                  : "无法使用验证器应用时，可以改用恢复码。"}
+              </FormDescription>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        {errorMessage && (
+          <Alert variant="destructive">
+            <AlertCircle className="h-4 w-4" />
+            <AlertTitle>验证失败</AlertTitle>
+            <AlertDescription>{errorMessage}</AlertDescription>
+          </Alert>
+        )}
+        <Button type="submit" className="w-full" disabled={mutation.isPending}>
+          {mutation.isPending ? (
+            <>
+              <Loader2 className="animate-spin" />
+              请稍等
+            </>
+          ) : (
+            "验证"
+          )}
+        </Button>
+        <div className="flex justify-between">
+          <Button
+            type="button"
+            variant="link"
+            className="px-0"
+            onClick={() => {
+              setUseRecoveryCode(!useRecoveryCode);
+              setErrorMessage("");
+              form.reset();
+            }}
+          >
+            {useRecoveryCode ? "使用验证码" : "使用恢复码"}
+          </Button>
+          <Button
+            type="button"
+            variant="link"
+            className="px-0"
+            onClick={onCancel}
+          >
+            返回登录
+          </Button>
+        </div>
+      </form>
+    </Form>
+  );
+}
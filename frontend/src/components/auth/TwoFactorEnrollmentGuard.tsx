@@ -0,0 +1,24 @@
+import { UserType } from "@/types/user";
+import { useQueryClient } from "@tanstack/react-query";
+import { PropsWithChildren } from "react";
+import { Navigate } from "react-router";
+
+// TwoFactorEnrollmentGuard sends users whose role requires two-factor
+// authentication to the setup page until they have enabled it, since the
+// api refuses everything else to them. It must be used inside AuthGuard.
+export default function TwoFactorEnrollmentGuard({
+  children,
+}: PropsWithChildren) {
+  const queryClient = useQueryClient();
+  const myInfo: UserType | undefined = queryClient.getQueryData(["me"]);
+
+  // an impersonated session cannot enroll on behalf of the user
+  if (
+    myInfo?.twoFactorRequired &&
+    !myInfo.twoFactorEnabled &&
+    !myInfo.impersonatedBy
+  ) {
+    return <Navigate to="/two-factor-setup" />;
+  }
+  return children;
+}
@@ -30,6 +30,7 @@ import { api, APIResponse } from "@/lib/api";
 import { UserType } from "@/types/user";
 import { toast } from "sonner";
 import PendingButton from "@/components/PendingButton";
+import { useAssignableRoles } from "@/hooks/use-assignable-roles";
 
 interface Props {
   open: boolean;
@@ -43,7 +44,7 @@ const formSchema = z.object({
     .min(1, { message: "邮箱不能为空" })
     .email({ message: "邮箱格式不正确" }),
   fullName: z.string().min(1, { message: "姓名不能为空" }),
-  role: z.string(),
+  role: z.string().min(1, { message: "请选择身份" }),
 });
 
 export default function CreateUserDialog({ open, onOpenChange }: Props) {
@@ -53,9 +54,10 @@ export default function CreateUserDialog({ open, onOpenChange }: Props) {
       username: "",
       email: "",
       fullName: "",
-      role: "普通助理",
+      role: "",
     },
   });
+  const { data: roles, isPending: rolesPending } = useAssignableRoles();
   const queryClient = useQueryClient();
   const mutation = useMutation({
     mutationFn: (data: z.infer<typeof formSchema>) =>
@@ -148,19 +150,22 @@ export default function CreateUserDialog({ open, onOpenChange }: Props) {
                   <Select
                     onValueChange={field.onChange}
                     value={field.value}
-                    disabled={mutation.isPending}
+                    disabled={mutation.isPending || rolesPending}
                   >
                     <FormControl>
                       <SelectTrigger>
-                        <SelectValue />
+                        <SelectValue placeholder="请选择身份" />
                       </SelectTrigger>
                     </FormControl>
                     <SelectContent>
-                      <SelectItem value="普通助理">普通助理</SelectItem>
-                      <SelectItem value="资深助理">资深助理</SelectItem>
-                      <SelectItem value="黑心">黑心</SelectItem>
+                      {roles?.map((role) => (
+                        <SelectItem key={role.id} value={role.name}>
+                          {role.name}
+                        </SelectItem>
+                      ))}
                     </SelectContent>
                   </Select>
+                  <FormMessage />
                 </FormItem>
               )}
             />
@@ -21,7 +21,10 @@ interface Props {
 export default function DeleteUserDialog({ user, open, onOpenChange }: Props) {
   const queryClient = useQueryClient();
   const mutation = useMutation({
-    mutationFn: () => api.delete<APIResponse<null>>(`/users/${user?.id}`),
+    mutationFn: () =>
+      api.post<APIResponse<UserType>>(`/users/${user?.id}/deactivate`, {
+        status: "deactivated",
+      }),
     onSuccess: (res) => {
       queryClient.setQueryData(["users"], (data: UserType[]) => {
         return data.filter((u) => u.id !== user?.id);
@@ -39,10 +42,10 @@ export default function DeleteUserDialog({ user, open, onOpenChange }: Props) {
     <AlertDialog open={open} onOpenChange={onOpenChange}>
       <AlertDialogContent>
         <AlertDialogHeader>
-          <AlertDialogTitle>删除用户</AlertDialogTitle>
+          <AlertDialogTitle>停用用户</AlertDialogTitle>
           <AlertDialogDescription>
-            你确定要删除{user?.fullName}({user?.username}
-            )吗？删除用户之后，与其相关的所有数据都会被一起删除。
+            你确定要停用{user?.fullName}({user?.username}
+            )吗？停用之后该用户将无法登录，其相关数据会被保留，管理员可以随时恢复。
           </AlertDialogDescription>
         </AlertDialogHeader>
         <div className="flex items-center justify-end gap-2 mt-4">
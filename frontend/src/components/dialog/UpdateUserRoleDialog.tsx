@@ -15,6 +15,7 @@ import {
   FormField,
   FormItem,
   FormLabel,
+  FormMessage,
 } from "@/components/ui/form";
 import {
   Select,
@@ -29,6 +30,7 @@ import { api, APIResponse } from "@/lib/api";
 import { AxiosResponse } from "axios";
 import { toast } from "sonner";
 import PendingButton from "@/components/PendingButton";
+import { useAssignableRoles } from "@/hooks/use-assignable-roles";
 
 interface Props {
   user: UserType | null;
@@ -37,9 +39,11 @@ interface Props {
 }
 
 const formSchema = z.object({
-  role: z.string({
-    required_error: "请提供用户的新身份",
-  }),
+  role: z
+    .string({
+      required_error: "请提供用户的新身份",
+    })
+    .min(1, { message: "请提供用户的新身份" }),
 });
 
 export default function UpdateUserRoleDialog({
@@ -50,9 +54,10 @@ export default function UpdateUserRoleDialog({
   const form = useForm<z.infer<typeof formSchema>>({
     resolver: zodResolver(formSchema),
     defaultValues: {
-      role: "普通助理",
+      role: "",
     },
   });
+  const { data: roles, isPending: rolesPending } = useAssignableRoles();
   const queryClient = useQueryClient();
 
   const mutation = useMutation({
@@ -95,19 +100,22 @@ export default function UpdateUserRoleDialog({
                   <Select
                     onValueChange={field.onChange}
                     value={field.value}
-                    disabled={mutation.isPending}
+                    disabled={mutation.isPending || rolesPending}
                   >
                     <FormControl>
                       <SelectTrigger>
-                        <SelectValue />
+                        <SelectValue placeholder="请选择身份" />
                       </SelectTrigger>
                     </FormControl>
                     <SelectContent>
-                      <SelectItem value="普通助理">普通助理</SelectItem>
-                      <SelectItem value="资深助理">资深助理</SelectItem>
-                      <SelectItem value="黑心">黑心</SelectItem>
+                      {roles?.map((role) => (
+                        <SelectItem key={role.id} value={role.name}>
+                          {role.name}
+                        </SelectItem>
+                      ))}
                     </SelectContent>
                   </Select>
+                  <FormMessage />
                 </FormItem>
               )}
             />
@@ -3,7 +3,7 @@ import { UserType } from "@/types/user";
 import { DropdownMenu } from "@radix-ui/react-dropdown-menu";
 import { useMutation, useQueryClient } from "@tanstack/react-query";
 import { AxiosResponse } from "axios";
-import { KeyRound, LogOut, User } from "lucide-react";
+import { KeyRound, LogOut, ShieldCheck, User } from "lucide-react";
 import { useNavigate } from "react-router";
 import { toast } from "sonner";
 import {
@@ -63,6 +63,14 @@ export default function NavUser() {
                   <KeyRound />
                   修改密码
                 </DropdownMenuItem>
+                {!myInfo?.twoFactorEnabled && !myInfo?.impersonatedBy && (
+                  <DropdownMenuItem
+                    onClick={() => navigate("/two-factor-setup")}
+                  >
+                    <ShieldCheck />
+                    启用两步验证
+                  </DropdownMenuItem>
+                )}
               </DropdownMenuGroup>
               <DropdownMenuSeparator />
               <DropdownMenuGroup>
@@ -1,6 +1,6 @@
 import { api, APIResponse } from "@/lib/api";
 import { UserType } from "@/types/user";
-import { useQuery } from "@tanstack/react-query";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
 import { ColumnDef } from "@tanstack/react-table";
 import { MoreHorizontal } from "lucide-react";
 import { useState } from "react";
@@ -19,12 +19,19 @@ import {
 } from "../ui/dropdown-menu";
 import UpdateUserRoleDialog from "@/components/dialog/UpdateUserRoleDialog";
 import { format } from "date-fns";
+import { AxiosResponse } from "axios";
+import { useNavigate } from "react-router";
 
 export default function UsersTable() {
   const { data, isPending, isError, error } = useQuery({
     queryKey: ["users"],
     queryFn: () =>
-      api.get<APIResponse<UserType[]>>("/users").then((res) => res.data.data),
+      api
+        .get<APIResponse<{ users: UserType[]; nextCursor: string }>>(
+          "/users",
+          { params: { limit: 100 } }
+        )
+        .then((res) => res.data.data.users),
   });
   const [globalFilter, setGlobalFilter] = useState("");
   const [updateRoleDialogOpen, setUpdateRoleDialogOpen] = useState(false);
@@ -32,6 +39,21 @@ export default function UsersTable() {
   const [currentUser, setCurrentUser] = useState<UserType | null>(null);
   const [createUserDialogOpen, setCreateUserDialogOpen] = useState(false);
 
+  const queryClient = useQueryClient();
+  const navigate = useNavigate();
+  const impersonateMutation = useMutation({
+    mutationFn: (user: UserType) => api.post(`/users/${user.id}/impersonate`),
+    onSuccess: (res: AxiosResponse<APIResponse<UserType>>) => {
+      toast.success(res.data.message);
+      queryClient.clear();
+      queryClient.setQueryData(["me"], res.data.data);
+      navigate("/");
+    },
+    onError: (err) => {
+      toast.error(err.message);
+    },
+  });
+
   const columns: ColumnDef<UserType>[] = [
     {
       accessorKey: "username",
@@ -96,6 +118,11 @@ export default function UsersTable() {
                 >
                   更改身份
                 </DropdownMenuItem>
+                <DropdownMenuItem
+                  onClick={() => impersonateMutation.mutate(user)}
+                >
+                  以该用户身份查看
+                </DropdownMenuItem>
                 <DropdownMenuSeparator />
                 <DropdownMenuItem
                   className="text-destructive"
@@ -104,7 +131,7 @@ export default function UsersTable() {
                     setCurrentUser(user);
                   }}
                 >
-                  删除用户
+                  停用用户
                 </DropdownMenuItem>
               </DropdownMenuContent>
             </DropdownMenu>
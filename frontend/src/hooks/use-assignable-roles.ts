@@ -0,0 +1,15 @@
+import { api, APIResponse } from "@/lib/api";
+import { RoleType } from "@/types/role";
+import { useQuery } from "@tanstack/react-query";
+
+// useAssignableRoles fetches the roles below the level of the current user,
+// the ones they can give when creating users or changing their role.
+export function useAssignableRoles() {
+  return useQuery({
+    queryKey: ["assignable-roles"],
+    queryFn: () =>
+      api
+        .get<APIResponse<RoleType[]>>("/users/assignable-roles")
+        .then((res) => res.data.data),
+  });
+}
@@ -1,4 +1,6 @@
 import AuthGuard from "@/components/auth/AuthGuard";
+import TwoFactorEnrollmentGuard from "@/components/auth/TwoFactorEnrollmentGuard";
+import ImpersonationBanner from "@/components/ImpersonationBanner";
 import AppSidebar from "@/components/sidebar/AppSidebar";
 import { SidebarInset, SidebarProvider } from "@/components/ui/sidebar";
 import { Outlet } from "react-router";
@@ -6,12 +8,15 @@ import { Outlet } from "react-router";
 export default function DashboardLayout() {
   return (
     <AuthGuard>
-      <SidebarProvider>
-        <AppSidebar />
-        <SidebarInset className="p-4">
-          <Outlet />
-        </SidebarInset>
-      </SidebarProvider>
+      <TwoFactorEnrollmentGuard>
+        <SidebarProvider>
+          <AppSidebar />
+          <SidebarInset className="p-4">
+            <ImpersonationBanner />
+            <Outlet />
+          </SidebarInset>
+        </SidebarProvider>
+      </TwoFactorEnrollmentGuard>
     </AuthGuard>
   );
 }
@@ -0,0 +1,11 @@
+import { Outlet } from "react-router";
+
+// PublicLayout hosts the pages opened from emailed links, which work whether
+// or not someone is logged in on this browser.
+export default function PublicLayout() {
+  return (
+    <div className="h-screen flex items-center justify-center">
+      <Outlet />
+    </div>
+  );
+}
@@ -0,0 +1,14 @@
+import AuthGuard from "@/components/auth/AuthGuard";
+import { Outlet } from "react-router";
+
+// TwoFactorSetupLayout sits outside the dashboard, which sends the users
+// who still have to enable two-factor authentication here.
+export default function TwoFactorSetupLayout() {
+  return (
+    <AuthGuard>
+      <div className="h-screen flex items-center justify-center">
+        <Outlet />
+      </div>
+    </AuthGuard>
+  );
+}
@@ -1,24 +1,66 @@
-import axios, { AxiosResponse } from "axios";
+import axios, { AxiosError, AxiosResponse } from "axios";
 
 export type APIResponse<T> = {
   success: boolean;
   message: string;
+  code?: string;
+  fields?: Record<string, string>;
   data: T;
 };
 
+// APIError carries the stable code and the field details of a failed
+// request next to the message shown to the user.
+export class APIError extends Error {
+  status: number;
+  code: string;
+  fields: Record<string, string>;
+
+  constructor(
+    message: string,
+    status: number,
+    code = "",
+    fields: Record<string, string> = {}
+  ) {
+    super(message);
+    this.name = "APIError";
+    this.status = status;
+    this.code = code;
+    this.fields = fields;
+  }
+}
+
 export const api = axios.create({
   baseURL: "/api",
   withCredentials: true,
+  xsrfCookieName: "__ecnc_shift_manager_csrf",
+  xsrfHeaderName: "X-CSRF-Token",
 });
 
 api.interceptors.response.use(
   (response: AxiosResponse<APIResponse<unknown>>) => {
-    const { success, message } = response.data;
+    const { success, message, code, fields } = response.data;
 
     if (success === true) {
       return response;
     } else {
-      return Promise.reject(new Error(message));
+      return Promise.reject(
+        new APIError(message, response.status, code, fields)
+      );
+    }
+  },
+  (error: AxiosError<APIResponse<unknown>>) => {
+    const body = error.response?.data;
+
+    if (body && typeof body.message === "string") {
+      return Promise.reject(
+        new APIError(
+          body.message,
+          error.response?.status ?? 0,
+          body.code,
+          body.fields
+        )
+      );
     }
+    return Promise.reject(error);
   }
 );
@@ -6,9 +6,15 @@ import { createRoot } from "react-dom/client";
 import { BrowserRouter, Route, Routes } from "react-router";
 import "./index.css";
 import AuthLayout from "@/layouts/AuthLayout.tsx";
+import PublicLayout from "@/layouts/PublicLayout";
+import TwoFactorSetupLayout from "@/layouts/TwoFactorSetupLayout";
 import DashboardLayout from "@/layouts/DashboardLayout.tsx";
 import DashboardIndexPage from "@/pages/DashboardIndexPage";
 import LoginPage from "@/pages/LoginPage.tsx";
+import ForgotPasswordPage from "@/pages/ForgotPasswordPage";
+import ResetPasswordPage from "@/pages/ResetPasswordPage";
+import ActivatePage from "@/pages/ActivatePage";
+import TwoFactorSetupPage from "@/pages/TwoFactorSetupPage";
 import UsersManagementPage from "@/pages/UsersManagementPage.tsx";
 import ScheduleTemplatesManagementPage from "@/pages/ShiftTemplatesManagementPage";
 import CreateScheduleTemplatePage from "@/pages/CreateScheduleTemplatePage";
@@ -45,6 +51,14 @@ createRoot(document.getElementById("root")!).render(
           </Route>
           <Route path="auth" element={<AuthLayout />}>
             <Route path="login" element={<LoginPage />} />
+            <Route path="forgot-password" element={<ForgotPasswordPage />} />
+          </Route>
+          <Route path="two-factor-setup" element={<TwoFactorSetupLayout />}>
+            <Route index element={<TwoFactorSetupPage />} />
+          </Route>
+          <Route element={<PublicLayout />}>
+            <Route path="reset-password" element={<ResetPasswordPage />} />
+            <Route path="activate" element={<ActivatePage />} />
           </Route>
         </Routes>
       </BrowserRouter>
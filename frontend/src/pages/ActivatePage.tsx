@@ -0,0 +1,167 @@
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { z } from "zod";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import {
+  Form,
+  FormControl,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from "@/components/ui/form";
+import { useMutation } from "@tanstack/react-query";
+import { api, APIResponse } from "@/lib/api";
+import { useState } from "react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import { AlertCircle, Loader2 } from "lucide-react";
+import { toast } from "sonner";
+import { Link, useNavigate, useSearchParams } from "react-router";
+import { AxiosResponse } from "axios";
+import { UserType } from "@/types/user";
+
+const formSchema = z
+  .object({
+    password: z.string().min(1, {
+      message: "密码不能为空",
+    }),
+    confirmPassword: z.string(),
+  })
+  .refine((data) => data.password === data.confirmPassword, {
+    message: "确认密码与密码不一致",
+    path: ["confirmPassword"],
+  });
+
+export default function ActivatePage() {
+  const form = useForm<z.infer<typeof formSchema>>({
+    resolver: zodResolver(formSchema),
+    defaultValues: {
+      password: "",
+      confirmPassword: "",
+    },
+  });
+
+  const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get("token") ?? "";
+  const [errorMessage, setErrorMessage] = useState<string>("");
+
+  const mutation = useMutation<
+    AxiosResponse<APIResponse<UserType>>,
+    Error,
+    { token: string; password: string }
+  >({
+    mutationFn: (data) => api.post("/auth/activate", data),
+    onSuccess: (res) => {
+      toast.success(res.data.message);
+      // activating does not log in, so the new account logs in next
+      navigate("/auth/login");
+    },
+    onError: (err) => {
+      setErrorMessage(err.message);
+    },
+  });
+
+  const onSubmit = (formData: z.infer<typeof formSchema>) => {
+    setErrorMessage("");
+    mutation.mutate({ token, password: formData.password });
+  };
+
+  if (!token) {
+    return (
+      <Card>
+        <CardHeader>
+          <CardTitle>激活账号</CardTitle>
+          <CardDescription>
+            激活链接无效，请联系管理员重新发送邀请。
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          <Button className="w-full" asChild>
+            <Link to="/auth/login">返回登录</Link>
+          </Button>
+        </CardContent>
+      </Card>
+    );
+  }
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle>激活账号</CardTitle>
+        <CardDescription>
+          欢迎加入，请为你的账号设置密码以完成激活。
+        </CardDescription>
+      </CardHeader>
+      <CardContent>
+        <Form {...form}>
+          <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
+            <FormField
+              control={form.control}
+              name="password"
+              render={({ field }) => (
+                <FormItem>
+                  <FormLabel>密码</FormLabel>
+                  <FormControl>
+                    <Input
+                      type="password"
+                      placeholder="请输入你的密码。"
+                      {...field}
+                    />
+                  </FormControl>
+                  <FormMessage />
+                </FormItem>
+              )}
+            />
+            <FormField
+              control={form.control}
+              name="confirmPassword"
+              render={({ field }) => (
+                <FormItem>
+                  <FormLabel>确认密码</FormLabel>
+                  <FormControl>
+                    <Input
+                      type="password"
+                      placeholder="请再次输入你的密码。"
+                      {...field}
+                    />
+                  </FormControl>
+                  <FormMessage />
+                </FormItem>
+              )}
+            />
+            {errorMessage && (
+              <Alert variant="destructive">
+                <AlertCircle className="h-4 w-4" />
+                <AlertTitle>激活失败</AlertTitle>
+                <AlertDescription>{errorMessage}</AlertDescription>
+              </Alert>
+            )}
+            <Button
+              type="submit"
+              className="w-full"
+              disabled={mutation.isPending}
+            >
+              {mutation.isPending ? (
+                <>
+                  <Loader2 className="animate-spin" />
+                  请稍等
+                </>
+              ) : (
+                "激活账号"
+              )}
+            </Button>
+          </form>
+        </Form>
+      </CardContent>
+    </Card>
+  );
+}
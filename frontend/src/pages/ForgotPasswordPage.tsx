@@ -0,0 +1,127 @@
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { z } from "zod";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import {
+  Form,
+  FormControl,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from "@/components/ui/form";
+import { useMutation } from "@tanstack/react-query";
+import { api, APIResponse } from "@/lib/api";
+import { useState } from "react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import { AlertCircle, Loader2, MailCheck } from "lucide-react";
+import { Link } from "react-router";
+import { AxiosResponse } from "axios";
+
+const formSchema = z.object({
+  email: z.string().email({
+    message: "请输入有效的邮箱地址",
+  }),
+});
+
+export default function ForgotPasswordPage() {
+  const form = useForm<z.infer<typeof formSchema>>({
+    resolver: zodResolver(formSchema),
+    defaultValues: {
+      email: "",
+    },
+  });
+
+  const [errorMessage, setErrorMessage] = useState<string>("");
+  // the server answers the same whether or not the account exists
+  const [sentMessage, setSentMessage] = useState<string>("");
+
+  const mutation = useMutation<
+    AxiosResponse<APIResponse<null>>,
+    Error,
+    z.infer<typeof formSchema>
+  >({
+    mutationFn: (data) => api.post("/auth/forgot-password", data),
+    onSuccess: (res) => {
+      setSentMessage(res.data.message);
+    },
+    onError: (err) => {
+      setErrorMessage(err.message);
+    },
+  });
+
+  const onSubmit = (formData: z.infer<typeof formSchema>) => {
+    setErrorMessage("");
+    mutation.mutate(formData);
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle>忘记密码</CardTitle>
+        <CardDescription>
+          请输入你账号的邮箱，我们会发送一封重置密码的邮件。
+        </CardDescription>
+      </CardHeader>
+      <CardContent className="space-y-4">
+        {sentMessage ? (
+          <Alert>
+            <MailCheck className="h-4 w-4" />
+            <AlertTitle>请查收邮件</AlertTitle>
+            <AlertDescription>{sentMessage}</AlertDescription>
+          </Alert>
+        ) : (
+          <Form {...form}>
+            <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
+              <FormField
+                control={form.control}
+                name="email"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>邮箱</FormLabel>
+                    <FormControl>
+                      <Input placeholder="请输入你的邮箱。" {...field} />
+                    </FormControl>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+              {errorMessage && (
+                <Alert variant="destructive">
+                  <AlertCircle className="h-4 w-4" />
+                  <AlertTitle>发送失败</AlertTitle>
+                  <AlertDescription>{errorMessage}</AlertDescription>
+                </Alert>
+              )}
+              <Button
+                type="submit"
+                className="w-full"
+                disabled={mutation.isPending}
+              >
+                {mutation.isPending ? (
+                  <>
+                    <Loader2 className="animate-spin" />
+                    请稍等
+                  </>
+                ) : (
+                  "发送重置邮件"
+                )}
+              </Button>
+            </form>
+          </Form>
+        )}
+        <Button variant="link" className="w-full" asChild>
+          <Link to="/auth/login">返回登录</Link>
+        </Button>
+      </CardContent>
+    </Card>
+  );
+}
@@ -19,15 +19,21 @@ import {
   FormLabel,
   FormMessage,
 } from "@/components/ui/form";
-import { useMutation, useQueryClient } from "@tanstack/react-query";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
 import { api, APIResponse } from "@/lib/api";
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
 import { AlertCircle, Loader2 } from "lucide-react";
 import { toast } from "sonner";
-import { useNavigate } from "react-router";
+import {
+  Link,
+  useLocation,
+  useNavigate,
+  useSearchParams,
+} from "react-router";
 import { AxiosResponse } from "axios";
 import { UserType } from "@/types/user";
+import SecondFactorForm from "@/components/auth/SecondFactorForm";
 
 const formSchema = z.object({
   username: z.string().min(1, {
@@ -38,6 +44,13 @@ const formSchema = z.object({
   }),
 });
 
+// accounts with two-factor authentication get a partial token instead of a
+// session, which the second step exchanges together with a code
+type SecondFactorRequestedType = {
+  twoFactorRequired: true;
+  partialToken: string;
+};
+
 export default function LoginPage() {
   const form = useForm<z.infer<typeof formSchema>>({
     resolver: zodResolver(formSchema),
@@ -49,14 +62,46 @@ export default function LoginPage() {
 
   const navigate = useNavigate();
   const queryClient = useQueryClient();
+  const [searchParams] = useSearchParams();
+  const location = useLocation();
+
+  // the single sign-on callback passes its partial token in the fragment,
+  // which never reaches a server log
+  const [partialToken, setPartialToken] = useState<string>(
+    () =>
+      new URLSearchParams(location.hash.slice(1)).get("partialToken") ?? ""
+  );
+  useEffect(() => {
+    if (location.hash) {
+      navigate(location.pathname + location.search, { replace: true });
+    }
+  }, [location, navigate]);
+
+  const { data: authMethods } = useQuery({
+    queryKey: ["auth-methods"],
+    queryFn: () =>
+      api
+        .get<APIResponse<{ password: boolean; oidc: boolean }>>(
+          "/auth/methods"
+        )
+        .then((res) => res.data.data),
+  });
 
   const mutation = useMutation({
     mutationFn: (data: z.infer<typeof formSchema>) => {
       return api.post("/auth/login", data);
     },
-    onSuccess: (res: AxiosResponse<APIResponse<UserType>>) => {
+    onSuccess: (
+      res: AxiosResponse<APIResponse<UserType | SecondFactorRequestedType>>
+    ) => {
       const { message, data } = res.data;
 
+      if ("partialToken" in data) {
+        toast.info(message);
+        setPartialToken(data.partialToken);
+        return;
+      }
+
       toast.success(message);
       navigate("/");
       queryClient.setQueryData(["me"], data);
@@ -66,13 +111,38 @@ export default function LoginPage() {
     },
   });
 
-  const [loginError, setLoginError] = useState<string>("");
+  // errors of the single sign-on callback arrive in the query string
+  const [loginError, setLoginError] = useState<string>(
+    searchParams.get("error") ?? ""
+  );
 
   const onSubmit = (formData: z.infer<typeof formSchema>) => {
     setLoginError("");
     mutation.mutate(formData);
   };
 
+  if (partialToken) {
+    return (
+      <Card>
+        <CardHeader>
+          <CardTitle>两步验证</CardTitle>
+          <CardDescription>
+            你的账号已启用两步验证，请完成验证以登录系统。
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          <SecondFactorForm
+            partialToken={partialToken}
+            onCancel={() => {
+              setPartialToken("");
+              form.reset();
+            }}
+          />
+        </CardContent>
+      </Card>
+    );
+  }
+
   return (
     <Card>
       <CardHeader>
@@ -102,7 +172,15 @@ export default function LoginPage() {
               name="password"
               render={({ field }) => (
                 <FormItem>
-                  <FormLabel>密码</FormLabel>
+                  <div className="flex items-center justify-between">
+                    <FormLabel>密码</FormLabel>
+                    <Link
+                      to="/auth/forgot-password"
+                      className="text-sm text-muted-foreground underline-offset-4 hover:underline"
+                    >
+                      忘记密码？
+                    </Link>
+                  </div>
                   <FormControl>
                     <Input
                       type="password"
@@ -136,6 +214,11 @@ export default function LoginPage() {
                 "登录"
               )}
             </Button>
+            {authMethods?.oidc && (
+              <Button variant="outline" className="w-full" asChild>
+                <a href="/api/auth/oidc/login">统一身份认证登录</a>
+              </Button>
+            )}
           </form>
         </Form>
       </CardContent>
@@ -0,0 +1,166 @@
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { z } from "zod";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import {
+  Form,
+  FormControl,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from "@/components/ui/form";
+import { useMutation, useQueryClient } from "@tanstack/react-query";
+import { api, APIResponse } from "@/lib/api";
+import { useState } from "react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import { AlertCircle, Loader2 } from "lucide-react";
+import { toast } from "sonner";
+import { Link, useNavigate, useSearchParams } from "react-router";
+import { AxiosResponse } from "axios";
+
+const formSchema = z
+  .object({
+    newPassword: z.string().min(1, {
+      message: "新密码不能为空",
+    }),
+    confirmPassword: z.string(),
+  })
+  .refine((data) => data.newPassword === data.confirmPassword, {
+    message: "确认密码与新密码不一致",
+    path: ["confirmPassword"],
+  });
+
+export default function ResetPasswordPage() {
+  const form = useForm<z.infer<typeof formSchema>>({
+    resolver: zodResolver(formSchema),
+    defaultValues: {
+      newPassword: "",
+      confirmPassword: "",
+    },
+  });
+
+  const navigate = useNavigate();
+  const queryClient = useQueryClient();
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get("token") ?? "";
+  const [errorMessage, setErrorMessage] = useState<string>("");
+
+  const mutation = useMutation<
+    AxiosResponse<APIResponse<null>>,
+    Error,
+    { token: string; newPassword: string }
+  >({
+    mutationFn: (data) => api.post("/auth/reset-password", data),
+    onSuccess: (res) => {
+      toast.success(res.data.message);
+      // resetting the password ends every session of the account
+      queryClient.removeQueries({ queryKey: ["me"] });
+      navigate("/auth/login");
+    },
+    onError: (err) => {
+      setErrorMessage(err.message);
+    },
+  });
+
+  const onSubmit = (formData: z.infer<typeof formSchema>) => {
+    setErrorMessage("");
+    mutation.mutate({ token, newPassword: formData.newPassword });
+  };
+
+  if (!token) {
+    return (
+      <Card>
+        <CardHeader>
+          <CardTitle>重置密码</CardTitle>
+          <CardDescription>重置链接无效，请重新申请重置密码。</CardDescription>
+        </CardHeader>
+        <CardContent>
+          <Button className="w-full" asChild>
+            <Link to="/auth/forgot-password">重新申请</Link>
+          </Button>
+        </CardContent>
+      </Card>
+    );
+  }
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle>重置密码</CardTitle>
+        <CardDescription>
+          请设置新的密码，重置后所有设备上的登录都会失效。
+        </CardDescription>
+      </CardHeader>
+      <CardContent>
+        <Form {...form}>
+          <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
+            <FormField
+              control={form.control}
+              name="newPassword"
+              render={({ field }) => (
+                <FormItem>
+                  <FormLabel>新密码</FormLabel>
+                  <FormControl>
+                    <Input
+                      type="password"
+                      placeholder="请输入你的新密码。"
+                      {...field}
+                    />
+                  </FormControl>
+                  <FormMessage />
+                </FormItem>
+              )}
+            />
+            <FormField
+              control={form.control}
+              name="confirmPassword"
+              render={({ field }) => (
+                <FormItem>
+                  <FormLabel>确认密码</FormLabel>
+                  <FormControl>
+                    <Input
+                      type="password"
+                      placeholder="请确认你的新密码。"
+                      {...field}
+                    />
+                  </FormControl>
+                  <FormMessage />
+                </FormItem>
+              )}
+            />
+            {errorMessage && (
+              <Alert variant="destructive">
+                <AlertCircle className="h-4 w-4" />
+                <AlertTitle>重置密码失败</AlertTitle>
+                <AlertDescription>{errorMessage}</AlertDescription>
+              </Alert>
+            )}
+            <Button
+              type="submit"
+              className="w-full"
+              disabled={mutation.isPending}
+            >
+              {mutation.isPending ? (
+                <>
+                  <Loader2 className="animate-spin" />
+                  请稍等
+                </>
+              ) : (
+                "重置密码"
+              )}
+            </Button>
+          </form>
+        </Form>
+      </CardContent>
+    </Card>
+  );
+}
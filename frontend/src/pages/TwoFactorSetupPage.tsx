@@ -0,0 +1,262 @@
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { z } from "zod";
+import { useForm } from "react-hook-form";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import {
+  Form,
+  FormControl,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from "@/components/ui/form";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import { api, APIResponse } from "@/lib/api";
+import { useState } from "react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import { AlertCircle, Loader2 } from "lucide-react";
+import { toast } from "sonner";
+import { useNavigate } from "react-router";
+import { AxiosResponse } from "axios";
+import { UserType } from "@/types/user";
+
+const formSchema = z.object({
+  code: z.string().trim().min(1, {
+    message: "验证码不能为空",
+  }),
+});
+
+type EnrollmentType = {
+  secret: string;
+  otpauthUrl: string;
+};
+
+export default function TwoFactorSetupPage() {
+  const form = useForm<z.infer<typeof formSchema>>({
+    resolver: zodResolver(formSchema),
+    defaultValues: {
+      code: "",
+    },
+  });
+
+  const navigate = useNavigate();
+  const queryClient = useQueryClient();
+  const { data: myInfo } = useQuery({
+    queryKey: ["me"],
+    queryFn: () =>
+      api.get<APIResponse<UserType>>("/me").then((res) => res.data.data),
+  });
+
+  const [enrollment, setEnrollment] = useState<EnrollmentType | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [errorMessage, setErrorMessage] = useState<string>("");
+
+  const enrollMutation = useMutation<
+    AxiosResponse<APIResponse<EnrollmentType>>,
+    Error
+  >({
+    mutationFn: () => api.post("/me/2fa/enroll"),
+    onSuccess: (res) => {
+      setErrorMessage("");
+      setEnrollment(res.data.data);
+    },
+    onError: (err) => {
+      setErrorMessage(err.message);
+    },
+  });
+
+  const enableMutation = useMutation<
+    AxiosResponse<APIResponse<{ recoveryCodes: string[] }>>,
+    Error,
+    z.infer<typeof formSchema>
+  >({
+    mutationFn: (data) => api.post("/me/2fa/enable", data),
+    onSuccess: (res) => {
+      toast.success(res.data.message);
+      setErrorMessage("");
+      setRecoveryCodes(res.data.data.recoveryCodes);
+    },
+    onError: (err) => {
+      setErrorMessage(err.message);
+    },
+  });
+
+  const logoutMutation = useMutation({
+    mutationFn: () => api.post("/auth/logout"),
+    onSuccess: () => {
+      queryClient.clear();
+      navigate("/auth/login");
+    },
+    onError: (err) => {
+      toast.error(err.message);
+    },
+  });
+
+  const onSubmit = (formData: z.infer<typeof formSchema>) => {
+    setErrorMessage("");
+    enableMutation.mutate(formData);
+  };
+
+  const finish = () => {
+    // the dashboard lets the user in once /me reports 2FA as enabled
+    queryClient.invalidateQueries({ queryKey: ["me"] });
+    navigate("/");
+  };
+
+  const errorAlert = errorMessage && (
+    <Alert variant="destructive">
+      <AlertCircle className="h-4 w-4" />
+      <AlertTitle>设置失败</AlertTitle>
+      <AlertDescription>{errorMessage}</AlertDescription>
+    </Alert>
+  );
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <Card className="max-w-md">
+        <CardHeader>
+          <CardTitle>保存恢复码</CardTitle>
+          <CardDescription>
+            两步验证已启用。无法使用验证器应用时，可以用下面的恢复码登录，每个只能使用一次。恢复码只显示这一次，请妥善保存。
+          </CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-4">
+          <div className="grid grid-cols-2 gap-2 rounded-md border p-4 font-mono text-sm">
+            {recoveryCodes.map((code) => (
+              <span key={code}>{code}</span>
+            ))}
+          </div>
+          <Button className="w-full" onClick={finish}>
+            我已保存，继续
+          </Button>
+        </CardContent>
+      </Card>
+    );
+  }
+
+  if (myInfo?.twoFactorEnabled) {
+    return (
+      <Card className="max-w-md">
+        <CardHeader>
+          <CardTitle>两步验证</CardTitle>
+          <CardDescription>你的账号已启用两步验证。</CardDescription>
+        </CardHeader>
+        <CardContent>
+          <Button className="w-full" onClick={() => navigate("/")}>
+            返回
+          </Button>
+        </CardContent>
+      </Card>
+    );
+  }
+
+  return (
+    <Card className="max-w-md">
+      <CardHeader>
+        <CardTitle>启用两步验证</CardTitle>
+        <CardDescription>
+          {myInfo?.twoFactorRequired
+            ? "你的角色要求启用两步验证，完成设置后才能继续使用系统。"
+            : "启用后，登录时除了密码还需要输入验证器应用中的验证码。"}
+        </CardDescription>
+      </CardHeader>
+      <CardContent className="space-y-4">
+        {enrollment ? (
+          <Form {...form}>
+            <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
+              <p className="text-sm">
+                请在验证器应用中添加账号，手动输入以下密钥，或在本设备上
+                <a
+                  href={enrollment.otpauthUrl}
+                  className="underline underline-offset-4"
+                >
+                  直接打开验证器应用
+                </a>
+                。
+              </p>
+              <div className="rounded-md border p-4 font-mono text-sm break-all">
+                {enrollment.secret}
+              </div>
+              <FormField
+                control={form.control}
+                name="code"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>验证码</FormLabel>
+                    <FormControl>
+                      <Input
+                        autoComplete="one-time-code"
+                        inputMode="numeric"
+                        placeholder="请输入验证器应用中的 6 位验证码。"
+                        {...field}
+                      />
+                    </FormControl>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+              {errorAlert}
+              <Button
+                type="submit"
+                className="w-full"
+                disabled={enableMutation.isPending}
+              >
+                {enableMutation.isPending ? (
+                  <>
+                    <Loader2 className="animate-spin" />
+                    请稍等
+                  </>
+                ) : (
+                  "启用"
+                )}
+              </Button>
+            </form>
+          </Form>
+        ) : (
+          <>
+            {errorAlert}
+            <Button
+              className="w-full"
+              disabled={enrollMutation.isPending}
+              onClick={() => enrollMutation.mutate()}
+            >
+              {enrollMutation.isPending ? (
+                <>
+                  <Loader2 className="animate-spin" />
+                  请稍等
+                </>
+              ) : (
+                "开始设置"
+              )}
+            </Button>
+          </>
+        )}
+        {myInfo?.twoFactorRequired ? (
+          <Button
+            variant="link"
+            className="w-full"
+            onClick={() => logoutMutation.mutate()}
+          >
+            登出
+          </Button>
+        ) : (
+          <Button
+            variant="link"
+            className="w-full"
+            onClick={() => navigate("/")}
+          >
+            返回
+          </Button>
+        )}
+      </CardContent>
+    </Card>
+  );
+}
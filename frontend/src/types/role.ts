@@ -0,0 +1,8 @@
+export type RoleType = {
+  id: string;
+  name: string;
+  level: number;
+  description: string;
+  createdAt: string;
+  version: number;
+};
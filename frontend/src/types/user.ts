@@ -5,5 +5,12 @@ export type UserType = {
   fullName: string;
   role: string;
   level: number;
+  status: "active" | "deactivated" | "graduated";
+  deactivatedAt: string | null;
   createdAt: string;
+  locale: string;
+  twoFactorEnabled: boolean;
+  // the role of the user has to use two-factor authentication
+  twoFactorRequired: boolean;
+  impersonatedBy?: string;
 };
@@ -19,8 +19,13 @@ export default defineConfig(({ mode }) => {
         "/api": {
           target: env.VITE_BACKEND_URL,
           changeOrigin: true,
+          xfwd: true,
           rewrite: (path) => path.replace(/^\/api/, ""),
         },
+        "/.well-known": {
+          target: env.VITE_BACKEND_URL,
+          changeOrigin: true,
+        },
       },
     },
   };